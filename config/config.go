@@ -3,14 +3,19 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	Upload   UploadConfig
+	Storage  StorageConfig
 	Session  SessionConfig
 	Nav      NavConfig
+	Backup   BackupConfig
 }
 
 type ServerConfig struct {
@@ -23,20 +28,66 @@ type DatabaseConfig struct {
 }
 
 type UploadConfig struct {
-	Path         string
-	MaxSize      int64
-	AllowedTypes []string
+	Path           string
+	MaxSize        int64
+	AllowedTypes   []string
+	LogoMaxSize    int   // 图标最大边长（像素），超过时自动缩小
+	ThumbnailSizes []int // 图标缩略图尺寸（像素）
+
+	QuarantinePath      string        // 未被引用的文件清理前先移动到这里（不在 /uploads 下对外提供访问）
+	GCInterval          time.Duration // 自动清理间隔，0表示不自动清理
+	GCGracePeriod       time.Duration // 新上传的文件在该时间内不会被清理（可能还未保存到站点）
+	GCDownloadWindow    time.Duration // 在该时间内被下载过的文件不会被清理（可能通过下载链接共享）
+	QuarantineRetention time.Duration // 隔离区文件保留时长，超过后永久删除
+
+	ChunkedMaxSize int64         // 分片上传允许的最大文件大小
+	ChunkSize      int64         // 分片大小
+	ChunkPath      string        // 分片临时目录
+	ChunkExpiry    time.Duration // 分片上传超过该时间未更新视为放弃，临时文件会被清理
+}
+
+// StorageConfig 上传文件存储配置
+type StorageConfig struct {
+	Driver          string // local 或 s3
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3Prefix        string        // 对象键前缀
+	S3PathStyle     bool          // 路径风格地址（MinIO等需要）
+	S3Presign       bool          // 访问文件时重定向到预签名地址，否则由服务端代理
+	S3PresignExpiry time.Duration // 预签名地址有效期
 }
 
+// BackupConfig 定时备份配置
+type BackupConfig struct {
+	Path        string // 备份文件保存目录
+	Schedule    string // cron表达式（分 时 日 月 周），为空时不自动备份
+	KeepDaily   int    // 保留最近N天每天的一份备份
+	KeepWeekly  int    // 保留最近N周每周的一份备份
+	KeepMonthly int    // 保留最近N个月每月的一份备份
+
+	ImportMaxSize     int64 // 上传导入的备份文件大小限制
+	ImportMaxFileSize int64 // 上传导入的备份中单个上传文件的大小限制
+}
+
+// DefaultSessionSecret 未设置 SESSION_SECRET 时使用的公开默认密钥，不能用于签名
+const DefaultSessionSecret = "nav-admin-secret-key-change-in-production"
+
 type SessionConfig struct {
 	Secret string
 	MaxAge int
 }
 
 type NavConfig struct {
-	JSONPath string // nav.json输出路径
+	JSONPath   string // nav.json输出路径
+	JSONLayout string // nav.json中分类的结构：flat（平铺，子分类带 parent 字段，默认）或 nested（子分类在 children 中）
 }
 
+// Version 程序版本，构建时通过 -ldflags "-X nav-admin/config.Version=v1.2.3" 设置
+var Version = "dev"
+
 var AppConfig *Config
 
 func Init() {
@@ -49,16 +100,52 @@ func Init() {
 			Path: getEnv("DB_PATH", "./data/admin.db"),
 		},
 		Upload: UploadConfig{
-			Path:         getEnv("UPLOAD_PATH", "./uploads"),
-			MaxSize:      5 * 1024 * 1024, // 5MB
-			AllowedTypes: []string{".png", ".jpg", ".jpeg", ".svg", ".gif", ".zip", ".rar", ".7z", ".pdf", ".doc", ".docx", ".xls", ".xlsx"},
+			Path:           getEnv("UPLOAD_PATH", "./uploads"),
+			MaxSize:        getEnvSize("UPLOAD_MAX_SIZE", 5*1024*1024), // 5MB
+			AllowedTypes:   []string{".png", ".jpg", ".jpeg", ".svg", ".gif", ".webp", ".ico", ".zip", ".rar", ".7z", ".pdf", ".doc", ".docx", ".xls", ".xlsx"},
+			LogoMaxSize:    256,
+			ThumbnailSizes: []int{32, 64},
+
+			QuarantinePath:      getEnv("UPLOAD_QUARANTINE_PATH", "./data/quarantine"),
+			GCInterval:          getEnvDuration("UPLOAD_GC_INTERVAL", 0),
+			GCGracePeriod:       getEnvDuration("UPLOAD_GC_GRACE_PERIOD", 24*time.Hour),
+			GCDownloadWindow:    getEnvDuration("UPLOAD_GC_DOWNLOAD_WINDOW", 30*24*time.Hour),
+			QuarantineRetention: getEnvDuration("UPLOAD_QUARANTINE_RETENTION", 7*24*time.Hour),
+
+			ChunkedMaxSize: getEnvSize("UPLOAD_CHUNKED_MAX_SIZE", 2*1024*1024*1024), // 2GB
+			ChunkSize:      getEnvSize("UPLOAD_CHUNK_SIZE", 5*1024*1024),            // 5MB
+			ChunkPath:      getEnv("UPLOAD_CHUNK_PATH", "./data/chunks"),
+			ChunkExpiry:    getEnvDuration("UPLOAD_CHUNK_EXPIRY", 24*time.Hour),
+		},
+		Storage: StorageConfig{
+			Driver:          getEnv("STORAGE_DRIVER", "local"),
+			S3Endpoint:      getEnv("S3_ENDPOINT", ""),
+			S3Region:        getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:        getEnv("S3_BUCKET", ""),
+			S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+			S3Prefix:        getEnv("S3_PREFIX", ""),
+			S3PathStyle:     getEnv("S3_PATH_STYLE", "true") == "true",
+			S3Presign:       getEnv("S3_PRESIGN", "false") == "true",
+			S3PresignExpiry: getEnvDuration("S3_PRESIGN_EXPIRY", 15*time.Minute),
 		},
 		Session: SessionConfig{
-			Secret: getEnv("SESSION_SECRET", "nav-admin-secret-key-change-in-production"),
+			Secret: getEnv("SESSION_SECRET", DefaultSessionSecret),
 			MaxAge: 86400, // 24小时
 		},
 		Nav: NavConfig{
-			JSONPath: getEnv("NAV_JSON_PATH", "./static/nav.json"),
+			JSONPath:   getEnv("NAV_JSON_PATH", "./static/nav.json"),
+			JSONLayout: getEnv("NAV_JSON_LAYOUT", "flat"),
+		},
+		Backup: BackupConfig{
+			Path:        getEnv("BACKUP_PATH", "./data/backups"),
+			Schedule:    getEnv("BACKUP_SCHEDULE", ""),
+			KeepDaily:   getEnvInt("BACKUP_KEEP_DAILY", 7),
+			KeepWeekly:  getEnvInt("BACKUP_KEEP_WEEKLY", 4),
+			KeepMonthly: getEnvInt("BACKUP_KEEP_MONTHLY", 6),
+
+			ImportMaxSize:     getEnvSize("BACKUP_IMPORT_MAX_SIZE", 50*1024*1024),      // 50MB
+			ImportMaxFileSize: getEnvSize("BACKUP_IMPORT_MAX_FILE_SIZE", 10*1024*1024), // 10MB
 		},
 	}
 
@@ -75,3 +162,58 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt 读取非负整数类型的环境变量，格式错误时使用默认值
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		log.Printf("环境变量 %s 格式错误（%s），使用默认值 %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvDuration 读取时长类型的环境变量（如 24h、30m），格式错误时使用默认值
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("环境变量 %s 格式错误（%s），使用默认值 %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvSize 读取大小类型的环境变量，支持字节数或带 KB、MB、GB 单位（如 512MB），格式错误时使用默认值
+func getEnvSize(key string, defaultValue int64) int64 {
+	value := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	if value == "" {
+		return defaultValue
+	}
+
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		log.Printf("环境变量 %s 格式错误（%s），使用默认值 %d", key, os.Getenv(key), defaultValue)
+		return defaultValue
+	}
+	return n * unit
+}
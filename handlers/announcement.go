@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"nav-admin/models"
+	"nav-admin/navdoc"
 	"nav-admin/utils"
 	"strconv"
 
@@ -53,6 +54,12 @@ func (h *AnnouncementHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if errs := utils.ValidateAnnouncement(&ann); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+	ann.Content = utils.SanitizeRichText(ann.Content, ann.Format)
+
 	// 使用事务
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -93,6 +100,17 @@ func (h *AnnouncementHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if errs := utils.ValidateAnnouncement(&ann); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+	ann.Content = utils.SanitizeRichText(ann.Content, ann.Format)
+
+	if _, err := models.GetAnnouncementByID(h.DB, id); err != nil {
+		utils.NotFound(c, "公告不存在")
+		return
+	}
+
 	// 使用事务
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -173,6 +191,11 @@ func (h *AnnouncementHandler) UpdateConfig(c *gin.Context) {
 		return
 	}
 
+	if errs := utils.ValidateAnnouncementInterval(config.Interval); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
 	// 使用事务
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -197,3 +220,17 @@ func (h *AnnouncementHandler) UpdateConfig(c *gin.Context) {
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
 }
+
+// announcementFromDoc 从导入数据中构建公告（字段已经过 utils.ValidateNavDocument 校验和规范化）
+func announcementFromDoc(a navdoc.Announcement) *models.Announcement {
+	return &models.Announcement{
+		Timestamp: a.Timestamp,
+		Content:   utils.SanitizeRichText(a.Content, a.Format),
+		Format:    a.Format,
+		PublishAt: a.PublishAt,
+		ExpireAt:  a.ExpireAt,
+		Priority:  a.Priority,
+		Pinned:    a.Pinned,
+		Severity:  a.Severity,
+	}
+}
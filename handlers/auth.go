@@ -2,11 +2,11 @@ package handlers
 
 import (
 	"database/sql"
-	"fmt"
-	"net/http"
 	"nav-admin/config"
+	"nav-admin/middleware"
 	"nav-admin/models"
 	"nav-admin/utils"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -41,9 +41,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 设置session cookie
-	sessionToken := fmt.Sprintf("%s_%d", user.Username, time.Now().Unix())
-	c.SetCookie("session", sessionToken, config.AppConfig.Session.MaxAge, "/", "", false, true)
+	// 创建登录会话并设置session cookie
+	maxAge := config.AppConfig.Session.MaxAge
+	sessionToken, err := models.CreateLoginSession(h.DB, user.Username, time.Duration(maxAge)*time.Second)
+	if err != nil {
+		utils.InternalServerError(c, "创建登录会话失败")
+		return
+	}
+	c.SetCookie("session", sessionToken, maxAge, "/", "", false, true)
 
 	utils.SuccessWithMessage(c, "登录成功", gin.H{
 		"username": user.Username,
@@ -52,14 +57,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 // Logout 用户登出
 func (h *AuthHandler) Logout(c *gin.Context) {
+	if token, err := c.Cookie("session"); err == nil && token != "" {
+		if err := models.DeleteLoginSession(h.DB, token); err != nil {
+			utils.InternalServerError(c, "登出失败")
+			return
+		}
+	}
 	c.SetCookie("session", "", -1, "/", "", false, true)
 	utils.SuccessWithMessage(c, "登出成功", nil)
 }
 
 // CheckAuth 检查登录状态
 func (h *AuthHandler) CheckAuth(c *gin.Context) {
-	session, err := c.Cookie("session")
-	if err != nil || session == "" {
+	if !middleware.IsLoggedIn(c, h.DB) {
 		c.JSON(http.StatusOK, gin.H{
 			"authenticated": false,
 		})
@@ -106,18 +116,8 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// 获取当前用户 (从session中解析用户名)
-	session, _ := c.Cookie("session")
-	// session格式: username_timestamp
-	username := "admin" // 默认用户名
-	if len(session) > 0 {
-		for i := len(session) - 1; i >= 0; i-- {
-			if session[i] == '_' {
-				username = session[:i]
-				break
-			}
-		}
-	}
+	// 获取当前用户
+	username := middleware.CurrentUsername(c)
 
 	// 验证旧密码
 	user, err := models.GetUserByUsername(h.DB, username)
@@ -137,5 +137,12 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	// 使该用户的所有会话失效，需要重新登录
+	if err := models.DeleteUserLoginSessions(h.DB, username); err != nil {
+		utils.InternalServerError(c, "清除登录会话失败")
+		return
+	}
+	c.SetCookie("session", "", -1, "/", "", false, true)
+
 	utils.SuccessWithMessage(c, "密码修改成功，请重新登录", nil)
 }
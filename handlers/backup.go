@@ -2,15 +2,18 @@ package handlers
 
 import (
 	"archive/zip"
-	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"nav-admin/config"
+	"nav-admin/middleware"
 	"nav-admin/models"
+	"nav-admin/navdoc"
+	"nav-admin/storage"
 	"nav-admin/utils"
-	"os"
+	"net/http"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -46,67 +49,117 @@ var allowedBackupExtensions = map[string]bool{
 }
 
 // ExportBackup 导出完整备份为zip文件
+// zip直接流式写入响应，不在内存中缓存整个备份；文件清单和SHA-256写在最后的 manifest.json 中
+// include_users=true 时同时导出管理员账号（密码为哈希）
 func (h *BackupHandler) ExportBackup(c *gin.Context) {
-	// 创建内存中的zip缓冲区
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
+	includeUsers := c.Query("include_users") == "true" || c.Query("include_users") == "1"
 
-	// 1. 导出nav.json数据
-	navData, err := h.getNavData()
+	// 1. 读取数据库内容（开始写响应之前出错可以正常返回错误信息）
+	entries, contents, err := utils.CollectBackup(h.DB, includeUsers)
 	if err != nil {
-		utils.InternalServerError(c, "获取导航数据失败: "+err.Error())
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	navJSON, err := json.MarshalIndent(navData, "", "  ")
-	if err != nil {
-		utils.InternalServerError(c, "序列化导航数据失败")
-		return
-	}
+	// 2. 设置响应头，触发下载（大小未知，不设置Content-Length）
+	timestamp := time.Now().Format("20060102_150405")
+	filename := fmt.Sprintf("nav_backup_%s.zip", timestamp)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
 
-	// 将nav.json写入zip
-	navFile, err := zipWriter.Create("nav.json")
-	if err != nil {
-		utils.InternalServerError(c, "创建nav.json失败")
+	// 3. 流式写入数据文件、上传文件和清单
+	if _, err := utils.WriteBackup(c.Writer, entries, contents); err != nil {
+		log.Printf("导出备份失败: %v", err)
+		abortStream(c)
 		return
 	}
-	navFile.Write(navJSON)
+}
 
-	// 2. 添加uploads目录下的文件
-	uploadPath := config.AppConfig.Upload.Path
-	err = h.addDirectoryToZip(zipWriter, uploadPath, "uploads")
-	if err != nil {
-		utils.InternalServerError(c, "添加上传文件失败: "+err.Error())
+// abortStream 响应已开始发送后出错时直接断开连接，
+// 让客户端得到不完整的下载（而不是一个看似正常但内容残缺的zip）
+func abortStream(c *gin.Context) {
+	c.Abort()
+	if conn, _, err := c.Writer.Hijack(); err == nil {
+		conn.Close()
 		return
 	}
+	// 不支持Hijack时（如HTTP/2），由net/http中止响应
+	panic(http.ErrAbortHandler)
+}
 
-	// 关闭zip writer
-	if err := zipWriter.Close(); err != nil {
-		utils.InternalServerError(c, "创建zip文件失败")
-		return
+// 备份恢复范围
+const (
+	restoreData     = "data"          // 仅数据：分类、站点、公告、上传文件
+	restoreSettings = "data_settings" // 数据 + 设置：页面配置、公告轮播间隔
+	restoreAll      = "all"           // 全部：数据、设置和管理员账号
+)
+
+// 上传导入备份时，表单解析保存在内存中的大小（超出的部分即上传的zip写入临时文件）
+// 和表单中除zip文件以外的内容（其他字段、分隔符）允许的大小
+const (
+	importFormMemory   = 1024 * 1024
+	importFormOverhead = 1024 * 1024
+)
+
+// formatSize 以 GB/MB/KB 中能整除的最大单位显示大小
+func formatSize(size int64) string {
+	for _, unit := range []struct {
+		name string
+		size int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if size >= unit.size && size%unit.size == 0 {
+			return fmt.Sprintf("%d%s", size/unit.size, unit.name)
+		}
 	}
+	return fmt.Sprintf("%d字节", size)
+}
 
-	// 设置响应头，触发下载
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("nav_backup_%s.zip", timestamp)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Length", fmt.Sprintf("%d", buf.Len()))
-	c.Data(200, "application/zip", buf.Bytes())
+// restoreMode 读取并检查恢复范围参数
+func restoreMode(c *gin.Context) (string, bool) {
+	mode := c.DefaultPostForm("mode", restoreSettings)
+	if mode != restoreData && mode != restoreSettings && mode != restoreAll {
+		utils.BadRequest(c, "无效的恢复范围，可选值: data, data_settings, all")
+		return "", false
+	}
+	return mode, true
 }
 
 // ImportBackup 从zip文件导入备份
+// mode 指定恢复范围：data、data_settings（默认）、all；strategy 指定导入策略：replace（默认）、merge
 func (h *BackupHandler) ImportBackup(c *gin.Context) {
+	// 限制请求体大小，并在读取表单字段之前解析表单：上传的zip由 multipart 写入临时文件，
+	// 请求结束后由 net/http 删除
+	maxSize := config.AppConfig.Backup.ImportMaxSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+importFormOverhead)
+	if err := c.Request.ParseMultipartForm(importFormMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.BadRequest(c, "文件大小超过限制（最大"+formatSize(maxSize)+"）")
+		} else {
+			utils.BadRequest(c, "未找到上传文件")
+		}
+		return
+	}
+
+	mode, ok := restoreMode(c)
+	if !ok {
+		return
+	}
+	opts, ok := importOptions(c)
+	if !ok {
+		return
+	}
+
 	file, err := c.FormFile("file")
 	if err != nil {
 		utils.BadRequest(c, "未找到上传文件")
 		return
 	}
 
-	// 检查文件大小（限制为50MB）
-	maxSize := int64(50 * 1024 * 1024)
+	// 检查文件大小
 	if file.Size > maxSize {
-		utils.BadRequest(c, "文件大小超过限制（最大50MB）")
+		utils.BadRequest(c, "文件大小超过限制（最大"+formatSize(maxSize)+"）")
 		return
 	}
 
@@ -116,7 +169,7 @@ func (h *BackupHandler) ImportBackup(c *gin.Context) {
 		return
 	}
 
-	// 打开上传的文件
+	// 打开上传的文件（multipart.File 支持随机读取，zip不需要读入内存）
 	src, err := file.Open()
 	if err != nil {
 		utils.InternalServerError(c, "打开上传文件失败")
@@ -124,55 +177,107 @@ func (h *BackupHandler) ImportBackup(c *gin.Context) {
 	}
 	defer src.Close()
 
-	// 读取文件内容到内存
-	fileBytes, err := io.ReadAll(src)
-	if err != nil {
-		utils.InternalServerError(c, "读取文件失败")
-		return
-	}
-
 	// 验证并解析zip文件
-	zipReader, err := zip.NewReader(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+	zipReader, err := zip.NewReader(src, file.Size)
 	if err != nil {
 		utils.BadRequest(c, "无效的zip文件格式")
 		return
 	}
 
+	h.restoreBackup(c, zipReader, mode, opts, uint64(config.AppConfig.Backup.ImportMaxFileSize))
+}
+
+// restoreBackup 校验并恢复zip备份，maxFileSize 为单个上传文件的大小限制（0表示不限制）
+func (h *BackupHandler) restoreBackup(c *gin.Context, zipReader *zip.Reader, mode string, opts utils.ImportOptions, maxFileSize uint64) {
+	withSettings := mode != restoreData
+
 	// 安全验证zip内容
-	if err := h.validateZipContent(zipReader); err != nil {
+	if err := h.validateZipContent(zipReader, maxFileSize); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	// 按清单校验文件完整性（在修改任何数据之前）
+	if _, err := utils.VerifyBackupFiles(zipReader); err != nil {
 		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// 查找并读取nav.json
-	var navData []map[string]interface{}
-	var hasNavJSON bool
-	for _, f := range zipReader.File {
-		if f.Name == "nav.json" {
-			hasNavJSON = true
-			rc, err := f.Open()
-			if err != nil {
-				utils.InternalServerError(c, "读取nav.json失败")
-				return
-			}
-			defer rc.Close()
+	var navJSON json.RawMessage
+	found, err := readBackupJSON(zipReader, utils.BackupNavFile, &navJSON)
+	if err != nil {
+		utils.BadRequest(c, "nav.json格式无效")
+		return
+	}
+	if !found {
+		utils.BadRequest(c, "zip文件中缺少nav.json")
+		return
+	}
 
-			if err := json.NewDecoder(rc).Decode(&navData); err != nil {
-				utils.BadRequest(c, "nav.json格式无效")
+	// 上传记录和用户（v2备份），旧版本备份中没有
+	var uploads []models.Upload
+	if _, err := readBackupJSON(zipReader, utils.BackupUploadsFile, &uploads); err != nil {
+		utils.BadRequest(c, "uploads.json格式无效")
+		return
+	}
+	var users []utils.BackupUser
+	hasUsers := false
+	if mode == restoreAll {
+		if hasUsers, err = readBackupJSON(zipReader, utils.BackupUsersFile, &users); err != nil {
+			utils.BadRequest(c, "users.json格式无效")
+			return
+		}
+	}
+
+	// 解码并校验nav.json内容
+	doc, errs, err := utils.ParseNavDocument(navJSON)
+	if err != nil {
+		utils.BadRequest(c, "nav.json格式无效: "+err.Error())
+		return
+	}
+	if errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+	if doc.IsEmpty() {
+		utils.BadRequest(c, "nav.json数据为空")
+		return
+	}
+	var pageConfig *models.PageConfig
+	if withSettings {
+		// 页面配置（v1备份中没有）
+		if pageConfig = pageConfigFromDoc(doc); pageConfig != nil {
+			if errs := utils.ValidatePageConfig(pageConfig); errs.HasErrors() {
+				utils.ValidationFailed(c, errs)
 				return
 			}
-			break
+			pageConfig.FooterText = utils.SanitizeRichText(pageConfig.FooterText, pageConfig.FooterFormat)
+		}
+	}
+	for _, user := range users {
+		if user.Username == "" || !models.IsPasswordHash(user.PasswordHash) {
+			utils.BadRequest(c, "users.json中的用户名或密码哈希无效")
+			return
 		}
 	}
 
-	if !hasNavJSON {
-		utils.BadRequest(c, "zip文件中缺少nav.json")
+	// 预览模式：只返回恢复后的变化，不修改数据
+	if c.Query("dry_run") == "true" || c.Query("dry_run") == "1" {
+		diff, err := h.diffBackup(zipReader, doc, opts, withSettings, pageConfig, hasUsers, users)
+		if err != nil {
+			utils.InternalServerError(c, "生成导入预览失败")
+			return
+		}
+		utils.SuccessWithMessage(c, "导入预览（未修改数据）", diff)
 		return
 	}
 
-	// 验证nav.json内容格式
-	if err := h.validateNavJSON(navData); err != nil {
-		utils.BadRequest(c, err.Error())
+	// 先把上传文件写入存储，再在事务中恢复引用这些文件的数据和上传记录
+	// 写入失败时中止导入、不修改数据库；已写入的文件没有被引用，由上传文件清理回收
+	if err := h.extractUploadsFromZip(zipReader); err != nil {
+		log.Printf("解压上传文件失败: %v", err)
+		utils.InternalServerError(c, "解压上传文件失败: "+err.Error())
 		return
 	}
 
@@ -184,24 +289,57 @@ func (h *BackupHandler) ImportBackup(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// 清空现有数据
-	if _, err := tx.Exec("DELETE FROM sites"); err != nil {
-		utils.InternalServerError(c, "清空站点失败")
-		return
-	}
-	if _, err := tx.Exec("DELETE FROM categories"); err != nil {
-		utils.InternalServerError(c, "清空分类失败")
+	// 导入nav.json数据：合并模式按分类 _id 和站点链接更新已有数据，否则整体替换
+	var merged *mergeResult
+	if opts.Merge {
+		if merged, err = mergeNavData(tx, doc, opts.KeepUnmatched, withSettings); err != nil {
+			utils.InternalServerError(c, "合并数据失败: "+err.Error())
+			return
+		}
+	} else if err := replaceNavData(tx, doc, withSettings); err != nil {
+		utils.InternalServerError(c, "导入数据失败: "+err.Error())
 		return
 	}
-	if _, err := tx.Exec("DELETE FROM announcements"); err != nil {
-		utils.InternalServerError(c, "清空公告失败")
+	restored := []string{utils.BackupContentData}
+
+	// 恢复备份中包含文件的上传记录（下载权限、原始文件名、下载次数）
+	if err := h.restoreUploads(tx, zipReader, uploads); err != nil {
+		utils.InternalServerError(c, "恢复上传记录失败: "+err.Error())
 		return
 	}
 
-	// 导入nav.json数据
-	if err := h.importNavData(tx, navData); err != nil {
-		utils.InternalServerError(c, "导入数据失败: "+err.Error())
-		return
+	if withSettings {
+		if pageConfig != nil {
+			if err := models.UpdatePageConfig(tx, pageConfig); err != nil {
+				utils.InternalServerError(c, "恢复页面配置失败")
+				return
+			}
+		}
+		restored = append(restored, utils.BackupContentSettings)
+	}
+
+	loggedOut := false
+	if hasUsers {
+		for _, user := range users {
+			if err := models.RestoreUser(tx, &models.User{
+				Username:  user.Username,
+				Password:  user.PasswordHash,
+				CreatedAt: user.CreatedAt,
+				UpdatedAt: user.UpdatedAt,
+			}); err != nil {
+				utils.InternalServerError(c, "恢复用户失败")
+				return
+			}
+			// 密码可能已改变，与修改密码一样使该用户的所有会话失效
+			if err := models.DeleteUserLoginSessions(tx, user.Username); err != nil {
+				utils.InternalServerError(c, "清除登录会话失败")
+				return
+			}
+			if user.Username == middleware.CurrentUsername(c) {
+				loggedOut = true
+			}
+		}
+		restored = append(restored, utils.BackupContentUsers)
 	}
 
 	// 提交数据库事务
@@ -209,90 +347,201 @@ func (h *BackupHandler) ImportBackup(c *gin.Context) {
 		utils.InternalServerError(c, "提交事务失败")
 		return
 	}
-
-	// 解压uploads目录下的文件（在数据库事务成功后）
-	if err := h.extractUploadsFromZip(zipReader); err != nil {
-		// 记录错误但不阻止整个导入
-		fmt.Printf("解压上传文件时出错: %v\n", err)
+	if loggedOut {
+		c.SetCookie("session", "", -1, "/", "", false, true)
 	}
 
-	utils.SuccessWithMessage(c, "备份导入成功", nil)
+	response := gin.H{"mode": mode, "restored": restored}
+	if merged != nil {
+		response["merged"] = merged
+	}
+	message := "备份导入成功"
+	if loggedOut {
+		message = "备份导入成功，当前用户已从备份恢复，请重新登录"
+	}
+	utils.SuccessWithMessage(c, message, response)
 
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
 }
 
-// getNavData 获取完整的导航数据
-func (h *BackupHandler) getNavData() ([]interface{}, error) {
-	announcementConfig, err := models.GetAnnouncementConfig(h.DB)
+// diffBackup 生成备份恢复预览：数据、设置（withSettings时）、上传文件和用户（hasUsers时）的变化
+func (h *BackupHandler) diffBackup(zipReader *zip.Reader, doc *navdoc.Document, opts utils.ImportOptions,
+	withSettings bool, pageConfig *models.PageConfig, hasUsers bool, users []utils.BackupUser) (*utils.ImportDiff, error) {
+	diff, err := utils.DiffNavDocument(h.DB, doc, opts)
 	if err != nil {
 		return nil, err
 	}
+	if withSettings {
+		if diff.Settings, err = utils.DiffSettings(h.DB, doc, pageConfig); err != nil {
+			return nil, err
+		}
+	}
+	if diff.Uploads, err = utils.DiffBackupUploads(zipReader); err != nil {
+		return nil, err
+	}
+	if hasUsers {
+		if diff.Users, err = utils.DiffBackupUsers(h.DB, users); err != nil {
+			return nil, err
+		}
+	}
+	return diff, nil
+}
 
-	categories, err := models.GetAllCategories(h.DB)
+// ListStoredBackups 列出服务器上保存的备份（定时备份和手动创建的备份）
+func (h *BackupHandler) ListStoredBackups(c *gin.Context) {
+	backups, err := utils.ListStoredBackups()
 	if err != nil {
-		return nil, err
+		utils.InternalServerError(c, "读取备份列表失败")
+		return
 	}
+	utils.Success(c, backups)
+}
 
-	for i := range categories {
-		sites, err := models.GetSitesByCategoryID(h.DB, categories[i].ID)
-		if err != nil {
-			continue
-		}
-		categories[i].Sites = sites
+// CreateStoredBackup 立即创建一份备份保存到服务器，并按保留策略清理旧备份
+func (h *BackupHandler) CreateStoredBackup(c *gin.Context) {
+	backup, err := utils.CreateStoredBackup(h.DB)
+	if err != nil {
+		log.Printf("创建备份失败: %v", err)
+		utils.InternalServerError(c, "创建备份失败: "+err.Error())
+		return
 	}
 
-	result := []interface{}{announcementConfig}
-	for _, cat := range categories {
-		result = append(result, cat)
+	if _, err := utils.PruneStoredBackups(); err != nil {
+		log.Printf("清理旧备份失败: %v", err)
 	}
 
-	return result, nil
+	utils.SuccessWithMessage(c, "备份已创建", backup)
 }
 
-// addDirectoryToZip 将目录添加到zip文件
-func (h *BackupHandler) addDirectoryToZip(zipWriter *zip.Writer, srcPath string, destPath string) error {
-	return filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // 忽略不存在的目录
-		}
+// storedBackupPath 根据路由参数获取备份文件路径，失败时已写入响应
+func storedBackupPath(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	p, err := utils.StoredBackupPath(name)
+	if err == utils.ErrInvalidBackupName {
+		utils.BadRequest(c, err.Error())
+		return "", false
+	}
+	if err != nil {
+		utils.NotFound(c, "备份不存在")
+		return "", false
+	}
+	return p, true
+}
 
-		// 跳过目录本身
-		if info.IsDir() {
-			return nil
-		}
+// DownloadStoredBackup 下载服务器上保存的备份
+func (h *BackupHandler) DownloadStoredBackup(c *gin.Context) {
+	p, ok := storedBackupPath(c)
+	if !ok {
+		return
+	}
+	c.FileAttachment(p, c.Param("name"))
+}
 
-		// 计算相对路径
-		relPath, err := filepath.Rel(srcPath, path)
-		if err != nil {
-			return nil
-		}
+// RestoreStoredBackup 从服务器上保存的备份恢复，mode 和 strategy 与导入备份相同
+func (h *BackupHandler) RestoreStoredBackup(c *gin.Context) {
+	mode, ok := restoreMode(c)
+	if !ok {
+		return
+	}
+	opts, ok := importOptions(c)
+	if !ok {
+		return
+	}
+	p, ok := storedBackupPath(c)
+	if !ok {
+		return
+	}
+
+	zipFile, err := zip.OpenReader(p)
+	if err != nil {
+		utils.InternalServerError(c, "打开备份文件失败")
+		return
+	}
+	defer zipFile.Close()
+
+	// 服务器生成的备份不限制单个文件大小（可能包含分片上传的大文件）
+	h.restoreBackup(c, &zipFile.Reader, mode, opts, 0)
+}
 
-		// 创建zip中的路径
-		zipPath := filepath.Join(destPath, relPath)
-		zipPath = filepath.ToSlash(zipPath) // 统一使用正斜杠
+// DeleteStoredBackup 删除服务器上保存的备份
+func (h *BackupHandler) DeleteStoredBackup(c *gin.Context) {
+	if _, ok := storedBackupPath(c); !ok {
+		return
+	}
+	if err := utils.DeleteStoredBackup(c.Param("name")); err != nil {
+		utils.InternalServerError(c, "删除备份失败")
+		return
+	}
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
 
-		// 打开源文件
-		srcFile, err := os.Open(path)
+// readBackupJSON 读取并解析zip中的JSON文件，文件不存在时返回 false
+func readBackupJSON(zipReader *zip.Reader, name string, v interface{}) (bool, error) {
+	for _, f := range zipReader.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
 		if err != nil {
-			return nil // 忽略无法打开的文件
+			return true, err
 		}
-		defer srcFile.Close()
+		defer rc.Close()
+		return true, json.NewDecoder(rc).Decode(v)
+	}
+	return false, nil
+}
 
-		// 创建zip中的文件
-		writer, err := zipWriter.Create(zipPath)
-		if err != nil {
-			return nil
+// pageConfigFromDoc 取出nav.json中的页面配置，不存在时返回nil（v1备份）
+func pageConfigFromDoc(doc *navdoc.Document) *models.PageConfig {
+	if doc.PageConfig == nil {
+		return nil
+	}
+	return &models.PageConfig{
+		Title:        doc.PageConfig.Title,
+		Subtitle:     doc.PageConfig.Subtitle,
+		Logo:         doc.PageConfig.Logo,
+		FooterText:   doc.PageConfig.FooterText,
+		ICP:          doc.PageConfig.ICP,
+		FooterFormat: doc.PageConfig.FooterFormat,
+	}
+}
+
+// restoreUploads 恢复上传记录，只恢复文件在备份中的记录
+func (h *BackupHandler) restoreUploads(tx *sql.Tx, zipReader *zip.Reader, uploads []models.Upload) error {
+	inZip := make(map[string]bool, len(zipReader.File))
+	for _, f := range zipReader.File {
+		if strings.HasPrefix(f.Name, "uploads/") {
+			inZip["/"+f.Name] = true
 		}
+	}
 
-		// 复制文件内容
-		_, err = io.Copy(writer, srcFile)
-		return err
-	})
+	now := time.Now().Format("2006-01-02 15:04:05")
+	for i := range uploads {
+		upload := &uploads[i]
+		if !inZip[upload.Path] {
+			continue
+		}
+		switch upload.Visibility {
+		case models.VisibilityPublic, models.VisibilityLogin, models.VisibilitySigned:
+		default:
+			upload.Visibility = models.VisibilityPublic
+		}
+		if upload.CreatedAt == "" {
+			upload.CreatedAt = now
+		}
+		if err := models.RestoreUpload(tx, upload); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// validateZipContent 验证zip文件内容的安全性
-func (h *BackupHandler) validateZipContent(zipReader *zip.Reader) error {
+// validateZipContent 验证zip文件内容的安全性，maxFileSize 为单个上传文件的大小限制（0表示不限制）
+func (h *BackupHandler) validateZipContent(zipReader *zip.Reader, maxFileSize uint64) error {
 	hasNavJSON := false
 
 	for _, f := range zipReader.File {
@@ -324,6 +573,19 @@ func (h *BackupHandler) validateZipContent(zipReader *zip.Reader) error {
 			continue
 		}
 
+		// 数据库快照（定时备份中包含，仅用于手动灾难恢复，导入时忽略）
+		if name == utils.BackupDatabaseFile {
+			continue
+		}
+
+		// 备份清单和数据文件
+		if name == utils.BackupManifestName || name == utils.BackupUploadsFile || name == utils.BackupUsersFile {
+			if f.UncompressedSize64 > 10*1024*1024 {
+				return fmt.Errorf("%s文件过大", name)
+			}
+			continue
+		}
+
 		// 检查uploads目录下的文件
 		if strings.HasPrefix(name, "uploads/") {
 			// 必须在logos或files子目录下
@@ -337,8 +599,8 @@ func (h *BackupHandler) validateZipContent(zipReader *zip.Reader) error {
 				return fmt.Errorf("不允许的文件类型: %s", ext)
 			}
 
-			// 检查单个文件大小（限制为10MB）
-			if f.UncompressedSize64 > 10*1024*1024 {
+			// 检查单个文件大小
+			if maxFileSize > 0 && f.UncompressedSize64 > maxFileSize {
 				return fmt.Errorf("文件过大: %s", name)
 			}
 
@@ -369,153 +631,8 @@ func isValidFilename(name string) bool {
 	return validPattern.MatchString(name)
 }
 
-// validateNavJSON 验证nav.json的内容格式
-func (h *BackupHandler) validateNavJSON(data []map[string]interface{}) error {
-	if len(data) == 0 {
-		return fmt.Errorf("nav.json数据为空")
-	}
-
-	for _, item := range data {
-		// 检查公告配置
-		if typeVal, ok := item["type"].(string); ok && typeVal == "announcement_config" {
-			// 验证公告配置结构
-			if _, ok := item["_id"].(string); !ok {
-				return fmt.Errorf("公告配置缺少_id字段")
-			}
-			continue
-		}
-
-		// 检查分类结构
-		if _, ok := item["_id"].(string); !ok {
-			return fmt.Errorf("分类缺少_id字段")
-		}
-		if _, ok := item["classify"].(string); !ok {
-			return fmt.Errorf("分类缺少classify字段")
-		}
-
-		// 检查站点数组
-		if sites, ok := item["sites"].([]interface{}); ok {
-			for i, site := range sites {
-				siteMap, ok := site.(map[string]interface{})
-				if !ok {
-					return fmt.Errorf("第%d个站点格式错误", i+1)
-				}
-				if _, ok := siteMap["name"].(string); !ok {
-					return fmt.Errorf("站点缺少name字段")
-				}
-				if _, ok := siteMap["href"].(string); !ok {
-					return fmt.Errorf("站点缺少href字段")
-				}
-
-				// 验证logo路径安全性
-				if logo, ok := siteMap["logo"].(string); ok && logo != "" {
-					if strings.Contains(logo, "..") {
-						return fmt.Errorf("检测到非法logo路径")
-					}
-				}
-
-				// 验证href路径安全性（如果是本地文件）
-				if href, ok := siteMap["href"].(string); ok {
-					if strings.HasPrefix(href, "/uploads/") && strings.Contains(href, "..") {
-						return fmt.Errorf("检测到非法href路径")
-					}
-				}
-			}
-		}
-	}
-
-	return nil
-}
-
-// importNavData 导入nav.json数据到数据库
-func (h *BackupHandler) importNavData(tx *sql.Tx, data []map[string]interface{}) error {
-	sortNo := 0
-	for _, item := range data {
-		// 检查是否是公告配置
-		if typeVal, ok := item["type"].(string); ok && typeVal == "announcement_config" {
-			// 处理公告配置
-			if interval, ok := item["interval"].(float64); ok {
-				if err := models.UpdateAnnouncementInterval(tx, int(interval)); err != nil {
-					return fmt.Errorf("更新公告配置失败: %v", err)
-				}
-			}
-
-			// 处理公告列表
-			if announcements, ok := item["announcements"].([]interface{}); ok {
-				for _, annItem := range announcements {
-					if annMap, ok := annItem.(map[string]interface{}); ok {
-						timestamp, _ := annMap["timestamp"].(string)
-						content, _ := annMap["content"].(string)
-						ann := &models.Announcement{
-							Timestamp: timestamp,
-							Content:   content,
-						}
-						if _, err := models.CreateAnnouncement(tx, ann); err != nil {
-							return fmt.Errorf("创建公告失败: %v", err)
-						}
-					}
-				}
-			}
-			continue
-		}
-
-		// 处理普通分类
-		idStr, _ := item["_id"].(string)
-		classify, _ := item["classify"].(string)
-		icon, _ := item["icon"].(string)
-
-		cat := &models.Category{
-			IDStr:    idStr,
-			Classify: classify,
-			Icon:     icon,
-			SortNo:   sortNo,
-		}
-
-		catID, err := models.CreateCategory(tx, cat)
-		if err != nil {
-			return fmt.Errorf("创建分类失败: %v", err)
-		}
-		sortNo++
-
-		// 处理站点
-		if sites, ok := item["sites"].([]interface{}); ok {
-			siteSortNo := 0
-			for _, siteItem := range sites {
-				if siteMap, ok := siteItem.(map[string]interface{}); ok {
-					name, _ := siteMap["name"].(string)
-					href, _ := siteMap["href"].(string)
-					desc, _ := siteMap["desc"].(string)
-					logo, _ := siteMap["logo"].(string)
-
-					site := &models.Site{
-						CatID:  int(catID),
-						Name:   name,
-						Href:   href,
-						Desc:   desc,
-						Logo:   logo,
-						SortNo: siteSortNo,
-					}
-
-					if _, err := models.CreateSite(tx, site); err != nil {
-						return fmt.Errorf("创建站点失败: %v", err)
-					}
-					siteSortNo++
-				}
-			}
-		}
-	}
-
-	return nil
-}
-
-// extractUploadsFromZip 从zip中解压uploads目录的文件
+// extractUploadsFromZip 从zip中解压uploads目录的文件到存储
 func (h *BackupHandler) extractUploadsFromZip(zipReader *zip.Reader) error {
-	uploadPath := config.AppConfig.Upload.Path
-
-	// 确保logos和files目录存在
-	os.MkdirAll(filepath.Join(uploadPath, "logos"), 0755)
-	os.MkdirAll(filepath.Join(uploadPath, "files"), 0755)
-
 	for _, f := range zipReader.File {
 		// 只处理uploads目录下的文件
 		if !strings.HasPrefix(f.Name, "uploads/") {
@@ -527,41 +644,22 @@ func (h *BackupHandler) extractUploadsFromZip(zipReader *zip.Reader) error {
 			continue
 		}
 
-		// 计算目标路径
-		relPath := strings.TrimPrefix(f.Name, "uploads/")
-		destPath := filepath.Join(uploadPath, relPath)
-
 		// 再次验证路径安全性
-		cleanPath := filepath.Clean(destPath)
-		absUploadPath, _ := filepath.Abs(uploadPath)
-		absDestPath, _ := filepath.Abs(cleanPath)
-		if !strings.HasPrefix(absDestPath, absUploadPath) {
-			continue // 跳过可能的路径穿越
-		}
-
-		// 确保父目录存在
-		os.MkdirAll(filepath.Dir(destPath), 0755)
-
-		// 打开zip中的文件
-		rc, err := f.Open()
+		key, err := storage.CleanKey(strings.TrimPrefix(f.Name, "uploads/"))
 		if err != nil {
-			continue
+			return fmt.Errorf("%s: %w", f.Name, err)
 		}
 
-		// 创建目标文件
-		destFile, err := os.Create(destPath)
+		// 打开zip中的文件，直接写入存储（大小已在 validateZipContent 中检查）
+		rc, err := f.Open()
 		if err != nil {
-			rc.Close()
-			continue
+			return fmt.Errorf("%s: %w", f.Name, err)
 		}
 
-		// 复制内容（限制大小）
-		_, err = io.CopyN(destFile, rc, 10*1024*1024) // 限制10MB
-		destFile.Close()
+		err = storage.Default.Put(key, rc, int64(f.UncompressedSize64), storage.ContentType(key))
 		rc.Close()
-
-		if err != nil && err != io.EOF {
-			os.Remove(destPath) // 清理失败的文件
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
 		}
 	}
 
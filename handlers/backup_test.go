@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"nav-admin/models"
+	"nav-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRestoreUsersInvalidatesSessions(t *testing.T) {
+	db := newMergeTestDB(t)
+	mergeTestDoc(t, db, parseTestDoc(t, `[
+		{"_id": "a", "classify": "A", "icon": "", "sites": [
+			{"name": "Restored", "href": "https://restored.example.com", "desc": "", "logo": ""}
+		]}
+	]`), false)
+
+	entries, contents, err := utils.CollectBackup(db, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := utils.WriteBackup(&buf, entries, contents); err != nil {
+		t.Fatal(err)
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 备份之后修改了密码并登录
+	if err := models.UpdatePassword(db, "admin", "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := models.CreateLoginSession(db, "admin", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/backup/import", nil)
+	c.Set("username", "admin")
+	h := &BackupHandler{DB: db}
+	h.restoreBackup(c, zipReader, restoreAll, utils.ImportOptions{}, 0)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	waitNavJSON(t, "Restored")
+
+	if _, err := models.GetLoginSessionUser(db, token); err != sql.ErrNoRows {
+		t.Errorf("session after restore: %v, want sql.ErrNoRows", err)
+	}
+	user, err := models.GetUserByUsername(db, "admin")
+	if err != nil || !user.VerifyPassword("admin") {
+		t.Errorf("password not restored: %v", err)
+	}
+	if cookie := w.Header().Get("Set-Cookie"); !strings.HasPrefix(cookie, "session=;") {
+		t.Errorf("Set-Cookie = %q, want session cleared", cookie)
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"nav-admin/config"
+	"nav-admin/middleware"
+	"nav-admin/models"
+	"nav-admin/navdoc"
+	"nav-admin/storage"
+	"nav-admin/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBookmarkFileSize 书签文件的大小限制（包含内嵌图标的书签文件可能较大）
+const maxBookmarkFileSize = 20 * 1024 * 1024
+
+// bookmarkCategoryIcon 书签分类使用的图标
+const bookmarkCategoryIcon = "ti-bookmark"
+
+// savedIcon 已保存的书签图标
+type savedIcon struct {
+	upload *models.Upload
+	err    error
+}
+
+// ImportBookmarks 导入浏览器导出的书签文件（Netscape HTML 格式）
+// 每个包含书签的文件夹导入为一个分类，书签导入为站点，内嵌的 ICON 图标保存到 uploads/logos
+// mode=append（默认）按分类 _id 和站点链接合并到现有数据；mode=replace 清空现有分类和站点后导入
+func (h *NavHandler) ImportBookmarks(c *gin.Context) {
+	mode, ok := externalImportMode(c)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "未找到上传文件")
+		return
+	}
+	if file.Size > maxBookmarkFileSize {
+		utils.BadRequest(c, "书签文件过大（最大20MB）")
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+	defer src.Close()
+
+	folders, err := utils.ParseNetscapeBookmarks(src)
+	if err != nil {
+		utils.BadRequest(c, "解析书签文件失败: "+err.Error())
+		return
+	}
+
+	result := newExternalImportResult(mode)
+
+	// 书签转换为分类和站点，不能作为站点的书签（书签脚本、浏览器内部页面等）跳过
+	doc := &navdoc.Document{Version: navdoc.Version}
+	icons := map[string]*savedIcon{} // data: URI -> 保存结果（相同图标只处理一次）
+	for _, folder := range folders {
+		cat := bookmarkCategory(folder.Path)
+		folderName := strings.Join(folder.Path, " / ")
+		for _, b := range folder.Bookmarks {
+			site := &models.Site{Name: b.Title, Href: b.Href, Desc: b.Description}
+			if reason := normalizeImportedSite(site); reason != "" {
+				result.skip(folderName, b.Title, b.Href, reason)
+				continue
+			}
+
+			if b.Icon != "" {
+				icon, ok := icons[b.Icon]
+				if !ok {
+					icon = &savedIcon{}
+					icon.upload, icon.err = saveBookmarkIcon(b.Icon, site.Href, middleware.CurrentUsername(c))
+					if icon.err != nil {
+						log.Printf("保存书签图标失败 %s: %v", site.Href, icon.err)
+					}
+					icons[b.Icon] = icon
+				}
+				if icon.err == nil {
+					site.Logo = icon.upload.Path
+				}
+			}
+
+			cat.Sites = append(cat.Sites, navdoc.Site{Name: site.Name, Href: site.Href, Desc: site.Desc, Logo: site.Logo})
+		}
+		if len(cat.Sites) > 0 {
+			doc.Categories = append(doc.Categories, cat)
+		}
+	}
+	if len(doc.Categories) == 0 {
+		utils.BadRequest(c, "书签文件中没有可以导入的书签")
+		return
+	}
+	if errs := utils.ValidateNavDocument(doc); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	// 登记图标的上传记录
+	for _, icon := range icons {
+		if icon.err != nil {
+			continue
+		}
+		if err := models.CreateUpload(tx, icon.upload); err != nil {
+			utils.InternalServerError(c, "登记书签图标失败")
+			return
+		}
+		result.Icons++
+	}
+
+	if result.Merged, err = writeExternalCategories(tx, doc, mode); err != nil {
+		utils.InternalServerError(c, "导入书签失败: "+err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	result.count(doc)
+	utils.SuccessWithMessage(c, "书签导入成功", result)
+
+	// 异步更新nav.json
+	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
+}
+
+// bookmarkCategory 由书签文件夹生成分类：名称为最后一级文件夹名，_id 由完整路径生成（同一文件夹重复导入时 _id 相同）
+// 根目录下的书签放入“书签”分类
+func bookmarkCategory(path []string) navdoc.Category {
+	cat := navdoc.Category{ID: "bookmarks", Classify: "书签", Sites: []navdoc.Site{}}
+	if len(path) > 0 {
+		sum := sha1.Sum([]byte(strings.Join(path, "\x00")))
+		cat.ID = "bm-" + hex.EncodeToString(sum[:])[:12]
+		cat.Classify = truncateRunes(path[len(path)-1], utils.MaxClassifyLength)
+		if cat.Classify == "" {
+			cat.Classify = "未命名文件夹"
+		}
+	}
+	if utils.IsAllowedIcon(bookmarkCategoryIcon) {
+		cat.Icon = bookmarkCategoryIcon
+	}
+	return cat
+}
+
+// saveBookmarkIcon 保存书签内嵌的 data: URI 图标到 uploads/logos（与上传图标的处理相同），返回待登记的上传记录
+func saveBookmarkIcon(dataURI, href, uploader string) (*models.Upload, error) {
+	ext, data, err := utils.DecodeImageDataURI(dataURI, config.AppConfig.Upload.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.CheckFileContent(ext, data); err != nil {
+		return nil, err
+	}
+	if data, _, err = processUploadedImage("logo", ext, data); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	key := "logos/" + hash + ext
+	mimeType := detectMimeType(ext, data)
+
+	deduplicated, err := saveUploadObject(key, bytes.NewReader(data), int64(len(data)), mimeType)
+	if err != nil {
+		return nil, err
+	}
+	if !deduplicated && utils.IsImageExt(ext) {
+		if _, err := utils.GenerateThumbnails(key, data, config.AppConfig.Upload.ThumbnailSizes); err != nil {
+			log.Printf("生成缩略图失败 %s: %v", key, err)
+		}
+	}
+
+	return &models.Upload{
+		Hash:         hash,
+		Path:         storage.URLFromKey(key),
+		OriginalName: hrefHost(href) + ext,
+		Mime:         mimeType,
+		Size:         int64(len(data)),
+		Uploader:     uploader,
+	}, nil
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
 	"nav-admin/models"
 	"nav-admin/utils"
 	"strconv"
@@ -13,7 +14,7 @@ type CategoryHandler struct {
 	DB *sql.DB
 }
 
-// GetAll 获取所有分类
+// GetAll 获取所有分类（按层级深度优先排列，带 parent_id 和 depth），tree=true 时返回嵌套的树
 func (h *CategoryHandler) GetAll(c *gin.Context) {
 	categories, err := models.GetAllCategories(h.DB)
 	if err != nil {
@@ -21,6 +22,10 @@ func (h *CategoryHandler) GetAll(c *gin.Context) {
 		return
 	}
 
+	if tree := c.Query("tree"); tree == "true" || tree == "1" {
+		utils.Success(c, models.CategoryTree(categories))
+		return
+	}
 	utils.Success(c, categories)
 }
 
@@ -59,6 +64,11 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if errs := utils.ValidateCategory(&cat); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
 	// 使用事务
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -67,6 +77,14 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
+	if errs, err := checkCategoryParent(tx, 0, cat.ParentID); err != nil {
+		utils.InternalServerError(c, "查询分类失败")
+		return
+	} else if errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
 	id, err := models.CreateCategory(tx, &cat)
 	if err != nil {
 		utils.InternalServerError(c, "创建失败")
@@ -85,7 +103,7 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 	go utils.GenerateNavJSON(h.DB)
 }
 
-// Update 更新分类
+// Update 更新分类，请求中有 parent_id 时同时修改上级分类（移动到新的上级分类下的最后）
 func (h *CategoryHandler) Update(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -93,11 +111,26 @@ func (h *CategoryHandler) Update(c *gin.Context) {
 		return
 	}
 
-	var cat models.Category
-	if err := c.ShouldBindJSON(&cat); err != nil {
+	var req struct {
+		models.Category
+		ParentID *int `json:"parent_id"` // 为 nil 时不修改上级分类
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "请求格式错误")
 		return
 	}
+	cat := req.Category
+
+	if errs := utils.ValidateCategory(&cat); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	current, err := models.GetCategoryByID(h.DB, id)
+	if err != nil {
+		utils.NotFound(c, "分类不存在")
+		return
+	}
 
 	// 使用事务
 	tx, err := h.DB.Begin()
@@ -113,6 +146,20 @@ func (h *CategoryHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if req.ParentID != nil && *req.ParentID != current.ParentID {
+		if errs, err := checkCategoryParent(tx, id, *req.ParentID); err != nil {
+			utils.InternalServerError(c, "查询分类失败")
+			return
+		} else if errs.HasErrors() {
+			utils.ValidationFailed(c, errs)
+			return
+		}
+		if err := models.MoveCategory(tx, id, *req.ParentID); err != nil {
+			utils.InternalServerError(c, "修改上级分类失败")
+			return
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		utils.InternalServerError(c, "提交事务失败")
 		return
@@ -124,13 +171,25 @@ func (h *CategoryHandler) Update(c *gin.Context) {
 	go utils.GenerateNavJSON(h.DB)
 }
 
-// Delete 删除分类
+// 删除有子分类的分类时子分类的处理方式
+const (
+	deleteChildren  = "delete"  // 连同所有下级分类及其站点一起删除
+	promoteChildren = "promote" // 子分类移到被删除分类的上级分类下
+)
+
+// Delete 删除分类（连同其中的站点）
+// 分类有子分类时必须用 children=delete 或 children=promote 指定子分类的处理方式，否则返回400
 func (h *CategoryHandler) Delete(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		utils.BadRequest(c, "无效的ID")
 		return
 	}
+	mode := c.Query("children")
+	if mode != "" && mode != deleteChildren && mode != promoteChildren {
+		utils.BadRequest(c, "无效的子分类处理方式，可选值: delete, promote")
+		return
+	}
 
 	// 使用事务
 	tx, err := h.DB.Begin()
@@ -140,7 +199,26 @@ func (h *CategoryHandler) Delete(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	err = models.DeleteCategory(tx, id)
+	var exists int
+	if err := tx.QueryRow("SELECT 1 FROM categories WHERE id = ?", id).Scan(&exists); err != nil {
+		utils.NotFound(c, "分类不存在")
+		return
+	}
+	descendants, err := models.GetCategoryDescendantIDs(tx, id)
+	if err != nil {
+		utils.InternalServerError(c, "查询子分类失败")
+		return
+	}
+	if len(descendants) > 0 && mode == "" {
+		utils.BadRequest(c, fmt.Sprintf("分类下有%d个子分类，请指定 children=delete（一并删除）或 children=promote（移到上一级）", len(descendants)))
+		return
+	}
+
+	if mode == deleteChildren {
+		_, err = models.DeleteCategoryTree(tx, id)
+	} else {
+		err = models.DeleteCategory(tx, id)
+	}
 	if err != nil {
 		utils.InternalServerError(c, "删除失败")
 		return
@@ -155,6 +233,9 @@ func (h *CategoryHandler) Delete(c *gin.Context) {
 
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
 }
 
 // UpdateSort 更新分类排序
@@ -213,14 +294,22 @@ func (h *CategoryHandler) UpdateSort(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// 安全验证4: 验证所有ID都存在于数据库中
+	// 安全验证4: 验证所有ID都存在于数据库中，并且属于同一上级分类
+	expectedParentID := -1
 	for _, id := range ids {
-		var exists int
-		err := tx.QueryRow("SELECT 1 FROM categories WHERE id = ?", id).Scan(&exists)
+		var parentID int
+		err := tx.QueryRow("SELECT parent_id FROM categories WHERE id = ?", id).Scan(&parentID)
 		if err != nil {
 			utils.BadRequest(c, "分类ID不存在: "+strconv.Itoa(id))
 			return
 		}
+		if expectedParentID == -1 {
+			expectedParentID = parentID
+		}
+		if parentID != expectedParentID {
+			utils.BadRequest(c, "不允许跨层级修改分类排序")
+			return
+		}
 	}
 
 	// 执行更新
@@ -248,3 +337,56 @@ func (h *CategoryHandler) UpdateSort(c *gin.Context) {
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
 }
+
+// checkCategoryParent 检查分类能否放到 parentID 下（parentID 为0表示顶级，id 为0表示新建的分类）：
+// 上级分类必须存在，不能是分类自己或它的下级分类，移动后的层级不能超过 utils.MaxCategoryDepth
+func checkCategoryParent(tx *sql.Tx, id, parentID int) (utils.ValidationErrors, error) {
+	var errs utils.ValidationErrors
+	if parentID == 0 {
+		return errs, nil
+	}
+	if parentID < 0 {
+		errs.Add("parent_id", "无效的上级分类ID")
+		return errs, nil
+	}
+	if parentID == id {
+		errs.Add("parent_id", "上级分类不能是自己")
+		return errs, nil
+	}
+
+	categories, err := models.GetAllCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+	parentPos, pos := -1, -1
+	for i, cat := range categories {
+		switch cat.ID {
+		case parentID:
+			parentPos = i
+		case id:
+			pos = i
+		}
+	}
+	if parentPos == -1 {
+		errs.Add("parent_id", "上级分类不存在")
+		return errs, nil
+	}
+
+	// 分类及其下级分类占用的层数（深度优先顺序中紧跟在分类后面、层级更深的是它的下级分类）
+	levels := 1
+	if pos != -1 {
+		for i := pos + 1; i < len(categories) && categories[i].Depth > categories[pos].Depth; i++ {
+			if i == parentPos {
+				errs.Add("parent_id", "不能移动到自己的下级分类中")
+				return errs, nil
+			}
+			if n := categories[i].Depth - categories[pos].Depth + 1; n > levels {
+				levels = n
+			}
+		}
+	}
+	if categories[parentPos].Depth+1+levels > utils.MaxCategoryDepth {
+		errs.Add("parent_id", fmt.Sprintf("分类层级不能超过%d级", utils.MaxCategoryDepth))
+	}
+	return errs, nil
+}
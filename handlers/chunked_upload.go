@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"nav-admin/config"
+	"nav-admin/middleware"
+	"nav-admin/models"
+	"nav-admin/storage"
+	"nav-admin/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 分片上传流程：
+//  1. POST   /api/admin/upload/chunked               创建会话，返回 upload_id 和 chunk_size
+//  2. PUT    /api/admin/upload/chunked/:id/chunks/:n 上传第 n 个分片（从0开始，请求体为分片内容）
+//  3. GET    /api/admin/upload/chunked/:id           查询已收到的分片，用于断点续传
+//  4. POST   /api/admin/upload/chunked/:id/complete  合并分片并校验，保存为普通上传文件
+//  5. DELETE /api/admin/upload/chunked/:id           取消上传
+// 超过 UPLOAD_CHUNK_EXPIRY 未更新的会话由 utils.StartChunkCleanup 自动清理
+
+// InitChunkedUpload 创建分片上传会话
+func (h *UploadHandler) InitChunkedUpload(c *gin.Context) {
+	var req struct {
+		Filename string `json:"filename"`
+		Size     int64  `json:"size"`
+		Type     string `json:"type"`   // document 或 file
+		SHA256   string `json:"sha256"` // 可选，完成时校验整个文件
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求参数错误")
+		return
+	}
+
+	cfg := config.AppConfig.Upload
+	req.Filename = strings.TrimSpace(req.Filename)
+	if req.Filename == "" {
+		utils.BadRequest(c, "文件名不能为空")
+		return
+	}
+	if req.Type == "" {
+		req.Type = "file"
+	}
+	if req.Type == "logo" {
+		utils.BadRequest(c, "图标请使用普通上传")
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(req.Filename))
+	if msg := checkUploadType(req.Type, ext); msg != "" {
+		utils.BadRequest(c, msg)
+		return
+	}
+	// 图片和SVG需要完整读入内存过滤元数据和脚本，只能使用普通上传
+	if ext == ".svg" || utils.IsImageExt(ext) {
+		utils.BadRequest(c, "图片请使用普通上传")
+		return
+	}
+
+	if req.Size <= 0 {
+		utils.BadRequest(c, "文件大小不正确")
+		return
+	}
+	if req.Size > cfg.ChunkedMaxSize {
+		utils.BadRequest(c, fmt.Sprintf("文件大小超过限制（最大%dMB）", cfg.ChunkedMaxSize/1024/1024))
+		return
+	}
+	req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+	if req.SHA256 != "" {
+		if b, err := hex.DecodeString(req.SHA256); err != nil || len(b) != 32 {
+			utils.BadRequest(c, "sha256格式不正确")
+			return
+		}
+	}
+
+	id, err := utils.NewUploadSessionID()
+	if err != nil {
+		utils.InternalServerError(c, "创建上传会话失败")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "开启事务失败")
+		return
+	}
+	defer tx.Rollback()
+
+	session := &models.UploadSession{
+		ID:        id,
+		Filename:  filepath.Base(req.Filename),
+		Type:      req.Type,
+		Size:      req.Size,
+		ChunkSize: cfg.ChunkSize,
+		SHA256:    req.SHA256,
+		Uploader:  middleware.CurrentUsername(c),
+	}
+	if err := models.CreateUploadSession(tx, session); err != nil {
+		utils.InternalServerError(c, "创建上传会话失败")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	session.Status = models.UploadSessionPending
+	utils.Success(c, chunkedUploadStatus(session, []int{}))
+}
+
+// GetChunkedUpload 查询分片上传进度（断点续传时根据 received 跳过已上传的分片）
+func (h *UploadHandler) GetChunkedUpload(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	received, err := utils.ReceivedChunks(session.ID)
+	if err != nil {
+		utils.InternalServerError(c, "读取分片失败")
+		return
+	}
+	utils.Success(c, chunkedUploadStatus(session, received))
+}
+
+// PutChunk 上传一个分片
+// 请求体为分片原始内容，除最后一个分片外大小必须等于 chunk_size；
+// 可通过 X-Chunk-SHA256 请求头提供分片的SHA-256，服务端校验不一致时拒绝
+func (h *UploadHandler) PutChunk(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Status != models.UploadSessionPending {
+		utils.BadRequest(c, "上传正在合并，不能继续上传分片")
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= session.TotalChunks() {
+		utils.BadRequest(c, fmt.Sprintf("分片序号不正确（应为0到%d）", session.TotalChunks()-1))
+		return
+	}
+
+	length := session.ChunkLength(index)
+	if c.Request.ContentLength >= 0 && c.Request.ContentLength != length {
+		utils.BadRequest(c, fmt.Sprintf("分片大小不正确（第%d个分片应为%d字节）", index, length))
+		return
+	}
+
+	if err := utils.SaveChunk(session.ID, index, c.Request.Body, length, c.GetHeader("X-Chunk-SHA256")); err != nil {
+		if err == utils.ErrChunkSize {
+			utils.BadRequest(c, fmt.Sprintf("分片大小不正确（第%d个分片应为%d字节）", index, length))
+		} else if err == utils.ErrChunkChecksum {
+			utils.BadRequest(c, err.Error())
+		} else {
+			log.Printf("保存分片失败 %s/%d: %v", session.ID, index, err)
+			utils.InternalServerError(c, "保存分片失败")
+		}
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "开启事务失败")
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.TouchUploadSession(tx, session.ID); err != nil {
+		utils.InternalServerError(c, "更新上传会话失败")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"index": index, "size": length})
+}
+
+// CompleteChunkedUpload 合并分片，校验大小、SHA-256和文件头后保存为上传文件
+func (h *UploadHandler) CompleteChunkedUpload(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	received, err := utils.ReceivedChunks(session.ID)
+	if err != nil {
+		utils.InternalServerError(c, "读取分片失败")
+		return
+	}
+	if missing := missingChunks(received, session.TotalChunks()); len(missing) > 0 {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Message: fmt.Sprintf("还有%d个分片未上传", len(missing)),
+			Data:    gin.H{"missing": missing},
+		})
+		return
+	}
+
+	// 标记为合并中，防止重复提交或合并时继续上传分片
+	if ok, err := h.setUploadSessionStatus(session.ID, models.UploadSessionPending, models.UploadSessionCompleting); err != nil {
+		utils.InternalServerError(c, "更新上传会话失败")
+		return
+	} else if !ok {
+		utils.BadRequest(c, "上传正在合并，请勿重复提交")
+		return
+	}
+	// 未能完成时恢复为接收分片状态，客户端可以补传后重试
+	completed := false
+	defer func() {
+		if !completed {
+			h.setUploadSessionStatus(session.ID, models.UploadSessionCompleting, models.UploadSessionPending)
+		}
+	}()
+
+	assembled, hash, err := utils.AssembleChunks(session)
+	if err != nil {
+		log.Printf("合并分片失败 %s: %v", session.ID, err)
+		utils.BadRequest(c, "合并分片失败，请重新上传缺失或损坏的分片")
+		return
+	}
+	defer os.Remove(assembled)
+
+	// 整个文件校验失败时无法确定是哪个分片出错，只能重新上传
+	if session.SHA256 != "" && session.SHA256 != hash {
+		h.abortUploadSession(session.ID)
+		completed = true
+		utils.BadRequest(c, "文件校验失败（SHA-256不一致），请重新上传")
+		return
+	}
+
+	file, err := os.Open(assembled)
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(session.Filename))
+	head, err := utils.ReadFileHead(file)
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+	if err := utils.CheckFileContent(ext, head); err != nil {
+		h.abortUploadSession(session.ID)
+		completed = true
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+
+	// 与普通上传一样按内容哈希命名
+	newFilename := hash + ext
+	key := "files/" + newFilename
+	accessPath := storage.URLFromKey(key)
+	mimeType := detectMimeType(ext, head)
+
+	deduplicated, err := saveUploadObject(key, file, session.Size, mimeType)
+	if err != nil {
+		log.Printf("保存上传文件失败 %s: %v", key, err)
+		utils.InternalServerError(c, "文件保存失败")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "开启事务失败")
+		return
+	}
+	defer tx.Rollback()
+
+	upload := &models.Upload{
+		Hash:         hash,
+		Path:         accessPath,
+		OriginalName: session.Filename,
+		Mime:         mimeType,
+		Size:         session.Size,
+		Uploader:     middleware.CurrentUsername(c),
+	}
+	if err := models.CreateUpload(tx, upload); err != nil {
+		utils.InternalServerError(c, "登记上传文件失败")
+		return
+	}
+	if err := models.DeleteUploadSession(tx, session.ID); err != nil {
+		utils.InternalServerError(c, "删除上传会话失败")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+	completed = true
+	utils.RemoveChunks(session.ID)
+
+	utils.SuccessWithMessage(c, "上传成功", gin.H{
+		"filename":     session.Filename,
+		"name":         newFilename,
+		"size":         session.Size,
+		"url":          accessPath,
+		"path":         accessPath,
+		"originalName": session.Filename,
+		"hash":         hash,
+		"deduplicated": deduplicated,
+	})
+}
+
+// AbortChunkedUpload 取消分片上传，删除已上传的分片
+func (h *UploadHandler) AbortChunkedUpload(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Status != models.UploadSessionPending {
+		utils.BadRequest(c, "上传正在合并，不能取消")
+		return
+	}
+
+	if err := h.abortUploadSession(session.ID); err != nil {
+		utils.InternalServerError(c, "取消上传失败")
+		return
+	}
+	utils.SuccessWithMessage(c, "已取消上传", nil)
+}
+
+// loadUploadSession 根据路由参数读取上传会话，失败时已写入响应
+func (h *UploadHandler) loadUploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	id := c.Param("id")
+	if !utils.IsUploadSessionID(id) {
+		utils.NotFound(c, "上传会话不存在或已过期")
+		return nil, false
+	}
+
+	session, err := models.GetUploadSession(h.DB, id)
+	if err == sql.ErrNoRows {
+		utils.NotFound(c, "上传会话不存在或已过期")
+		return nil, false
+	}
+	if err != nil {
+		utils.InternalServerError(c, "获取上传会话失败")
+		return nil, false
+	}
+	return session, true
+}
+
+// setUploadSessionStatus 修改会话状态，返回是否修改成功
+func (h *UploadHandler) setUploadSessionStatus(id, from, to string) (bool, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	ok, err := models.SetUploadSessionStatus(tx, id, from, to)
+	if err != nil {
+		return false, err
+	}
+	return ok, tx.Commit()
+}
+
+// abortUploadSession 删除会话记录和分片临时文件
+func (h *UploadHandler) abortUploadSession(id string) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := models.DeleteUploadSession(tx, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return utils.RemoveChunks(id)
+}
+
+// chunkedUploadStatus 分片上传会话的响应数据
+func chunkedUploadStatus(session *models.UploadSession, received []int) gin.H {
+	return gin.H{
+		"upload_id":    session.ID,
+		"filename":     session.Filename,
+		"type":         session.Type,
+		"size":         session.Size,
+		"chunk_size":   session.ChunkSize,
+		"total_chunks": session.TotalChunks(),
+		"received":     received,
+		"status":       session.Status,
+		"created_at":   session.CreatedAt,
+		"updated_at":   session.UpdatedAt,
+	}
+}
+
+// missingChunks 返回尚未收到的分片序号
+func missingChunks(received []int, total int) []int {
+	got := make(map[int]bool, len(received))
+	for _, index := range received {
+		got[index] = true
+	}
+
+	missing := []int{}
+	for i := 0; i < total; i++ {
+		if !got[i] {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
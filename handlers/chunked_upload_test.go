@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"nav-admin/config"
+	"nav-admin/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chunkTestContent 测试文件内容，分片大小为5时分为 "hello"、" worl"、"d!" 三个分片
+const chunkTestContent = "hello world!"
+
+type chunkTestServer struct {
+	t  *testing.T
+	db *sql.DB
+	r  *gin.Engine
+}
+
+func newChunkTestServer(t *testing.T) *chunkTestServer {
+	t.Helper()
+	db := newMergeTestDB(t)
+	config.AppConfig.Upload.ChunkPath = filepath.Join(t.TempDir(), "chunks")
+	config.AppConfig.Upload.ChunkSize = 5
+	config.AppConfig.Upload.ChunkedMaxSize = 1024
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := &UploadHandler{DB: db}
+	r.POST("/upload/chunked", h.InitChunkedUpload)
+	r.GET("/upload/chunked/:id", h.GetChunkedUpload)
+	r.PUT("/upload/chunked/:id/chunks/:index", h.PutChunk)
+	r.POST("/upload/chunked/:id/complete", h.CompleteChunkedUpload)
+	r.DELETE("/upload/chunked/:id", h.AbortChunkedUpload)
+	return &chunkTestServer{t: t, db: db, r: r}
+}
+
+// do 发送请求，返回状态码和响应中的 data
+func (s *chunkTestServer) do(method, path, body string, header map[string]string) (int, map[string]interface{}) {
+	s.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.r.ServeHTTP(w, req)
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp.Data
+}
+
+// init 创建会话，返回会话ID
+func (s *chunkTestServer) init(sum string) string {
+	s.t.Helper()
+	body := `{"filename": "notes.txt", "type": "document", "size": 12, "sha256": "` + sum + `"}`
+	code, data := s.do(http.MethodPost, "/upload/chunked", body, map[string]string{"Content-Type": "application/json"})
+	if code != http.StatusOK {
+		s.t.Fatalf("init: status %d", code)
+	}
+	if data["total_chunks"] != float64(3) {
+		s.t.Fatalf("total_chunks = %v, want 3", data["total_chunks"])
+	}
+	return data["upload_id"].(string)
+}
+
+// put 上传第 index 个分片，checksum 为空时不带 X-Chunk-SHA256
+func (s *chunkTestServer) put(id string, index int, chunk, checksum string) int {
+	s.t.Helper()
+	header := map[string]string{}
+	if checksum != "" {
+		header["X-Chunk-SHA256"] = checksum
+	}
+	code, _ := s.do(http.MethodPut, "/upload/chunked/"+id+"/chunks/"+strconv.Itoa(index), chunk, header)
+	return code
+}
+
+func (s *chunkTestServer) received(id string) []interface{} {
+	s.t.Helper()
+	code, data := s.do(http.MethodGet, "/upload/chunked/"+id, "", nil)
+	if code != http.StatusOK {
+		s.t.Fatalf("get session: status %d", code)
+	}
+	return data["received"].([]interface{})
+}
+
+func (s *chunkTestServer) sessionGone(id string) {
+	s.t.Helper()
+	if code, _ := s.do(http.MethodGet, "/upload/chunked/"+id, "", nil); code != http.StatusNotFound {
+		s.t.Errorf("session still exists: status %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(config.AppConfig.Upload.ChunkPath, id)); !os.IsNotExist(err) {
+		s.t.Errorf("chunk directory still exists: %v", err)
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestChunkedUploadOutOfOrder(t *testing.T) {
+	s := newChunkTestServer(t)
+	hash := sha256Hex(chunkTestContent)
+	id := s.init(hash)
+
+	if code := s.put(id, 2, "d!", sha256Hex("d!")); code != http.StatusOK {
+		t.Fatalf("put chunk 2: status %d", code)
+	}
+	if code := s.put(id, 0, "hello", ""); code != http.StatusOK {
+		t.Fatalf("put chunk 0: status %d", code)
+	}
+	if got := s.received(id); len(got) != 2 || got[0] != float64(0) || got[1] != float64(2) {
+		t.Errorf("received = %v, want [0 2]", got)
+	}
+
+	code, data := s.do(http.MethodPost, "/upload/chunked/"+id+"/complete", "", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("complete with missing chunk: status %d", code)
+	}
+	if missing, _ := data["missing"].([]interface{}); len(missing) != 1 || missing[0] != float64(1) {
+		t.Errorf("missing = %v, want [1]", data["missing"])
+	}
+
+	// 重复上传同一分片覆盖之前的内容
+	if code := s.put(id, 1, "xxxxx", ""); code != http.StatusOK {
+		t.Fatalf("put chunk 1: status %d", code)
+	}
+	if code := s.put(id, 1, " worl", ""); code != http.StatusOK {
+		t.Fatalf("put chunk 1 again: status %d", code)
+	}
+
+	code, data = s.do(http.MethodPost, "/upload/chunked/"+id+"/complete", "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("complete: status %d", code)
+	}
+	if data["path"] != "/uploads/files/"+hash+".txt" {
+		t.Errorf("path = %v", data["path"])
+	}
+	saved, err := os.ReadFile(filepath.Join(config.AppConfig.Upload.Path, "files", hash+".txt"))
+	if err != nil || string(saved) != chunkTestContent {
+		t.Errorf("saved file = %q, %v", saved, err)
+	}
+	upload, err := models.GetUploadByPath(s.db, "/uploads/files/"+hash+".txt")
+	if err != nil || upload.OriginalName != "notes.txt" || upload.Size != 12 {
+		t.Errorf("upload record = %+v, %v", upload, err)
+	}
+	s.sessionGone(id)
+}
+
+func TestChunkedUploadRejectsBadChunks(t *testing.T) {
+	s := newChunkTestServer(t)
+	id := s.init("")
+
+	if code := s.put(id, 0, "hello", sha256Hex("HELLO")); code != http.StatusBadRequest {
+		t.Errorf("bad X-Chunk-SHA256: status %d, want 400", code)
+	}
+	if code := s.put(id, 0, "hell", ""); code != http.StatusBadRequest {
+		t.Errorf("short chunk: status %d, want 400", code)
+	}
+	if code := s.put(id, 3, "x", ""); code != http.StatusBadRequest {
+		t.Errorf("index out of range: status %d, want 400", code)
+	}
+	if got := s.received(id); len(got) != 0 {
+		t.Errorf("received = %v after rejected chunks, want none", got)
+	}
+
+	if code := s.put(id, 0, "hello", strings.ToUpper(sha256Hex("hello"))); code != http.StatusOK {
+		t.Errorf("valid X-Chunk-SHA256: status %d", code)
+	}
+}
+
+func TestChunkedUploadHashMismatch(t *testing.T) {
+	s := newChunkTestServer(t)
+	id := s.init(sha256Hex("hello world?"))
+	for i, chunk := range []string{"hello", " worl", "d!"} {
+		if code := s.put(id, i, chunk, ""); code != http.StatusOK {
+			t.Fatalf("put chunk %d: status %d", i, code)
+		}
+	}
+
+	if code, _ := s.do(http.MethodPost, "/upload/chunked/"+id+"/complete", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("complete: status %d, want 400", code)
+	}
+	// 无法确定哪个分片出错，会话和分片都被删除
+	s.sessionGone(id)
+	entries, _ := os.ReadDir(filepath.Join(config.AppConfig.Upload.Path, "files"))
+	if len(entries) != 0 {
+		t.Errorf("files saved after hash mismatch: %d", len(entries))
+	}
+}
+
+func TestChunkedUploadAbort(t *testing.T) {
+	s := newChunkTestServer(t)
+	id := s.init("")
+	if code := s.put(id, 0, "hello", ""); code != http.StatusOK {
+		t.Fatalf("put chunk: status %d", code)
+	}
+
+	// 合并中的会话不能继续上传分片，也不能取消
+	if _, err := s.db.Exec("UPDATE upload_sessions SET status = ? WHERE id = ?", models.UploadSessionCompleting, id); err != nil {
+		t.Fatal(err)
+	}
+	if code := s.put(id, 1, " worl", ""); code != http.StatusBadRequest {
+		t.Errorf("put while completing: status %d, want 400", code)
+	}
+	if code, _ := s.do(http.MethodDelete, "/upload/chunked/"+id, "", nil); code != http.StatusBadRequest {
+		t.Errorf("abort while completing: status %d, want 400", code)
+	}
+	if _, err := s.db.Exec("UPDATE upload_sessions SET status = ? WHERE id = ?", models.UploadSessionPending, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := s.do(http.MethodDelete, "/upload/chunked/"+id, "", nil); code != http.StatusOK {
+		t.Fatalf("abort: status %d", code)
+	}
+	s.sessionGone(id)
+	if code := s.put(id, 1, " worl", ""); code != http.StatusNotFound {
+		t.Errorf("put after abort: status %d, want 404", code)
+	}
+}
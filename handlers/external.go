@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"database/sql"
+	"io"
+	"nav-admin/importers"
+	"nav-admin/models"
+	"nav-admin/navdoc"
+	"nav-admin/utils"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxExternalFileSize 外部数据文件的大小限制
+const maxExternalFileSize = 5 * 1024 * 1024
+
+// maxSkippedSites 导入结果中最多列出的跳过站点数
+const maxSkippedSites = 100
+
+// 外部数据（浏览器书签、其他导航项目）的导入方式
+const (
+	externalReplace = "replace" // 替换：清空现有分类和站点（公告和设置不变）
+	externalAppend  = "append"  // 追加：按分类 _id 和站点链接合并，已有数据保留
+)
+
+// skippedSite 导入时跳过的站点
+type skippedSite struct {
+	Folder string `json:"folder"`
+	Name   string `json:"name"`
+	Href   string `json:"href"`
+	Reason string `json:"reason"`
+}
+
+// externalImportResult 外部数据导入结果
+type externalImportResult struct {
+	Format     string        `json:"format,omitempty"`
+	Mode       string        `json:"mode"`
+	Categories int           `json:"categories"`
+	Sites      int           `json:"sites"`
+	Icons      int           `json:"icons"`
+	Skipped    int           `json:"skipped"`
+	SkipList   []skippedSite `json:"skipped_list"`
+	Merged     *mergeResult  `json:"merged,omitempty"`
+}
+
+// newExternalImportResult 创建导入结果
+func newExternalImportResult(mode string) *externalImportResult {
+	return &externalImportResult{Mode: mode, SkipList: []skippedSite{}}
+}
+
+// skip 记录跳过的站点
+func (r *externalImportResult) skip(folder, name, href, reason string) {
+	r.Skipped++
+	if len(r.SkipList) < maxSkippedSites {
+		r.SkipList = append(r.SkipList, skippedSite{Folder: folder, Name: name, Href: href, Reason: reason})
+	}
+}
+
+// count 统计导入的分类和站点数
+func (r *externalImportResult) count(doc *navdoc.Document) {
+	r.Categories = len(doc.Categories)
+	for _, cat := range doc.Categories {
+		r.Sites += len(cat.Sites)
+	}
+}
+
+// GetImportFormats 获取支持导入的其他导航项目数据格式
+func (h *NavHandler) GetImportFormats(c *gin.Context) {
+	utils.Success(c, importers.List())
+}
+
+// ImportExternal 导入其他导航项目的数据（format 为 importers 中注册的名称）
+// 转换得到的分类和站点经过截断和校验后导入，无效的站点跳过；mode 与书签导入相同
+func (h *NavHandler) ImportExternal(c *gin.Context) {
+	format := c.DefaultPostForm("format", c.Query("format"))
+	imp, ok := importers.Get(format)
+	if !ok {
+		utils.BadRequest(c, "不支持的数据格式: "+format)
+		return
+	}
+	mode, ok := externalImportMode(c)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "未找到上传文件")
+		return
+	}
+	if file.Size > maxExternalFileSize {
+		utils.BadRequest(c, "文件过大（最大5MB）")
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+
+	parsed, err := imp.Parse(data)
+	if err != nil {
+		utils.BadRequest(c, "解析文件失败: "+err.Error())
+		return
+	}
+
+	result := newExternalImportResult(mode)
+	result.Format = format
+	doc := &navdoc.Document{Version: navdoc.Version}
+	for _, cat := range parsed.Categories {
+		cat.Classify = truncateRunes(strings.TrimSpace(cat.Classify), utils.MaxClassifyLength)
+		if cat.Classify == "" {
+			cat.Classify = "未命名分类"
+		}
+		if cat.Icon != "" && !utils.IsAllowedIcon(cat.Icon) {
+			cat.Icon = ""
+		}
+
+		sites := make([]navdoc.Site, 0, len(cat.Sites))
+		for _, s := range cat.Sites {
+			site := &models.Site{Name: s.Name, Href: s.Href, Desc: s.Desc, Logo: s.Logo}
+			if reason := normalizeImportedSite(site); reason != "" {
+				result.skip(cat.Classify, s.Name, s.Href, reason)
+				continue
+			}
+			sites = append(sites, navdoc.Site{Name: site.Name, Href: site.Href, Desc: site.Desc, Logo: site.Logo})
+		}
+		if len(sites) > 0 {
+			cat.Sites = sites
+			doc.Categories = append(doc.Categories, cat)
+		}
+	}
+	if len(doc.Categories) == 0 {
+		utils.BadRequest(c, "文件中没有可以导入的站点")
+		return
+	}
+	if errs := utils.ValidateNavDocument(doc); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	if result.Merged, err = writeExternalCategories(tx, doc, mode); err != nil {
+		utils.InternalServerError(c, "导入失败: "+err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	result.count(doc)
+	utils.SuccessWithMessage(c, "导入成功", result)
+
+	// 异步更新nav.json
+	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
+}
+
+// externalImportMode 读取导入方式（查询参数或表单字段 mode，默认 append），无效时返回400
+func externalImportMode(c *gin.Context) (string, bool) {
+	mode := c.DefaultPostForm("mode", c.DefaultQuery("mode", externalAppend))
+	if mode != externalAppend && mode != externalReplace {
+		utils.BadRequest(c, "无效的导入方式，可选值: append, replace")
+		return "", false
+	}
+	return mode, true
+}
+
+// writeExternalCategories 在事务中写入外部数据转换得到的分类和站点
+// replace 清空现有分类和站点后导入；append 用 mergeNavData 合并（保留现有数据，公告不受影响）
+func writeExternalCategories(tx *sql.Tx, doc *navdoc.Document, mode string) (*mergeResult, error) {
+	if mode == externalAppend {
+		// 外部数据没有分类层级，按版本1合并，不修改已有分类的上级分类
+		doc.Version = 1
+		return mergeNavData(tx, doc, true, false)
+	}
+	if _, err := tx.Exec("DELETE FROM site_tags"); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM sites"); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM categories"); err != nil {
+		return nil, err
+	}
+	return nil, createNavCategories(tx, doc.Categories)
+}
+
+// normalizeImportedSite 规范化外部数据中的站点：名称和描述过长时截断，没有名称时使用域名，无效的logo去掉
+// 返回站点无法导入的原因（如链接无效），可以导入时返回空字符串
+func normalizeImportedSite(site *models.Site) string {
+	site.Name = truncateRunes(strings.TrimSpace(site.Name), utils.MaxSiteNameLength)
+	site.Href = strings.TrimSpace(site.Href)
+	site.Desc = truncateRunes(strings.TrimSpace(site.Desc), utils.MaxSiteDescLength)
+	if site.Name == "" {
+		site.Name = hrefHost(site.Href)
+	}
+
+	errs := utils.ValidateSite(nil, site)
+	for _, e := range errs {
+		if e.Field == "logo" {
+			site.Logo = ""
+			errs = utils.ValidateSite(nil, site)
+			break
+		}
+	}
+	if errs.HasErrors() {
+		return errs[0].Field + " " + errs[0].Message
+	}
+	return ""
+}
+
+// hrefHost 返回链接的域名（用作没有名称的站点名称和图标文件名）
+func hrefHost(href string) string {
+	if u, err := url.Parse(href); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return truncateRunes(href, utils.MaxSiteNameLength)
+}
+
+// truncateRunes 按字符数截断字符串
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
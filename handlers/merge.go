@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"nav-admin/models"
+	"nav-admin/navdoc"
+	"nav-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mergeCounts 合并导入中某类数据的处理结果
+type mergeCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Deleted   int `json:"deleted"`
+}
+
+// mergeResult 合并导入的结果
+type mergeResult struct {
+	Categories    mergeCounts `json:"categories"`
+	Sites         mergeCounts `json:"sites"`
+	Announcements mergeCounts `json:"announcements"`
+}
+
+// importOptions 读取导入策略参数（查询参数或表单字段）：
+// strategy=replace（默认）或 merge；keep_unmatched 仅对 merge 有效，默认 true
+func importOptions(c *gin.Context) (utils.ImportOptions, bool) {
+	param := func(key string) string {
+		if v := c.Query(key); v != "" {
+			return v
+		}
+		return c.PostForm(key)
+	}
+
+	opts := utils.ImportOptions{KeepUnmatched: true}
+	switch param("strategy") {
+	case "", "replace":
+	case "merge":
+		opts.Merge = true
+	default:
+		utils.BadRequest(c, "无效的导入策略，可选值: replace, merge")
+		return opts, false
+	}
+
+	switch param("keep_unmatched") {
+	case "", "true", "1":
+	case "false", "0":
+		opts.KeepUnmatched = false
+	default:
+		utils.BadRequest(c, "keep_unmatched 只能为 true 或 false")
+		return opts, false
+	}
+	return opts, true
+}
+
+// mergeNavData 将导入的nav数据（已通过 utils.ValidateNavDocument 校验）合并到现有数据：
+// 分类按 _id 匹配，站点按归一化链接匹配（utils.MatchSites，优先匹配同一分类中的站点，其他分类中的站点移动到导入数据中的分类），
+// 公告按发布时间和过滤后的内容匹配；已存在的更新（分类和站点保持原来的位置，移动的站点排在新分类最后），
+// 不存在的追加到末尾；keepUnmatched 为 false 时删除导入数据中没有的记录。
+// 文档包含分类层级时（navdoc.Document.Hierarchical）按文档设置上级分类，上级分类改变的分类排在新的同级分类最后。
+// 文档包含标签信息时（navdoc.Document.Tagged）同时按文档设置匹配站点的标签。
+// withSettings 为 true 时同时更新公告轮播间隔
+func mergeNavData(tx *sql.Tx, doc *navdoc.Document, keepUnmatched, withSettings bool) (*mergeResult, error) {
+	result := &mergeResult{}
+
+	// 读取现有数据（在事务中读取，与写入使用同一连接）
+	categories, err := models.GetAllCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+	currentCats := make(map[string]models.Category, len(categories))
+	var currentSites []utils.CurrentSite
+	for _, cat := range categories {
+		currentCats[cat.IDStr] = cat
+		sites, err := models.GetSitesByCategoryID(tx, cat.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, site := range sites {
+			currentSites = append(currentSites, utils.CurrentSite{Category: cat.IDStr, Site: site})
+		}
+	}
+
+	announcements, err := models.GetAllAnnouncements(tx)
+	if err != nil {
+		return nil, err
+	}
+	currentAnns := map[string][]int{} // 发布时间+内容 -> 公告ID
+	for _, ann := range announcements {
+		key := ann.Timestamp + "\x00" + ann.Content
+		currentAnns[key] = append(currentAnns[key], ann.ID)
+	}
+
+	if cfg := doc.AnnouncementConfig; cfg != nil {
+		if cfg.Interval != nil && withSettings {
+			if err := models.UpdateAnnouncementInterval(tx, *cfg.Interval); err != nil {
+				return nil, fmt.Errorf("更新公告配置失败: %v", err)
+			}
+		}
+		for _, a := range cfg.Announcements {
+			ann := announcementFromDoc(a)
+			key := ann.Timestamp + "\x00" + ann.Content
+			if ids := currentAnns[key]; len(ids) > 0 {
+				// 发布时间和内容相同的公告视为已存在，保持不变
+				currentAnns[key] = ids[1:]
+				result.Announcements.Unchanged++
+				continue
+			}
+			if _, err := models.CreateAnnouncement(tx, ann); err != nil {
+				return nil, fmt.Errorf("创建公告失败: %v", err)
+			}
+			result.Announcements.Created++
+		}
+	}
+
+	tagged := doc.Tagged()
+	siteMatches := utils.MatchSites(doc, currentSites)
+	matchedSites := map[int]bool{}
+	seenCats := map[string]bool{}
+	catIDs := make(map[string]int, len(doc.Categories)) // 导入数据中分类的 _id -> 分类ID
+	unchangedCats := map[string]bool{}                  // 内容没有变化的已有分类（上级分类改变时改为更新）
+	for catPos, c := range doc.Categories {
+		if seenCats[c.ID] {
+			return nil, fmt.Errorf("分类 %s 重复", c.ID)
+		}
+		seenCats[c.ID] = true
+
+		// 分类
+		var catID int
+		if cur, ok := currentCats[c.ID]; ok {
+			catID = cur.ID
+			if cur.Classify != c.Classify || cur.Icon != c.Icon {
+				if err := models.UpdateCategory(tx, catID, &models.Category{IDStr: c.ID, Classify: c.Classify, Icon: c.Icon}); err != nil {
+					return nil, fmt.Errorf("更新分类失败: %v", err)
+				}
+				result.Categories.Updated++
+			} else {
+				result.Categories.Unchanged++
+				unchangedCats[c.ID] = true
+			}
+		} else {
+			id, err := models.CreateCategory(tx, &models.Category{IDStr: c.ID, Classify: c.Classify, Icon: c.Icon})
+			if err != nil {
+				return nil, fmt.Errorf("创建分类失败: %v", err)
+			}
+			catID = int(id)
+			result.Categories.Created++
+		}
+		catIDs[c.ID] = catID
+
+		// 站点
+		for sitePos, s := range c.Sites {
+			site := &models.Site{CatID: catID, Name: s.Name, Href: s.Href, Desc: s.Desc, Logo: s.Logo, Tags: s.Tags}
+			if tagged && site.Tags == nil {
+				site.Tags = []string{}
+			}
+
+			if match := siteMatches[catPos][sitePos]; match != nil {
+				cur := match.Site
+				matchedSites[cur.ID] = true
+				moved := cur.CatID != catID
+				if moved {
+					// 其他分类中链接相同的站点移动到导入数据中的分类，而不是新建重复的站点
+					if err := models.MoveSite(tx, cur.ID, catID); err != nil {
+						return nil, fmt.Errorf("移动站点失败: %v", err)
+					}
+				}
+				if cur.Name == site.Name && cur.Href == site.Href && cur.Desc == site.Desc && cur.Logo == site.Logo &&
+					(!tagged || models.SameTags(cur.Tags, site.Tags)) {
+					if moved {
+						result.Sites.Updated++
+					} else {
+						result.Sites.Unchanged++
+					}
+					continue
+				}
+				if err := models.UpdateSite(tx, cur.ID, site); err != nil {
+					return nil, fmt.Errorf("更新站点失败: %v", err)
+				}
+				result.Sites.Updated++
+				continue
+			}
+
+			if _, err := models.CreateSite(tx, site); err != nil {
+				return nil, fmt.Errorf("创建站点失败: %v", err)
+			}
+			result.Sites.Created++
+		}
+	}
+
+	// 上级分类（上级分类可能在子分类之后，所有分类处理完后再设置）
+	if doc.Hierarchical() {
+		for _, c := range doc.Categories {
+			parentID := 0
+			if c.Parent != "" {
+				parentID = catIDs[c.Parent]
+			}
+			current := 0
+			if cur, ok := currentCats[c.ID]; ok {
+				current = cur.ParentID
+			}
+			if parentID == current {
+				continue
+			}
+			if err := models.MoveCategory(tx, catIDs[c.ID], parentID); err != nil {
+				return nil, fmt.Errorf("设置上级分类失败: %v", err)
+			}
+			if unchangedCats[c.ID] {
+				unchangedCats[c.ID] = false
+				result.Categories.Unchanged--
+				result.Categories.Updated++
+			}
+		}
+	}
+
+	if keepUnmatched {
+		return result, nil
+	}
+
+	// 导入数据中有的分类中未匹配的站点（移动到其他分类的站点已匹配）
+	for _, s := range currentSites {
+		if !seenCats[s.Category] || matchedSites[s.Site.ID] {
+			continue
+		}
+		if err := models.DeleteSite(tx, s.Site.ID); err != nil {
+			return nil, fmt.Errorf("删除站点失败: %v", err)
+		}
+		result.Sites.Deleted++
+	}
+
+	// 导入数据中没有的分类（连同其中剩下的站点）和公告
+	for _, cat := range categories {
+		if seenCats[cat.IDStr] {
+			continue
+		}
+		sites, err := models.GetSitesByCategoryID(tx, cat.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := models.DeleteCategory(tx, cat.ID); err != nil {
+			return nil, fmt.Errorf("删除分类失败: %v", err)
+		}
+		result.Categories.Deleted++
+		result.Sites.Deleted += len(sites)
+	}
+	for _, ids := range currentAnns {
+		for _, id := range ids {
+			if err := models.DeleteAnnouncement(tx, id); err != nil {
+				return nil, fmt.Errorf("删除公告失败: %v", err)
+			}
+			result.Announcements.Deleted++
+		}
+	}
+
+	return result, nil
+}
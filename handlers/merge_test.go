@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"database/sql"
+	"nav-admin/config"
+	"nav-admin/models"
+	"nav-admin/navdoc"
+	"nav-admin/storage"
+	"nav-admin/utils"
+	"path/filepath"
+	"testing"
+)
+
+func newMergeTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dir := t.TempDir()
+	config.AppConfig = &config.Config{
+		Upload: config.UploadConfig{Path: filepath.Join(dir, "uploads")},
+		Nav:    config.NavConfig{JSONPath: filepath.Join(dir, "nav.json")},
+	}
+	storage.Default = storage.NewLocalStorage(config.AppConfig.Upload.Path)
+	db, err := utils.InitDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func parseTestDoc(t *testing.T, data string) *navdoc.Document {
+	t.Helper()
+	doc, errs, err := utils.ParseNavDocument([]byte(data))
+	if err != nil || errs.HasErrors() {
+		t.Fatalf("parse: %v %v", err, errs)
+	}
+	return doc
+}
+
+func mergeTestDoc(t *testing.T, db *sql.DB, doc *navdoc.Document, keepUnmatched bool) *mergeResult {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	result, err := mergeNavData(tx, doc, keepUnmatched, true)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// siteCategories 返回链接对应的站点所在分类的 _id
+func siteCategories(t *testing.T, db *sql.DB, href string) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT c.id_str FROM sites s JOIN categories c ON c.id = s.cat_id WHERE s.href = ? ORDER BY s.id", href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var cats []string
+	for rows.Next() {
+		var cat string
+		if err := rows.Scan(&cat); err != nil {
+			t.Fatal(err)
+		}
+		cats = append(cats, cat)
+	}
+	return cats
+}
+
+func TestMergeNavDataMovesSitesAcrossCategories(t *testing.T) {
+	db := newMergeTestDB(t)
+	mergeTestDoc(t, db, parseTestDoc(t, `[
+		{"_id": "a", "classify": "A", "icon": "", "sites": [
+			{"name": "X", "href": "https://x.example.com/", "desc": "", "logo": ""},
+			{"name": "Shared", "href": "https://shared.example.com", "desc": "", "logo": ""}
+		]},
+		{"_id": "old", "classify": "Old", "icon": "", "sites": [
+			{"name": "Z", "href": "https://z.example.com", "desc": "", "logo": ""}
+		]}
+	]`), true)
+	var xID int
+	if err := db.QueryRow("SELECT id FROM sites WHERE href = 'https://x.example.com/'").Scan(&xID); err != nil {
+		t.Fatal(err)
+	}
+
+	// X 移到 b；Shared 同时出现在 b 和 a 中（b 在前），a 中原有的站点留在 a；Z 从将被删除的分类移到 b
+	result := mergeTestDoc(t, db, parseTestDoc(t, `[
+		{"_id": "b", "classify": "B", "icon": "", "sites": [
+			{"name": "Shared", "href": "https://shared.example.com", "desc": "", "logo": ""},
+			{"name": "X", "href": "https://X.example.com", "desc": "", "logo": ""},
+			{"name": "Z", "href": "https://z.example.com", "desc": "", "logo": ""}
+		]},
+		{"_id": "a", "classify": "A", "icon": "", "sites": [
+			{"name": "Shared", "href": "https://shared.example.com", "desc": "", "logo": ""}
+		]}
+	]`), false)
+
+	if cats := siteCategories(t, db, "https://X.example.com"); len(cats) != 1 || cats[0] != "b" {
+		t.Errorf("X categories = %v, want [b]", cats)
+	}
+	var movedID int
+	if err := db.QueryRow("SELECT id FROM sites WHERE href = 'https://X.example.com'").Scan(&movedID); err != nil || movedID != xID {
+		t.Errorf("X was recreated (id %d, want %d): %v", movedID, xID, err)
+	}
+	if cats := siteCategories(t, db, "https://shared.example.com"); len(cats) != 2 || cats[0] != "a" || cats[1] != "b" {
+		t.Errorf("Shared categories = %v, want [a b]", cats)
+	}
+	if cats := siteCategories(t, db, "https://z.example.com"); len(cats) != 1 || cats[0] != "b" {
+		t.Errorf("Z categories = %v, want [b]", cats)
+	}
+
+	want := mergeResult{
+		Categories: mergeCounts{Created: 1, Unchanged: 1, Deleted: 1},
+		Sites:      mergeCounts{Created: 1, Updated: 2, Unchanged: 1},
+	}
+	if *result != want {
+		t.Errorf("result = %+v, want %+v", *result, want)
+	}
+}
+
+func TestMergeNavDataMatchesSanitizedAnnouncements(t *testing.T) {
+	db := newMergeTestDB(t)
+	doc := parseTestDoc(t, `[
+		{"_id": "announcement_config", "type": "announcement_config", "announcements": [
+			{"timestamp": "2024-01-01", "content": "<p onclick=\"alert(1)\">维护通知</p><script>x()</script>", "format": "html", "severity": "info", "pinned": false}
+		]},
+		{"_id": "a", "classify": "A", "icon": "", "sites": []}
+	]`)
+
+	first := mergeTestDoc(t, db, doc, false)
+	if first.Announcements.Created != 1 {
+		t.Fatalf("first merge = %+v", first.Announcements)
+	}
+
+	// 预览与合并都按过滤后的内容匹配，再次导入同一文档不产生重复公告
+	diff, err := utils.DiffNavDocument(db, doc, utils.ImportOptions{Merge: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Announcements.Added) != 0 || diff.Announcements.Unchanged != 1 {
+		t.Errorf("diff = %+v, want 1 unchanged", diff.Announcements)
+	}
+	second := mergeTestDoc(t, db, doc, false)
+	if second.Announcements != (mergeCounts{Unchanged: 1}) {
+		t.Errorf("second merge = %+v, want 1 unchanged", second.Announcements)
+	}
+	anns, err := models.GetAllAnnouncements(db)
+	if err != nil || len(anns) != 1 {
+		t.Fatalf("announcements = %d, %v", len(anns), err)
+	}
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"bytes"
 	"database/sql"
+	"fmt"
 	"nav-admin/models"
+	"nav-admin/navdoc"
 	"nav-admin/utils"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,55 +16,29 @@ type NavHandler struct {
 	DB *sql.DB
 }
 
-// GetNavData 获取完整的导航数据（用于前端展示）
+// GetNavData 获取完整的导航数据（用于前端展示，与nav.json内容相同）
+// layout=flat 或 nested 指定分类结构，不传时与nav.json相同；tag 不为空时只返回带该标签的站点（及其所在的分类）
 func (h *NavHandler) GetNavData(c *gin.Context) {
-	// 获取页面配置
-	pageConfig, err := models.GetPageConfig(h.DB)
-	if err != nil {
-		utils.InternalServerError(c, "查询页面配置失败")
-		return
-	}
-
-	// 获取公告配置
-	announcementConfig, err := models.GetAnnouncementConfig(h.DB)
-	if err != nil {
-		utils.InternalServerError(c, "查询公告配置失败")
-		return
+	doc := utils.BuildNavDocument(h.DB)
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		doc.FilterTag(tag)
 	}
-
-	// 获取所有分类
-	categories, err := models.GetAllCategories(h.DB)
-	if err != nil {
-		utils.InternalServerError(c, "查询分类失败")
+	switch c.Query("layout") {
+	case "":
+	case "flat":
+		doc.Nested = false
+	case "nested":
+		doc.Nested = true
+	default:
+		utils.BadRequest(c, "无效的分类结构，可选值: flat, nested")
 		return
 	}
+	utils.Success(c, doc)
+}
 
-	// 为每个分类获取站点
-	for i := range categories {
-		sites, err := models.GetSitesByCategoryID(h.DB, categories[i].ID)
-		if err != nil {
-			continue
-		}
-		categories[i].Sites = sites
-	}
-
-	// 构建返回数据，页面配置和公告配置放在前面
-	result := []interface{}{
-		map[string]interface{}{
-			"type":        "page_config",
-			"title":       pageConfig.Title,
-			"subtitle":    pageConfig.Subtitle,
-			"logo":        pageConfig.Logo,
-			"footer_text": pageConfig.FooterText,
-			"icp":         pageConfig.ICP,
-		},
-		announcementConfig,
-	}
-	for _, cat := range categories {
-		result = append(result, cat)
-	}
-
-	utils.Success(c, result)
+// GetSchema 获取导航数据文档格式的 JSON Schema
+func (h *NavHandler) GetSchema(c *gin.Context) {
+	c.Data(200, "application/schema+json; charset=utf-8", navdoc.Schema)
 }
 
 // GetPageConfig 获取页面配置
@@ -82,6 +60,12 @@ func (h *NavHandler) UpdatePageConfig(c *gin.Context) {
 		return
 	}
 
+	if errs := utils.ValidatePageConfig(&config); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+	config.FooterText = utils.SanitizeRichText(config.FooterText, config.FooterFormat)
+
 	// 使用事务
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -104,34 +88,37 @@ func (h *NavHandler) UpdatePageConfig(c *gin.Context) {
 
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
 }
 
-// ExportData 导出所有数据为JSON
+// ExportData 导出所有数据
+// format 指定导出格式：json（默认，可重新导入的完整数据）、html（浏览器书签）、opml、csv、markdown
 func (h *NavHandler) ExportData(c *gin.Context) {
-	// 获取完整的导航数据
-	announcementConfig, err := models.GetAnnouncementConfig(h.DB)
-	if err != nil {
-		utils.InternalServerError(c, "查询公告配置失败")
+	format := c.DefaultQuery("format", "json")
+	exportFormat, ok := utils.NavExportFormats[format]
+	if format != "json" && !ok {
+		utils.BadRequest(c, "无效的导出格式，可选值: json, html, opml, csv, markdown")
 		return
 	}
 
-	categories, err := models.GetAllCategories(h.DB)
+	// 获取完整的导航数据（包含页面配置）
+	result, err := utils.BuildNavExport(h.DB)
 	if err != nil {
-		utils.InternalServerError(c, "查询分类失败")
+		utils.InternalServerError(c, "查询导航数据失败")
 		return
 	}
 
-	for i := range categories {
-		sites, err := models.GetSitesByCategoryID(h.DB, categories[i].ID)
-		if err != nil {
-			continue
+	if ok {
+		var buf bytes.Buffer
+		if err := exportFormat.Write(&buf, result); err != nil {
+			utils.InternalServerError(c, "生成导出文件失败")
+			return
 		}
-		categories[i].Sites = sites
-	}
-
-	result := []interface{}{announcementConfig}
-	for _, cat := range categories {
-		result = append(result, cat)
+		c.Header("Content-Disposition", "attachment; filename=nav_data"+exportFormat.Ext)
+		c.Data(200, exportFormat.ContentType, buf.Bytes())
+		return
 	}
 
 	// 设置响应头，触发下载
@@ -142,11 +129,39 @@ func (h *NavHandler) ExportData(c *gin.Context) {
 
 // ImportData 导入JSON数据
 func (h *NavHandler) ImportData(c *gin.Context) {
-	var data []map[string]interface{}
-	if err := c.ShouldBindJSON(&data); err != nil {
+	body, err := c.GetRawData()
+	if err != nil {
+		utils.BadRequest(c, "请求格式错误")
+		return
+	}
+	doc, errs, err := utils.ParseNavDocument(body)
+	if err != nil {
 		utils.BadRequest(c, "请求格式错误")
 		return
 	}
+	if errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	opts, ok := importOptions(c)
+	if !ok {
+		return
+	}
+
+	// 预览模式：只返回导入后的变化，不修改数据
+	if c.Query("dry_run") == "true" || c.Query("dry_run") == "1" {
+		diff, err := utils.DiffNavDocument(h.DB, doc, opts)
+		if err == nil {
+			diff.Settings, err = utils.DiffSettings(h.DB, doc, nil)
+		}
+		if err != nil {
+			utils.InternalServerError(c, "生成导入预览失败")
+			return
+		}
+		utils.SuccessWithMessage(c, "导入预览（未修改数据）", diff)
+		return
+	}
 
 	// 使用事务
 	tx, err := h.DB.Begin()
@@ -156,101 +171,115 @@ func (h *NavHandler) ImportData(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
+	// 合并模式：按分类 _id 和站点链接更新已有数据
+	if opts.Merge {
+		result, err := mergeNavData(tx, doc, opts.KeepUnmatched, true)
+		if err != nil {
+			utils.InternalServerError(c, "合并数据失败: "+err.Error())
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			utils.InternalServerError(c, "提交事务失败")
+			return
+		}
+
+		utils.SuccessWithMessage(c, "合并导入成功", result)
+
+		// 异步更新nav.json
+		go utils.GenerateNavJSON(h.DB)
+
+		// 事务已提交，删除不再引用的上传文件
+		go utils.RemovePendingUploads(h.DB)
+		return
+	}
+
+	if err := replaceNavData(tx, doc, true); err != nil {
+		utils.InternalServerError(c, "导入数据失败: "+err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "导入成功", nil)
+
+	// 异步更新nav.json
+	go utils.GenerateNavJSON(h.DB)
+}
+
+// replaceNavData 清空分类、站点和公告后导入nav数据（已通过 utils.ValidateNavDocument 校验），
+// withSettings 为 true 时同时更新公告轮播间隔；页面配置由调用方按需恢复
+func replaceNavData(tx *sql.Tx, doc *navdoc.Document, withSettings bool) error {
 	// 清空现有数据
+	if _, err := tx.Exec("DELETE FROM site_tags"); err != nil {
+		return fmt.Errorf("清空站点标签失败: %v", err)
+	}
 	if _, err := tx.Exec("DELETE FROM sites"); err != nil {
-		utils.InternalServerError(c, "清空站点失败")
-		return
+		return fmt.Errorf("清空站点失败: %v", err)
 	}
 	if _, err := tx.Exec("DELETE FROM categories"); err != nil {
-		utils.InternalServerError(c, "清空分类失败")
-		return
+		return fmt.Errorf("清空分类失败: %v", err)
 	}
 	if _, err := tx.Exec("DELETE FROM announcements"); err != nil {
-		utils.InternalServerError(c, "清空公告失败")
-		return
+		return fmt.Errorf("清空公告失败: %v", err)
 	}
 
-	sortNo := 0
-	for _, item := range data {
-		// 检查是否是公告配置
-		if typeVal, ok := item["type"].(string); ok && typeVal == "announcement_config" {
-			// 处理公告配置
-			if interval, ok := item["interval"].(float64); ok {
-				if err := models.UpdateAnnouncementInterval(tx, int(interval)); err != nil {
-					utils.InternalServerError(c, "更新公告配置失败")
-					return
-				}
+	if cfg := doc.AnnouncementConfig; cfg != nil {
+		if cfg.Interval != nil && withSettings {
+			if err := models.UpdateAnnouncementInterval(tx, *cfg.Interval); err != nil {
+				return fmt.Errorf("更新公告配置失败: %v", err)
 			}
-
-			// 处理公告列表
-			if announcements, ok := item["announcements"].([]interface{}); ok {
-				for _, annItem := range announcements {
-					if annMap, ok := annItem.(map[string]interface{}); ok {
-						ann := &models.Announcement{
-							Timestamp: annMap["timestamp"].(string),
-							Content:   annMap["content"].(string),
-						}
-						if _, err := models.CreateAnnouncement(tx, ann); err != nil {
-							utils.InternalServerError(c, "创建公告失败")
-							return
-						}
-					}
-				}
+		}
+		for _, a := range cfg.Announcements {
+			if _, err := models.CreateAnnouncement(tx, announcementFromDoc(a)); err != nil {
+				return fmt.Errorf("创建公告失败: %v", err)
 			}
-			continue
 		}
+	}
 
-		// 处理普通分类
-		cat := &models.Category{
-			IDStr:    item["_id"].(string),
-			Classify: item["classify"].(string),
-			Icon:     item["icon"].(string),
-			SortNo:   sortNo,
-		}
+	return createNavCategories(tx, doc.Categories)
+}
+
+// pendingParent 创建时上级分类还不存在、需要在最后设置上级分类的分类
+type pendingParent struct {
+	id     int
+	parent string // 上级分类的 _id
+}
 
-		catID, err := models.CreateCategory(tx, cat)
+// createNavCategories 按顺序创建分类及其站点（CreateCategory/CreateSite 追加到末尾）
+// 上级分类已创建时直接创建为其子分类，否则（上级分类在后面）先创建为顶级分类，全部创建后再移动
+func createNavCategories(tx *sql.Tx, categories []navdoc.Category) error {
+	created := make(map[string]int, len(categories)) // _id -> 分类ID（_id 重复时使用第一个）
+	var deferred []pendingParent
+	for _, c := range categories {
+		parentID, parentCreated := created[c.Parent]
+		id, err := models.CreateCategory(tx, &models.Category{IDStr: c.ID, ParentID: parentID, Classify: c.Classify, Icon: c.Icon})
 		if err != nil {
-			utils.InternalServerError(c, "创建分类失败")
-			return
+			return fmt.Errorf("创建分类失败: %v", err)
+		}
+		catID := int(id)
+		if _, ok := created[c.ID]; !ok {
+			created[c.ID] = catID
 		}
-		sortNo++
-
-		// 处理站点
-		if sites, ok := item["sites"].([]interface{}); ok {
-			siteSortNo := 0
-			for _, siteItem := range sites {
-				if siteMap, ok := siteItem.(map[string]interface{}); ok {
-					site := &models.Site{
-						CatID:  int(catID),
-						Name:   siteMap["name"].(string),
-						Href:   siteMap["href"].(string),
-						SortNo: siteSortNo,
-					}
-
-					if desc, ok := siteMap["desc"].(string); ok {
-						site.Desc = desc
-					}
-					if logo, ok := siteMap["logo"].(string); ok {
-						site.Logo = logo
-					}
-
-					if _, err := models.CreateSite(tx, site); err != nil {
-						utils.InternalServerError(c, "创建站点失败")
-						return
-					}
-					siteSortNo++
-				}
+		if c.Parent != "" && !parentCreated {
+			deferred = append(deferred, pendingParent{id: catID, parent: c.Parent})
+		}
+		for _, s := range c.Sites {
+			site := &models.Site{CatID: catID, Name: s.Name, Href: s.Href, Desc: s.Desc, Logo: s.Logo, Tags: s.Tags}
+			if _, err := models.CreateSite(tx, site); err != nil {
+				return fmt.Errorf("创建站点失败: %v", err)
 			}
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		utils.InternalServerError(c, "提交事务失败")
-		return
+	for _, d := range deferred {
+		if parentID, ok := created[d.parent]; ok {
+			if err := models.MoveCategory(tx, d.id, parentID); err != nil {
+				return fmt.Errorf("设置上级分类失败: %v", err)
+			}
+		}
 	}
-
-	utils.SuccessWithMessage(c, "导入成功", nil)
-
-	// 异步更新nav.json
-	go utils.GenerateNavJSON(h.DB)
+	return nil
 }
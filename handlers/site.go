@@ -5,6 +5,7 @@ import (
 	"nav-admin/models"
 	"nav-admin/utils"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -59,6 +60,11 @@ func (h *SiteHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if errs := utils.ValidateSite(h.DB, &site); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
 	// 使用事务
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -79,7 +85,7 @@ func (h *SiteHandler) Create(c *gin.Context) {
 	}
 
 	site.ID = int(id)
-	utils.SuccessWithMessage(c, "创建成功", site)
+	utils.SuccessWithWarnings(c, "创建成功", site, h.duplicateWarnings(site.Href, site.ID))
 
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
@@ -99,6 +105,21 @@ func (h *SiteHandler) Update(c *gin.Context) {
 		return
 	}
 
+	oldSite, err := models.GetSiteByID(h.DB, id)
+	if err != nil {
+		utils.NotFound(c, "站点不存在")
+		return
+	}
+	// 未传分类时沿用原分类
+	if site.CatID == 0 {
+		site.CatID = oldSite.CatID
+	}
+
+	if errs := utils.ValidateSite(h.DB, &site); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
 	// 使用事务
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -113,15 +134,26 @@ func (h *SiteHandler) Update(c *gin.Context) {
 		return
 	}
 
+	// 分类改变时移到新分类的最后
+	if site.CatID != oldSite.CatID {
+		if err := models.MoveSite(tx, id, site.CatID); err != nil {
+			utils.InternalServerError(c, "移动站点失败")
+			return
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		utils.InternalServerError(c, "提交事务失败")
 		return
 	}
 
-	utils.SuccessWithMessage(c, "更新成功", nil)
+	utils.SuccessWithWarnings(c, "更新成功", nil, h.duplicateWarnings(site.Href, id))
 
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
 }
 
 // Delete 删除站点
@@ -155,6 +187,9 @@ func (h *SiteHandler) Delete(c *gin.Context) {
 
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
 }
 
 // UpdateSort 更新站点排序
@@ -258,3 +293,120 @@ func (h *SiteHandler) UpdateSort(c *gin.Context) {
 	// 异步更新nav.json
 	go utils.GenerateNavJSON(h.DB)
 }
+
+// FetchMetadata 抓取网页元数据，用于创建站点时预填名称和描述
+func (h *SiteHandler) FetchMetadata(c *gin.Context) {
+	var req struct {
+		URL string `json:"url" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求格式错误")
+		return
+	}
+
+	meta, err := utils.FetchPageMetadata(req.URL)
+	if err != nil {
+		utils.BadRequest(c, "获取网页信息失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, meta)
+}
+
+// GetDuplicates 获取归一化链接重复的站点报告
+func (h *SiteHandler) GetDuplicates(c *gin.Context) {
+	groups, err := models.GetDuplicateSiteGroups(h.DB)
+	if err != nil {
+		utils.InternalServerError(c, "查询失败")
+		return
+	}
+
+	utils.Success(c, groups)
+}
+
+// MergeDuplicates 合并重复站点，保留一个并删除其余站点
+func (h *SiteHandler) MergeDuplicates(c *gin.Context) {
+	var req struct {
+		KeepID    int   `json:"keep_id" binding:"required"`
+		RemoveIDs []int `json:"remove_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求格式错误")
+		return
+	}
+
+	if len(req.RemoveIDs) == 0 {
+		utils.BadRequest(c, "待合并站点不能为空")
+		return
+	}
+	if len(req.RemoveIDs) > 100 {
+		utils.BadRequest(c, "待合并站点过多，最多支持100项")
+		return
+	}
+
+	// 使用事务，在事务内校验，避免校验后站点被修改
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	keep, err := models.GetSiteByID(tx, req.KeepID)
+	if err != nil {
+		utils.BadRequest(c, "保留的站点不存在")
+		return
+	}
+
+	// 只允许合并归一化链接确实相同的站点
+	target := models.NormalizeHref(keep.Href)
+	for _, id := range req.RemoveIDs {
+		if id <= 0 || id == req.KeepID {
+			utils.BadRequest(c, "无效的站点ID")
+			return
+		}
+		site, err := models.GetSiteByID(tx, id)
+		if err != nil {
+			utils.BadRequest(c, "站点ID不存在: "+strconv.Itoa(id))
+			return
+		}
+		if models.NormalizeHref(site.Href) != target {
+			utils.BadRequest(c, "站点链接不重复，无法合并: "+site.Name)
+			return
+		}
+	}
+
+	if err := models.MergeDuplicateSites(tx, req.KeepID, req.RemoveIDs); err != nil {
+		utils.InternalServerError(c, "合并失败")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "合并成功", nil)
+
+	// 异步更新nav.json
+	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
+}
+
+// duplicateWarnings 检查链接是否已被其他站点使用，返回提示信息
+func (h *SiteHandler) duplicateWarnings(href string, excludeID int) []string {
+	duplicates, err := models.FindSitesByNormalizedHref(h.DB, href, excludeID)
+	if err != nil || len(duplicates) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(duplicates))
+	for _, dup := range duplicates {
+		names = append(names, "「"+dup.Classify+" / "+dup.Name+"」")
+	}
+	return []string{"该链接已存在于: " + strings.Join(names, "、")}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"nav-admin/models"
+	"nav-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBulkSiteItems 一次批量操作最多处理的站点数
+const maxBulkSiteItems = 500
+
+// 批量操作类型
+const (
+	bulkMove   = "move"   // 移动到另一个分类（排在目标分类最后）
+	bulkDelete = "delete" // 删除
+	bulkEdit   = "edit"   // 修改描述和/或logo
+	bulkCreate = "create" // 创建
+)
+
+// bulkSiteRequest 批量操作请求：move/delete/edit 使用 ids，create 使用 sites
+type bulkSiteRequest struct {
+	Action string        `json:"action"`
+	IDs    []int         `json:"ids"`
+	CatID  int           `json:"cat_id"` // move 的目标分类
+	Desc   *string       `json:"desc"`   // edit 时为 nil 的字段不修改
+	Logo   *string       `json:"logo"`
+	Sites  []models.Site `json:"sites"`
+}
+
+// bulkItemResult 单个站点的处理结果，Index 为在 ids 或 sites 中的位置
+type bulkItemResult struct {
+	Index   int                    `json:"index"`
+	ID      int                    `json:"id,omitempty"`
+	Success bool                   `json:"success"`
+	Error   string                 `json:"error,omitempty"`
+	Errors  utils.ValidationErrors `json:"errors,omitempty"`
+}
+
+// bulkSiteResult 批量操作结果
+type bulkSiteResult struct {
+	Action    string           `json:"action"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []bulkItemResult `json:"results"`
+}
+
+// fail 记录失败的条目
+func (r *bulkSiteResult) fail(item bulkItemResult, message string, errs utils.ValidationErrors) {
+	item.Error = message
+	item.Errors = errs
+	r.Failed++
+	r.Results = append(r.Results, item)
+}
+
+// ok 记录成功的条目
+func (r *bulkSiteResult) ok(item bulkItemResult) {
+	item.Success = true
+	r.Succeeded++
+	r.Results = append(r.Results, item)
+}
+
+// Bulk 批量移动、删除、修改或创建站点
+// 每个条目单独校验，失败的条目（站点不存在、字段校验失败等）跳过并在结果中说明原因，其余条目在同一事务中完成；
+// 全部处理完后只更新一次nav.json
+func (h *SiteHandler) Bulk(c *gin.Context) {
+	var req bulkSiteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求格式错误")
+		return
+	}
+
+	count := len(req.IDs)
+	switch req.Action {
+	case bulkMove, bulkDelete, bulkEdit:
+	case bulkCreate:
+		count = len(req.Sites)
+	default:
+		utils.BadRequest(c, "无效的操作，可选值: move, delete, edit, create")
+		return
+	}
+	if count == 0 {
+		utils.BadRequest(c, "操作的站点不能为空")
+		return
+	}
+	if count > maxBulkSiteItems {
+		utils.BadRequest(c, "站点过多，一次最多支持500项")
+		return
+	}
+	if req.Action == bulkEdit && req.Desc == nil && req.Logo == nil {
+		utils.BadRequest(c, "请指定要修改的描述或logo")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	if req.Action == bulkMove {
+		var exists int
+		if err := tx.QueryRow("SELECT 1 FROM categories WHERE id = ?", req.CatID).Scan(&exists); err != nil {
+			utils.BadRequest(c, "目标分类不存在")
+			return
+		}
+	}
+
+	result := &bulkSiteResult{Action: req.Action, Results: make([]bulkItemResult, 0, count)}
+	if req.Action == bulkCreate {
+		err = bulkCreateSites(tx, req.Sites, result)
+	} else {
+		err = bulkUpdateSites(tx, &req, result)
+	}
+	if err != nil {
+		utils.InternalServerError(c, "批量操作失败")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "批量操作完成", result)
+
+	// 异步更新nav.json，删除不再引用的上传文件
+	if result.Succeeded > 0 {
+		go utils.GenerateNavJSON(h.DB)
+		go utils.RemovePendingUploads(h.DB)
+	}
+}
+
+// bulkUpdateSites 批量移动、删除或修改已有站点，只有数据库错误时返回 error
+func bulkUpdateSites(tx *sql.Tx, req *bulkSiteRequest, result *bulkSiteResult) error {
+	seen := make(map[int]bool, len(req.IDs))
+	for i, id := range req.IDs {
+		item := bulkItemResult{Index: i, ID: id}
+		if seen[id] {
+			result.fail(item, "重复的站点ID", nil)
+			continue
+		}
+		seen[id] = true
+
+		site, err := models.GetSiteByID(tx, id)
+		if err == sql.ErrNoRows {
+			result.fail(item, "站点不存在", nil)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		switch req.Action {
+		case bulkMove:
+			// 已在目标分类中的站点保持原位置
+			if site.CatID != req.CatID {
+				if err := models.MoveSite(tx, id, req.CatID); err != nil {
+					return err
+				}
+			}
+
+		case bulkDelete:
+			if err := models.DeleteSite(tx, id); err != nil {
+				return err
+			}
+
+		case bulkEdit:
+			updated := *site
+			if req.Desc != nil {
+				updated.Desc = *req.Desc
+			}
+			if req.Logo != nil {
+				updated.Logo = *req.Logo
+			}
+			if errs := utils.ValidateSite(nil, &updated); errs.HasErrors() {
+				result.fail(item, errs[0].Field+" "+errs[0].Message, errs)
+				continue
+			}
+			if err := models.UpdateSite(tx, id, &updated); err != nil {
+				return err
+			}
+		}
+		result.ok(item)
+	}
+	return nil
+}
+
+// bulkCreateSites 批量创建站点（按顺序排在各自分类的最后），只有数据库错误时返回 error
+func bulkCreateSites(tx *sql.Tx, sites []models.Site, result *bulkSiteResult) error {
+	for i := range sites {
+		site := sites[i]
+		item := bulkItemResult{Index: i}
+		if errs := utils.ValidateSite(tx, &site); errs.HasErrors() {
+			result.fail(item, errs[0].Field+" "+errs[0].Message, errs)
+			continue
+		}
+		id, err := models.CreateSite(tx, &site)
+		if err != nil {
+			return err
+		}
+		item.ID = int(id)
+		result.ok(item)
+	}
+	return nil
+}
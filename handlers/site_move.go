@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"nav-admin/models"
+	"nav-admin/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 布局中分类和站点数量的上限
+const (
+	maxLayoutCategories = 1000
+	maxLayoutSites      = 20000
+)
+
+// layoutCategory 布局中的一个分类及其站点ID（按显示顺序）
+type layoutCategory struct {
+	ID    int   `json:"id"`
+	Sites []int `json:"sites"`
+}
+
+// siteLayout 完整的导航布局：分类顺序和每个分类中的站点顺序
+type siteLayout struct {
+	Categories []layoutCategory `json:"categories"`
+}
+
+// Move 把站点移动到指定分类的指定位置（position 从0开始，不传时排在最后）
+// 目标分类可以是站点当前所在的分类，此时只调整位置
+func (h *SiteHandler) Move(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "无效的ID")
+		return
+	}
+
+	var req struct {
+		CatID    int  `json:"cat_id"`
+		Position *int `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求格式错误")
+		return
+	}
+	position := -1
+	if req.Position != nil {
+		if *req.Position < 0 {
+			utils.BadRequest(c, "位置不能小于0")
+			return
+		}
+		position = *req.Position
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := models.GetSiteByID(tx, id); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(c, "站点不存在")
+		} else {
+			utils.InternalServerError(c, "查询失败")
+		}
+		return
+	}
+	var exists int
+	if err := tx.QueryRow("SELECT 1 FROM categories WHERE id = ?", req.CatID).Scan(&exists); err != nil {
+		utils.BadRequest(c, "目标分类不存在")
+		return
+	}
+
+	if err := models.MoveSiteTo(tx, id, req.CatID, position); err != nil {
+		utils.InternalServerError(c, "移动站点失败")
+		return
+	}
+	site, err := models.GetSiteByID(tx, id)
+	if err != nil {
+		utils.InternalServerError(c, "查询失败")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "移动成功", site)
+
+	// 异步更新nav.json
+	go utils.GenerateNavJSON(h.DB)
+}
+
+// GetLayout 获取当前的导航布局（可修改后提交给 UpdateLayout）
+func (h *SiteHandler) GetLayout(c *gin.Context) {
+	categories, err := models.GetAllCategories(h.DB)
+	if err != nil {
+		utils.InternalServerError(c, "查询分类失败")
+		return
+	}
+	sites, err := models.GetSiteLayout(h.DB)
+	if err != nil {
+		utils.InternalServerError(c, "查询站点失败")
+		return
+	}
+
+	layout := siteLayout{Categories: make([]layoutCategory, 0, len(categories))}
+	for _, cat := range categories {
+		ids := sites[cat.ID]
+		if ids == nil {
+			ids = []int{}
+		}
+		layout.Categories = append(layout.Categories, layoutCategory{ID: cat.ID, Sites: ids})
+	}
+	utils.Success(c, layout)
+}
+
+// UpdateLayout 按提交的完整布局一次性调整分类顺序、站点所在分类和站点顺序
+// 布局必须包含所有分类和所有站点且各出现一次，校验通过后在同一事务中写入
+func (h *SiteHandler) UpdateLayout(c *gin.Context) {
+	var layout siteLayout
+	if err := c.ShouldBindJSON(&layout); err != nil {
+		utils.BadRequest(c, "请求格式错误")
+		return
+	}
+	if len(layout.Categories) == 0 {
+		utils.BadRequest(c, "布局中的分类不能为空")
+		return
+	}
+	if len(layout.Categories) > maxLayoutCategories {
+		utils.BadRequest(c, "分类过多，最多支持"+strconv.Itoa(maxLayoutCategories)+"个")
+		return
+	}
+
+	// 检查重复的分类和站点
+	catSeen := make(map[int]bool, len(layout.Categories))
+	siteSeen := map[int]bool{}
+	for _, cat := range layout.Categories {
+		if catSeen[cat.ID] {
+			utils.BadRequest(c, "布局中存在重复的分类ID: "+strconv.Itoa(cat.ID))
+			return
+		}
+		catSeen[cat.ID] = true
+		for _, id := range cat.Sites {
+			if siteSeen[id] {
+				utils.BadRequest(c, "布局中存在重复的站点ID: "+strconv.Itoa(id))
+				return
+			}
+			siteSeen[id] = true
+		}
+		if len(siteSeen) > maxLayoutSites {
+			utils.BadRequest(c, "站点过多，最多支持"+strconv.Itoa(maxLayoutSites)+"个")
+			return
+		}
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	// 布局必须与数据库中的分类和站点完全对应
+	categories, err := models.GetAllCategories(tx)
+	if err != nil {
+		utils.InternalServerError(c, "查询分类失败")
+		return
+	}
+	current, err := models.GetSiteLayout(tx)
+	if err != nil {
+		utils.InternalServerError(c, "查询站点失败")
+		return
+	}
+	siteCount := 0
+	for _, cat := range categories {
+		if !catSeen[cat.ID] {
+			utils.BadRequest(c, "布局不完整，缺少分类ID: "+strconv.Itoa(cat.ID))
+			return
+		}
+		for _, id := range current[cat.ID] {
+			if !siteSeen[id] {
+				utils.BadRequest(c, "布局不完整，缺少站点ID: "+strconv.Itoa(id))
+				return
+			}
+		}
+		siteCount += len(current[cat.ID])
+	}
+	if len(catSeen) != len(categories) {
+		for _, cat := range layout.Categories {
+			if !containsCategory(categories, cat.ID) {
+				utils.BadRequest(c, "分类ID不存在: "+strconv.Itoa(cat.ID))
+				return
+			}
+		}
+	}
+	if len(siteSeen) != siteCount {
+		existing := make(map[int]bool, siteCount)
+		for _, ids := range current {
+			for _, id := range ids {
+				existing[id] = true
+			}
+		}
+		for _, cat := range layout.Categories {
+			for _, id := range cat.Sites {
+				if !existing[id] {
+					utils.BadRequest(c, "站点ID不存在: "+strconv.Itoa(id))
+					return
+				}
+			}
+		}
+	}
+
+	for i, cat := range layout.Categories {
+		if err := models.UpdateCategorySortNo(tx, cat.ID, i); err != nil {
+			utils.InternalServerError(c, "更新分类排序失败")
+			return
+		}
+		for j, id := range cat.Sites {
+			if err := models.UpdateSitePosition(tx, id, cat.ID, j); err != nil {
+				utils.InternalServerError(c, "更新站点排序失败")
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "布局已保存", nil)
+
+	// 异步更新nav.json
+	go utils.GenerateNavJSON(h.DB)
+}
+
+// containsCategory 判断分类列表中是否有指定ID的分类
+func containsCategory(categories []models.Category, id int) bool {
+	for _, cat := range categories {
+		if cat.ID == id {
+			return true
+		}
+	}
+	return false
+}
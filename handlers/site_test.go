@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"nav-admin/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateSiteMovesCategory(t *testing.T) {
+	db := newMergeTestDB(t)
+	mergeTestDoc(t, db, parseTestDoc(t, `[
+		{"_id": "a", "classify": "A", "icon": "", "sites": [
+			{"name": "X", "href": "https://x.example.com", "desc": "", "logo": ""}
+		]},
+		{"_id": "b", "classify": "B", "icon": "", "sites": [
+			{"name": "Y", "href": "https://y.example.com", "desc": "", "logo": ""}
+		]}
+	]`), false)
+	var siteID, catB int
+	if err := db.QueryRow("SELECT id FROM sites WHERE href = 'https://x.example.com'").Scan(&siteID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT id FROM categories WHERE id_str = 'b'").Scan(&catB); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := &SiteHandler{DB: db}
+	r.PUT("/sites/:id", h.Update)
+
+	body := `{"cat_id": ` + strconv.Itoa(catB) + `, "name": "X2", "href": "https://x.example.com", "desc": "", "logo": ""}`
+	req := httptest.NewRequest(http.MethodPut, "/sites/"+strconv.Itoa(siteID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var name string
+	var catID, sortNo int
+	if err := db.QueryRow("SELECT name, cat_id, sort_no FROM sites WHERE id = ?", siteID).Scan(&name, &catID, &sortNo); err != nil {
+		t.Fatal(err)
+	}
+	if name != "X2" || catID != catB || sortNo != 1 {
+		t.Errorf("site = %s in category %d at %d, want X2 in %d at 1", name, catID, sortNo, catB)
+	}
+	waitNavJSON(t, `"X2"`)
+}
+
+// waitNavJSON 等待处理函数异步生成的 nav.json 包含 want，避免测试结束删除临时目录时仍在写入
+func waitNavJSON(t *testing.T, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(config.AppConfig.Nav.JSONPath); err == nil && strings.Contains(string(data), want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("nav.json not updated with %s", want)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"nav-admin/models"
+	"nav-admin/utils"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 站点表格导入的限制
+const (
+	maxSiteSheetSize = 10 * 1024 * 1024
+	maxSiteSheetRows = 5000
+	maxSiteSortNo    = 1000000
+	maxSiteSheetErrs = 100 // 最多返回的错误条数
+)
+
+// 站点表格导入方式
+const (
+	siteSheetCreate = "create" // 每行创建一个新站点
+	siteSheetUpsert = "upsert" // 同一分类中链接相同（归一化后）的站点更新，否则创建
+)
+
+// siteSheetResult 站点表格导入结果
+type siteSheetResult struct {
+	Mode              string `json:"mode"`
+	Rows              int    `json:"rows"`
+	Created           int    `json:"created"`
+	Updated           int    `json:"updated"`
+	Unchanged         int    `json:"unchanged"`
+	CategoriesCreated int    `json:"categories_created"`
+}
+
+// siteSheetItem 校验通过的一行
+type siteSheetItem struct {
+	line     int
+	category string
+	site     models.Site
+	sort     *int
+}
+
+// ImportSites 从CSV或XLSX表格批量导入站点（列: category, name, href, desc, logo, sort）
+// 所有行校验通过后才在同一事务中写入，有错误时返回每行的错误（字段名带行号），不修改数据
+// 分类按名称匹配，不存在时自动创建；mode=upsert 时同一分类中链接相同的站点更新而不是重复创建
+func (h *SiteHandler) ImportSites(c *gin.Context) {
+	mode := c.DefaultPostForm("mode", c.DefaultQuery("mode", siteSheetCreate))
+	if mode != siteSheetCreate && mode != siteSheetUpsert {
+		utils.BadRequest(c, "无效的导入方式，可选值: create, upsert")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "未找到上传文件")
+		return
+	}
+	if file.Size > maxSiteSheetSize {
+		utils.BadRequest(c, "文件过大（最大10MB）")
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+
+	var rows []utils.SheetRow
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".csv":
+		rows, err = utils.ReadCSVSheet(bytes.NewReader(data))
+	case ".xlsx":
+		rows, err = utils.ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	default:
+		utils.BadRequest(c, "只支持 .csv 和 .xlsx 文件")
+		return
+	}
+	if err != nil {
+		utils.BadRequest(c, "读取表格失败: "+err.Error())
+		return
+	}
+	sheet, err := utils.ParseSiteSheet(rows)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if len(sheet.Rows) == 0 {
+		utils.BadRequest(c, "表格中没有站点数据")
+		return
+	}
+	if len(sheet.Rows) > maxSiteSheetRows {
+		utils.BadRequest(c, fmt.Sprintf("一次最多导入%d行", maxSiteSheetRows))
+		return
+	}
+
+	// 逐行校验，收集所有错误
+	var errs utils.ValidationErrors
+	items := make([]siteSheetItem, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		prefix := fmt.Sprintf("第%d行", row.Line)
+		var rowErrs utils.ValidationErrors
+
+		item := siteSheetItem{
+			line:     row.Line,
+			category: row.Category,
+			site:     models.Site{Name: row.Name, Href: row.Href, Desc: row.Desc, Logo: row.Logo},
+		}
+		if row.Category == "" {
+			rowErrs.Add("category", "分类不能为空")
+		} else if catErrs := utils.ValidateCategory(&models.Category{IDStr: sheetCategoryID(row.Category), Classify: row.Category}); catErrs.HasErrors() {
+			rowErrs.Merge("", catErrs)
+		}
+		rowErrs.Merge("", utils.ValidateSite(nil, &item.site))
+		if row.Sort != "" {
+			// 表格中的排序从1开始，sort_no 从0开始
+			sortNo, err := strconv.Atoi(row.Sort)
+			if err != nil || sortNo < 1 || sortNo > maxSiteSortNo {
+				rowErrs.Add("sort", fmt.Sprintf("排序必须是1-%d之间的整数", maxSiteSortNo))
+			} else {
+				sortNo--
+				item.sort = &sortNo
+			}
+		}
+
+		errs.Merge(prefix, rowErrs)
+		if len(errs) >= maxSiteSheetErrs {
+			break
+		}
+		items = append(items, item)
+	}
+	if errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	categories, err := models.GetAllCategories(tx)
+	if err != nil {
+		utils.InternalServerError(c, "查询分类失败")
+		return
+	}
+	catIDs := make(map[string]int, len(categories)) // 分类名称 -> 分类ID（同名时使用排在前面的）
+	for _, cat := range categories {
+		if _, ok := catIDs[cat.Classify]; !ok {
+			catIDs[cat.Classify] = cat.ID
+		}
+	}
+
+	result := &siteSheetResult{Mode: mode, Rows: len(items)}
+	existing := map[int]map[string]*models.Site{} // 分类ID -> 归一化链接 -> 站点（upsert 时使用）
+	for _, item := range items {
+		catID, ok := catIDs[item.category]
+		if !ok {
+			cat := &models.Category{IDStr: sheetCategoryID(item.category), Classify: item.category}
+			id, err := models.CreateCategory(tx, cat)
+			if err != nil {
+				utils.InternalServerError(c, fmt.Sprintf("第%d行创建分类失败", item.line))
+				return
+			}
+			catID = int(id)
+			catIDs[item.category] = catID
+			result.CategoriesCreated++
+		}
+		site := item.site
+		site.CatID = catID
+
+		var sites map[string]*models.Site
+		if mode == siteSheetUpsert {
+			sites, ok = existing[catID]
+			if !ok {
+				list, err := models.GetSitesByCategoryID(tx, catID)
+				if err != nil {
+					utils.InternalServerError(c, "查询站点失败")
+					return
+				}
+				sites = make(map[string]*models.Site, len(list))
+				for i := range list {
+					key := models.NormalizeHref(list[i].Href)
+					if _, ok := sites[key]; !ok {
+						sites[key] = &list[i]
+					}
+				}
+				existing[catID] = sites
+			}
+
+			key := models.NormalizeHref(site.Href)
+			if old, ok := sites[key]; ok {
+				// 表格中没有的列保持原值
+				if !sheet.Columns["desc"] {
+					site.Desc = old.Desc
+				}
+				if !sheet.Columns["logo"] {
+					site.Logo = old.Logo
+				}
+				site.ID, site.SortNo = old.ID, old.SortNo
+				if item.sort != nil {
+					site.SortNo = *item.sort
+				}
+				// 表格中没有标签列，标签保持不变（site.Tags 为 nil）
+				if site.Name == old.Name && site.Href == old.Href && site.Desc == old.Desc &&
+					site.Logo == old.Logo && site.SortNo == old.SortNo {
+					result.Unchanged++
+					continue
+				}
+				if err := models.UpdateSite(tx, old.ID, &site); err != nil {
+					utils.InternalServerError(c, fmt.Sprintf("第%d行更新站点失败", item.line))
+					return
+				}
+				if site.SortNo != old.SortNo {
+					if err := models.UpdateSiteSortNo(tx, old.ID, site.SortNo); err != nil {
+						utils.InternalServerError(c, fmt.Sprintf("第%d行更新排序失败", item.line))
+						return
+					}
+				}
+				*old = site
+				result.Updated++
+				continue
+			}
+		}
+
+		id, err := models.CreateSite(tx, &site)
+		if err != nil {
+			utils.InternalServerError(c, fmt.Sprintf("第%d行创建站点失败", item.line))
+			return
+		}
+		site.ID = int(id)
+		if item.sort != nil {
+			if err := models.UpdateSiteSortNo(tx, site.ID, *item.sort); err != nil {
+				utils.InternalServerError(c, fmt.Sprintf("第%d行更新排序失败", item.line))
+				return
+			}
+			site.SortNo = *item.sort
+		}
+		if sites != nil {
+			// 表格后面的行中链接相同的站点更新这一个
+			sites[models.NormalizeHref(site.Href)] = &site
+		}
+		result.Created++
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "导入成功", result)
+
+	// 异步更新nav.json
+	go utils.GenerateNavJSON(h.DB)
+
+	// 事务已提交，删除不再引用的上传文件
+	go utils.RemovePendingUploads(h.DB)
+}
+
+// sheetCategoryID 表格导入时自动创建的分类的 _id（由分类名称生成）
+func sheetCategoryID(classify string) string {
+	sum := sha1.Sum([]byte(classify))
+	return "sheet-" + hex.EncodeToString(sum[:])[:12]
+}
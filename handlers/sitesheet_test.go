@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"mime/multipart"
+	"nav-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importSiteSheet 上传表格到 ImportSites，返回状态码和响应
+func importSiteSheet(t *testing.T, db *sql.DB, mode, filename string, data []byte) (int, *utils.Response) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("mode", mode)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := &SiteHandler{DB: db}
+	r.POST("/sites/import", h.ImportSites)
+	req := httptest.NewRequest(http.MethodPost, "/sites/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp utils.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response %s: %v", w.Body.String(), err)
+	}
+	return w.Code, &resp
+}
+
+func countSites(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sites").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestImportSitesReportsLine(t *testing.T) {
+	db := newMergeTestDB(t)
+
+	var xlsx bytes.Buffer
+	if err := utils.WriteXLSX(&xlsx, "Sites", [][]string{
+		{"category", "name", "href"},
+		{"A", "Good", "https://good.example.com"},
+		{},
+		{"A", "Bad", "javascript:alert(1)"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		filename string
+		data     []byte
+		field    string
+	}{
+		// 描述中的换行占两行
+		{"sites.csv", []byte("category,name,href,desc\nA,Good,https://good.example.com,\"a\nb\"\nA,Bad,javascript:alert(1),\n"), "第4行.href"},
+		// 空行不返回，但计入行号
+		{"sites.xlsx", xlsx.Bytes(), "第4行.href"},
+	}
+	for _, tt := range tests {
+		code, resp := importSiteSheet(t, db, siteSheetCreate, tt.filename, tt.data)
+		if code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", tt.filename, code)
+			continue
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0].Field != tt.field {
+			t.Errorf("%s: errors = %v, want field %s", tt.filename, resp.Errors, tt.field)
+		}
+	}
+	// 有错误时不导入任何行
+	if n := countSites(t, db); n != 0 {
+		t.Errorf("%d sites imported", n)
+	}
+}
+
+func TestImportSitesUpsert(t *testing.T) {
+	db := newMergeTestDB(t)
+	mergeTestDoc(t, db, parseTestDoc(t, `[
+		{"_id": "a", "classify": "常用", "icon": "", "sites": [
+			{"name": "A", "href": "https://www.a.example.com/?utm_source=x", "desc": "old desc", "logo": "/uploads/logos/a.png"},
+			{"name": "Keep", "href": "https://keep.example.com", "desc": "k", "logo": ""},
+			{"name": "Other", "href": "https://other.example.com", "desc": "", "logo": ""}
+		]},
+		{"_id": "b", "classify": "其他", "icon": "", "sites": [
+			{"name": "A elsewhere", "href": "https://a.example.com/", "desc": "", "logo": ""}
+		]}
+	]`), false)
+
+	// 没有 desc 和 logo 列，更新时保持原值
+	csv := "\ufeffcategory,name,href,sort\n" +
+		"常用,A2,https://a.example.com/,\n" +
+		"常用,Keep,https://keep.example.com,\n" +
+		"常用,B,https://b.example.com,\n" +
+		"新分类,C,https://c.example.com,\n" +
+		"常用,B again,https://b.example.com/?utm_medium=y,1\n"
+	code, resp := importSiteSheet(t, db, siteSheetUpsert, "sites.csv", []byte(csv))
+	if code != http.StatusOK {
+		t.Fatalf("status %d: %s", code, resp.Message)
+	}
+	result, _ := resp.Data.(map[string]interface{})
+	for key, want := range map[string]float64{"rows": 5, "created": 2, "updated": 2, "unchanged": 1, "categories_created": 1} {
+		if result[key] != want {
+			t.Errorf("%s = %v, want %v", key, result[key], want)
+		}
+	}
+
+	type site struct {
+		category, name, href, desc, logo string
+		sortNo                           int
+	}
+	rows, err := db.Query("SELECT c.classify, s.name, s.href, s.description, s.logo, s.sort_no FROM sites s JOIN categories c ON c.id = s.cat_id ORDER BY c.sort_no, c.id, s.sort_no, s.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []site
+	for rows.Next() {
+		var s site
+		if err := rows.Scan(&s.category, &s.name, &s.href, &s.desc, &s.logo, &s.sortNo); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	want := []site{
+		{"常用", "A2", "https://a.example.com/", "old desc", "/uploads/logos/a.png", 0},
+		{"常用", "B again", "https://b.example.com/?utm_medium=y", "", "", 0},
+		{"常用", "Keep", "https://keep.example.com", "k", "", 1},
+		{"常用", "Other", "https://other.example.com", "", "", 2},
+		{"其他", "A elsewhere", "https://a.example.com/", "", "", 0},
+		{"新分类", "C", "https://c.example.com", "", "", 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("sites = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("site %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	waitNavJSON(t, `"C"`)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"database/sql"
+	"nav-admin/models"
+	"nav-admin/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagHandler struct {
+	DB *sql.DB
+}
+
+// GetAll 获取所有标签（按名称排序，带使用该标签的站点数）
+func (h *TagHandler) GetAll(c *gin.Context) {
+	tags, err := models.GetAllTags(h.DB)
+	if err != nil {
+		utils.InternalServerError(c, "查询失败")
+		return
+	}
+
+	utils.Success(c, tags)
+}
+
+// Create 创建标签（站点保存时也会自动创建不存在的标签）
+func (h *TagHandler) Create(c *gin.Context) {
+	var tag models.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil {
+		utils.BadRequest(c, "请求格式错误")
+		return
+	}
+
+	if errs := utils.ValidateTag(&tag); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	// 使用事务
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	if errs, err := checkTagName(tx, 0, tag.Name); err != nil {
+		utils.InternalServerError(c, "查询失败")
+		return
+	} else if errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	id, err := models.CreateTag(tx, &tag)
+	if err != nil {
+		utils.InternalServerError(c, "创建失败")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	tag.ID = int(id)
+	tag.SiteCount = 0
+	utils.SuccessWithMessage(c, "创建成功", tag)
+}
+
+// Update 修改标签名称，使用该标签的站点随之改变
+func (h *TagHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "无效的ID")
+		return
+	}
+
+	var tag models.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil {
+		utils.BadRequest(c, "请求格式错误")
+		return
+	}
+
+	if errs := utils.ValidateTag(&tag); errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	// 使用事务
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	old, err := models.GetTagByID(tx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(c, "标签不存在")
+		} else {
+			utils.InternalServerError(c, "查询失败")
+		}
+		return
+	}
+	if errs, err := checkTagName(tx, id, tag.Name); err != nil {
+		utils.InternalServerError(c, "查询失败")
+		return
+	} else if errs.HasErrors() {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	if err := models.UpdateTag(tx, id, &tag); err != nil {
+		utils.InternalServerError(c, "更新失败")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	tag.ID, tag.SiteCount = id, old.SiteCount
+	utils.SuccessWithMessage(c, "更新成功", tag)
+
+	// 异步更新nav.json
+	if old.SiteCount > 0 {
+		go utils.GenerateNavJSON(h.DB)
+	}
+}
+
+// Delete 删除标签，并从所有站点上去掉（站点本身不删除）
+func (h *TagHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "无效的ID")
+		return
+	}
+
+	// 使用事务
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "事务开始失败")
+		return
+	}
+	defer tx.Rollback()
+
+	tag, err := models.GetTagByID(tx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(c, "标签不存在")
+		} else {
+			utils.InternalServerError(c, "查询失败")
+		}
+		return
+	}
+
+	if err := models.DeleteTag(tx, id); err != nil {
+		utils.InternalServerError(c, "删除失败")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+
+	// 异步更新nav.json
+	if tag.SiteCount > 0 {
+		go utils.GenerateNavJSON(h.DB)
+	}
+}
+
+// checkTagName 检查标签名称是否与其他标签重复（不区分大小写），id 为正在修改的标签（创建时为0）
+func checkTagName(tx *sql.Tx, id int, name string) (utils.ValidationErrors, error) {
+	var errs utils.ValidationErrors
+	existing, err := models.GetTagIDByName(tx, name)
+	if err == sql.ErrNoRows {
+		return errs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing != id {
+		errs.Add("name", "标签已存在")
+	}
+	return errs, nil
+}
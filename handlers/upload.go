@@ -1,19 +1,32 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"nav-admin/config"
+	"nav-admin/middleware"
+	"nav-admin/models"
+	"nav-admin/storage"
 	"nav-admin/utils"
-	"os"
+	"net/http"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-type UploadHandler struct{}
+type UploadHandler struct {
+	DB *sql.DB
+}
 
 // UploadFile 上传文件（支持logo和下载文件）
 func (h *UploadHandler) UploadFile(c *gin.Context) {
@@ -23,12 +36,6 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// 检查文件大小
-	if file.Size > config.AppConfig.Upload.MaxSize {
-		utils.BadRequest(c, fmt.Sprintf("文件大小超过限制（最大%dMB）", config.AppConfig.Upload.MaxSize/1024/1024))
-		return
-	}
-
 	// 检查文件类型
 	ext := strings.ToLower(filepath.Ext(file.Filename))
 	uploadType := c.PostForm("type") // logo 或 document
@@ -36,75 +43,193 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 		uploadType = c.DefaultQuery("type", "file")
 	}
 
-	// 根据上传类型验证文件格式
-	var allowedExts []string
-	if uploadType == "logo" {
-		allowedExts = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".ico"}
-	} else if uploadType == "document" {
-		allowedExts = []string{".txt", ".pdf", ".ppt", ".pptx", ".xls", ".xlsx", ".doc", ".docx", ".rar", ".zip", ".7z"}
-	} else {
-		// 默认允许所有配置的类型
-		allowedExts = config.AppConfig.Upload.AllowedTypes
+	// 检查文件大小（大文件需使用分片上传）
+	if file.Size > config.AppConfig.Upload.MaxSize {
+		msg := fmt.Sprintf("文件大小超过限制（最大%dMB）", config.AppConfig.Upload.MaxSize/1024/1024)
+		if uploadType != "logo" {
+			msg += "，大文件请使用分片上传"
+		}
+		utils.BadRequest(c, msg)
+		return
 	}
 
-	allowed := false
-	for _, allowedExt := range allowedExts {
-		if ext == allowedExt {
-			allowed = true
-			break
-		}
+	if msg := checkUploadType(uploadType, ext); msg != "" {
+		utils.BadRequest(c, msg)
+		return
 	}
 
-	if !allowed {
-		if uploadType == "logo" {
-			utils.BadRequest(c, "图标只支持 png, jpg, jpeg, gif, webp, ico 格式")
-		} else if uploadType == "document" {
-			utils.BadRequest(c, "文件只支持 txt, pdf, ppt, pptx, xls, xlsx, doc, docx, rar, zip, 7z 格式")
-		} else {
-			utils.BadRequest(c, "不支持的文件类型")
-		}
+	// 读取文件内容，检查文件头是否与扩展名一致
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(src, config.AppConfig.Upload.MaxSize+1))
+	src.Close()
+	if err != nil {
+		utils.InternalServerError(c, "读取文件失败")
+		return
+	}
+	if err := utils.CheckFileContent(ext, data); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	// 图片处理：过滤SVG脚本、去除EXIF等元数据、图标缩放到标准尺寸
+	data, resized, err := processUploadedImage(uploadType, ext, data)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	// 生成唯一文件名
-	timestamp := time.Now().Format("20060102150405")
-	randomStr := fmt.Sprintf("%d", time.Now().UnixNano()%10000)
-	newFilename := fmt.Sprintf("%s_%s%s", timestamp, randomStr, ext)
+	// 按内容哈希命名，相同内容只保存一份
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	newFilename := hash + ext
 
 	// 确定保存路径
-	var savePath string
+	subDir := "files"
 	if uploadType == "logo" {
-		savePath = filepath.Join(config.AppConfig.Upload.Path, "logos", newFilename)
-		os.MkdirAll(filepath.Join(config.AppConfig.Upload.Path, "logos"), 0755)
-	} else {
-		savePath = filepath.Join(config.AppConfig.Upload.Path, "files", newFilename)
-		os.MkdirAll(filepath.Join(config.AppConfig.Upload.Path, "files"), 0755)
+		subDir = "logos"
 	}
+	key := subDir + "/" + newFilename
+	accessPath := storage.URLFromKey(key)
 
-	// 保存文件
-	if err := c.SaveUploadedFile(file, savePath); err != nil {
+	deduplicated, err := saveUploadObject(key, bytes.NewReader(data), int64(len(data)), detectMimeType(ext, data))
+	if err != nil {
+		log.Printf("保存上传文件失败 %s: %v", key, err)
 		utils.InternalServerError(c, "文件保存失败")
 		return
 	}
 
-	// 返回文件访问路径
-	var accessPath string
-	if uploadType == "logo" {
-		accessPath = fmt.Sprintf("/uploads/logos/%s", newFilename)
-	} else {
-		accessPath = fmt.Sprintf("/uploads/files/%s", newFilename)
+	// 登记上传记录（已登记的保留首次上传的信息）
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "开启事务失败")
+		return
+	}
+	defer tx.Rollback()
+
+	upload := &models.Upload{
+		Hash:         hash,
+		Path:         accessPath,
+		OriginalName: file.Filename,
+		Mime:         detectMimeType(ext, data),
+		Size:         int64(len(data)),
+		Uploader:     middleware.CurrentUsername(c),
+	}
+	if err := models.CreateUpload(tx, upload); err != nil {
+		utils.InternalServerError(c, "登记上传文件失败")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	// 为图标生成PNG和WebP缩略图，失败不影响上传结果
+	thumbnails := gin.H{}
+	if uploadType == "logo" && utils.IsImageExt(ext) {
+		sizes := config.AppConfig.Upload.ThumbnailSizes
+		var err error
+		if !deduplicated {
+			_, err = utils.GenerateThumbnails(key, data, sizes)
+		}
+		if err != nil {
+			log.Printf("生成缩略图失败 %s: %v", newFilename, err)
+		} else {
+			for _, size := range sizes {
+				urls := gin.H{}
+				for _, format := range utils.ThumbnailFormats {
+					urls[strings.TrimPrefix(format, ".")] = storage.URLFromKey(utils.ThumbnailPath(key, size, format))
+				}
+				thumbnails[strconv.Itoa(size)] = urls
+			}
+		}
 	}
 
 	utils.SuccessWithMessage(c, "上传成功", gin.H{
 		"filename":     file.Filename,
 		"name":         newFilename,
-		"size":         file.Size,
+		"size":         len(data),
 		"url":          accessPath,
 		"path":         accessPath,
 		"originalName": file.Filename,
+		"hash":         hash,
+		"deduplicated": deduplicated,
+		"resized":      resized,
+		"thumbnails":   thumbnails,
 	})
 }
 
+// checkUploadType 根据上传类型（logo、document 或 file）检查扩展名，不允许时返回错误提示
+func checkUploadType(uploadType, ext string) string {
+	var allowedExts []string
+	if uploadType == "logo" {
+		allowedExts = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".ico", ".svg"}
+	} else if uploadType == "document" {
+		allowedExts = []string{".txt", ".pdf", ".ppt", ".pptx", ".xls", ".xlsx", ".doc", ".docx", ".rar", ".zip", ".7z"}
+	} else {
+		// 默认允许所有配置的类型
+		allowedExts = config.AppConfig.Upload.AllowedTypes
+	}
+
+	for _, allowedExt := range allowedExts {
+		if ext == allowedExt {
+			return ""
+		}
+	}
+
+	if uploadType == "logo" {
+		return "图标只支持 png, jpg, jpeg, gif, webp, ico, svg 格式"
+	} else if uploadType == "document" {
+		return "文件只支持 txt, pdf, ppt, pptx, xls, xlsx, doc, docx, rar, zip, 7z 格式"
+	}
+	return "不支持的文件类型"
+}
+
+// saveUploadObject 保存上传文件到存储，返回是否已存在相同内容的文件
+// 已存在时仍重新写入一次以刷新修改时间，避免被当作未引用文件清理
+func saveUploadObject(key string, r io.Reader, size int64, contentType string) (bool, error) {
+	deduplicated := false
+	if _, err := storage.Default.Stat(key); err == nil {
+		deduplicated = true
+	}
+	if err := storage.Default.Put(key, r, size, contentType); err != nil {
+		return false, err
+	}
+	return deduplicated, nil
+}
+
+// detectMimeType 根据扩展名获取MIME类型，未知时根据内容判断
+func detectMimeType(ext string, data []byte) string {
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType
+	}
+	return http.DetectContentType(data)
+}
+
+// processUploadedImage 处理上传的图片，返回处理后的内容和是否缩放过
+// SVG过滤脚本和事件属性；位图去除元数据；图标超过标准尺寸时等比缩小
+func processUploadedImage(uploadType, ext string, data []byte) ([]byte, bool, error) {
+	if ext == ".svg" {
+		sanitized, err := utils.SanitizeSVG(data)
+		return sanitized, false, err
+	}
+	if !utils.IsImageExt(ext) {
+		return data, false, nil
+	}
+
+	data, err := utils.StripImageMetadata(ext, data)
+	if err != nil {
+		return nil, false, err
+	}
+	if uploadType != "logo" {
+		return data, false, nil
+	}
+	return utils.ResizeLogo(ext, data, config.AppConfig.Upload.LogoMaxSize)
+}
+
 // DeleteFile 删除文件
 func (h *UploadHandler) DeleteFile(c *gin.Context) {
 	// 支持 path 或 filename 参数
@@ -116,36 +241,78 @@ func (h *UploadHandler) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	var fullPath string
+	// 安全检查：只允许删除uploads目录下的文件，禁止路径穿越
+	if strings.Contains(filePath, "..") || strings.Contains(filename, "..") || strings.ContainsAny(filename, "/\\") {
+		utils.BadRequest(c, "无效的文件路径")
+		return
+	}
+
+	var accessPath string
 	if filePath != "" {
-		// 安全检查：只允许删除uploads目录下的文件
 		if !strings.HasPrefix(filePath, "/uploads/") {
 			utils.BadRequest(c, "无效的文件路径")
 			return
 		}
-		// 构建完整路径
-		fullPath = filepath.Join(".", filePath)
+		accessPath = filePath
 	} else {
-		// 根据文件名在uploads目录下搜索
-		// 先检查files目录，再检查logos目录
-		fullPath = filepath.Join(config.AppConfig.Upload.Path, "files", filename)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			fullPath = filepath.Join(config.AppConfig.Upload.Path, "logos", filename)
+		// 根据文件名查找，先检查files目录，再检查logos目录
+		accessPath = "/uploads/files/" + filename
+		if _, err := storage.Default.Stat("files/" + filename); err == storage.ErrNotExist {
+			accessPath = "/uploads/logos/" + filename
 		}
 	}
 
+	key, err := storage.KeyFromURL(accessPath)
+	if err != nil {
+		utils.BadRequest(c, "无效的文件路径")
+		return
+	}
+
 	// 检查文件是否存在
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		utils.NotFound(c, "文件不存在")
+	if _, err := storage.Default.Stat(key); err != nil {
+		if err == storage.ErrNotExist {
+			utils.NotFound(c, "文件不存在")
+		} else {
+			utils.InternalServerError(c, "获取文件信息失败")
+		}
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "开启事务失败")
+		return
+	}
+	defer tx.Rollback()
+
+	// 仍被站点或页面配置引用的文件不允许删除（在事务中检查，避免检查后又被引用）
+	refs, err := models.CountUploadReferences(tx, accessPath)
+	if err != nil {
+		utils.InternalServerError(c, "查询文件引用失败")
+		return
+	}
+	if refs > 0 {
+		utils.BadRequest(c, fmt.Sprintf("文件仍被%d处引用，请先修改引用它的站点或页面配置", refs))
+		return
+	}
+
+	if err := models.DeleteUpload(tx, accessPath); err != nil {
+		utils.InternalServerError(c, "删除上传记录失败")
+		return
+	}
+	if err := models.QueueFileRemoval(tx, accessPath); err != nil {
+		utils.InternalServerError(c, "登记待删除文件失败")
 		return
 	}
 
-	// 删除文件
-	if err := os.Remove(fullPath); err != nil {
-		utils.InternalServerError(c, "删除文件失败")
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
 		return
 	}
 
+	// 事务已提交，删除文件及其缩略图（删除失败的文件保留登记，下次重试）
+	utils.RemovePendingUploads(h.DB)
+
 	utils.SuccessWithMessage(c, "删除成功", nil)
 }
 
@@ -154,50 +321,67 @@ func (h *UploadHandler) ListFiles(c *gin.Context) {
 	uploadType := c.DefaultQuery("type", "all")
 	var fileList []gin.H
 
-	// 读取文件的辅助函数
-	readDir := func(dirPath string, urlPrefix string) {
-		files, err := os.ReadDir(dirPath)
+	// 上传记录（旧版本上传或备份恢复的文件可能没有记录）
+	uploads, err := models.GetAllUploads(h.DB)
+	if err != nil {
+		utils.InternalServerError(c, "获取上传记录失败")
+		return
+	}
+
+	// 列出目录下文件的辅助函数（不包含缩略图等子目录）
+	listDir := func(subDir string) {
+		objects, err := storage.Default.List(subDir + "/")
 		if err != nil {
+			log.Printf("列出上传文件失败 %s: %v", subDir, err)
 			return
 		}
 
-		for _, file := range files {
-			if file.IsDir() {
-				continue
-			}
-
-			info, err := file.Info()
-			if err != nil {
+		for _, object := range objects {
+			name := strings.TrimPrefix(object.Key, subDir+"/")
+			if strings.Contains(name, "/") {
 				continue
 			}
 
-			accessPath := fmt.Sprintf("%s%s", urlPrefix, file.Name())
+			accessPath := storage.URLFromKey(object.Key)
+			refs, _ := models.CountUploadReferences(h.DB, accessPath)
 
-			fileList = append(fileList, gin.H{
-				"name":        file.Name(),
-				"size":        info.Size(),
-				"modTime":     info.ModTime().Format("2006-01-02 15:04:05"),
-				"uploaded_at": info.ModTime().Format("2006-01-02 15:04:05"),
+			item := gin.H{
+				"name":        name,
+				"size":        object.Size,
+				"modTime":     object.ModTime.Format("2006-01-02 15:04:05"),
+				"uploaded_at": object.ModTime.Format("2006-01-02 15:04:05"),
 				"path":        accessPath,
 				"url":         accessPath,
-			})
+				"references":  refs,
+			}
+			if upload, ok := uploads[accessPath]; ok {
+				item["hash"] = upload.Hash
+				item["original_name"] = upload.OriginalName
+				item["mime"] = upload.Mime
+				item["uploader"] = upload.Uploader
+				item["visibility"] = upload.Visibility
+				item["download_count"] = upload.DownloadCount
+				item["last_download_at"] = upload.LastDownloadAt
+			}
+			fileList = append(fileList, item)
 		}
 	}
 
 	if uploadType == "logo" {
-		readDir(filepath.Join(config.AppConfig.Upload.Path, "logos"), "/uploads/logos/")
+		listDir("logos")
 	} else if uploadType == "file" {
-		readDir(filepath.Join(config.AppConfig.Upload.Path, "files"), "/uploads/files/")
+		listDir("files")
 	} else {
 		// all - 读取所有目录
-		readDir(filepath.Join(config.AppConfig.Upload.Path, "logos"), "/uploads/logos/")
-		readDir(filepath.Join(config.AppConfig.Upload.Path, "files"), "/uploads/files/")
+		listDir("logos")
+		listDir("files")
 	}
 
 	utils.Success(c, fileList)
 }
 
-// DownloadFile 下载文件
+// DownloadFile 下载文件（/api/download?path=/uploads/files/...）
+// 按文件的下载权限检查访问，以原始文件名下载，支持断点续传，并统计下载次数
 func (h *UploadHandler) DownloadFile(c *gin.Context) {
 	filePath := c.Query("path")
 	if filePath == "" {
@@ -205,43 +389,309 @@ func (h *UploadHandler) DownloadFile(c *gin.Context) {
 		return
 	}
 
-	// 安全检查
-	if !strings.HasPrefix(filePath, "/uploads/") {
+	// 安全检查：只提供 files 目录下的文件下载
+	key, err := storage.KeyFromURL(filePath)
+	if err != nil || !strings.HasPrefix(key, "files/") {
 		utils.BadRequest(c, "无效的文件路径")
 		return
 	}
+	accessPath := storage.URLFromKey(key)
 
-	// 构建完整路径
-	fullPath := filepath.Join(".", filePath)
-
-	// 检查文件是否存在
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		utils.NotFound(c, "文件不存在")
+	upload, ok := h.checkDownloadAccess(c, accessPath)
+	if !ok {
 		return
 	}
 
 	// 打开文件
-	file, err := os.Open(fullPath)
+	file, info, err := storage.Default.Get(key)
 	if err != nil {
-		utils.InternalServerError(c, "打开文件失败")
+		if err == storage.ErrNotExist {
+			utils.NotFound(c, "文件不存在")
+		} else {
+			utils.InternalServerError(c, "打开文件失败")
+		}
 		return
 	}
 	defer file.Close()
 
-	// 获取文件信息
-	fileInfo, err := file.Stat()
+	filename := path.Base(key)
+	if upload != nil && upload.OriginalName != "" {
+		filename = upload.OriginalName
+	}
+	c.Header("Content-Disposition", utils.ContentDisposition("attachment", filename))
+
+	// 断点续传的后续分段不重复计数
+	if upload != nil && isFullDownload(c.Request) {
+		if err := h.countDownload(accessPath); err != nil {
+			log.Printf("更新下载次数失败 %s: %v", accessPath, err)
+		}
+	}
+
+	serveObject(c, key, file, info)
+}
+
+// ServeFile 提供上传文件访问（/uploads/*filepath）
+// files 目录下设置了下载权限的文件同样需要登录或签名；
+// 存储支持直接访问时（如开启预签名的S3）重定向到临时地址，否则由服务端读取后返回
+func (h *UploadHandler) ServeFile(c *gin.Context) {
+	key, err := storage.CleanKey(c.Param("filepath"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if strings.HasPrefix(key, "files/") {
+		if _, ok := h.checkDownloadAccess(c, storage.URLFromKey(key)); !ok {
+			return
+		}
+	}
+
+	if url, err := storage.Default.URL(key); err == nil && url != "" {
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	file, info, err := storage.Default.Get(key)
+	if err != nil {
+		if err == storage.ErrNotExist {
+			c.Status(http.StatusNotFound)
+		} else {
+			log.Printf("读取上传文件失败 %s: %v", key, err)
+			c.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+	defer file.Close()
+
+	serveObject(c, key, file, info)
+}
+
+// serveObject 返回存储对象内容，可Seek的对象支持Range和条件请求
+func serveObject(c *gin.Context, key string, file io.ReadCloser, info *storage.ObjectInfo) {
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = storage.ContentType(key)
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, seeker)
+		return
+	}
+
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	if !info.ModTime.IsZero() {
+		c.Header("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusOK)
+	if c.Request.Method != http.MethodHead {
+		io.Copy(c.Writer, file)
+	}
+}
+
+// checkDownloadAccess 按上传记录的下载权限检查访问，无权限时写入响应并返回 false
+// 没有上传记录的文件（旧版本上传）视为公开
+func (h *UploadHandler) checkDownloadAccess(c *gin.Context, accessPath string) (*models.Upload, bool) {
+	upload, err := models.GetUploadByPath(h.DB, accessPath)
+	if err == sql.ErrNoRows {
+		return nil, true
+	}
 	if err != nil {
 		utils.InternalServerError(c, "获取文件信息失败")
+		return nil, false
+	}
+
+	switch {
+	case upload.Visibility == "" || upload.Visibility == models.VisibilityPublic || middleware.IsLoggedIn(c, h.DB):
+		return upload, true
+	case upload.Visibility == models.VisibilitySigned:
+		if utils.VerifyDownloadSignature(accessPath, c.Query("expires"), c.Query("sig")) {
+			return upload, true
+		}
+		utils.Error(c, http.StatusForbidden, "下载链接无效或已过期")
+	default:
+		utils.Unauthorized(c, "请登录后下载")
+	}
+	return nil, false
+}
+
+// isFullDownload 判断是否是从头开始的下载（HEAD请求和从中间开始的Range请求不计数）
+func isFullDownload(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	rangeHeader := strings.TrimSpace(r.Header.Get("Range"))
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
+
+// countDownload 下载次数加一
+func (h *UploadHandler) countDownload(accessPath string) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := models.IncrementDownloadCount(tx, accessPath); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateFileVisibility 设置文件的下载权限（public、login 或 signed）
+func (h *UploadHandler) UpdateFileVisibility(c *gin.Context) {
+	var req struct {
+		Path       string `json:"path"`
+		Visibility string `json:"visibility"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求参数错误")
+		return
+	}
+
+	key, err := storage.KeyFromURL(req.Path)
+	if err != nil || !strings.HasPrefix(key, "files/") {
+		utils.BadRequest(c, "只能设置 /uploads/files/ 下文件的下载权限")
+		return
+	}
+	switch req.Visibility {
+	case models.VisibilityPublic, models.VisibilityLogin, models.VisibilitySigned:
+	default:
+		utils.BadRequest(c, "下载权限只能是 public、login 或 signed")
 		return
 	}
 
-	// 设置响应头
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(filePath)))
-	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.InternalServerError(c, "开启事务失败")
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.UpdateUploadVisibility(tx, storage.URLFromKey(key), req.Visibility); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(c, "文件不存在或没有上传记录")
+		} else {
+			utils.InternalServerError(c, "更新下载权限失败")
+		}
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.InternalServerError(c, "提交事务失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "下载权限已更新", nil)
+}
+
+// CreateDownloadLink 生成带签名的限时下载链接
+// expires_in 为有效期（秒），默认1小时，最长30天
+func (h *UploadHandler) CreateDownloadLink(c *gin.Context) {
+	var req struct {
+		Path      string `json:"path"`
+		ExpiresIn int64  `json:"expires_in"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求参数错误")
+		return
+	}
+
+	key, err := storage.KeyFromURL(req.Path)
+	if err != nil || !strings.HasPrefix(key, "files/") {
+		utils.BadRequest(c, "只能为 /uploads/files/ 下的文件生成下载链接")
+		return
+	}
+	if req.ExpiresIn == 0 {
+		req.ExpiresIn = 3600
+	}
+	if req.ExpiresIn < 0 || req.ExpiresIn > 30*24*3600 {
+		utils.BadRequest(c, "有效期必须在1秒到30天之间")
+		return
+	}
+	if _, err := storage.Default.Stat(key); err != nil {
+		if err == storage.ErrNotExist {
+			utils.NotFound(c, "文件不存在")
+		} else {
+			utils.InternalServerError(c, "获取文件信息失败")
+		}
+		return
+	}
+
+	expires := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+	downloadURL, err := utils.SignedDownloadURL(storage.URLFromKey(key), expires)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{
+		"url":        downloadURL,
+		"expires_at": expires.Format("2006-01-02 15:04:05"),
+	})
+}
+
+// CollectGarbage 清理未被引用的上传文件
+// dry_run 为 true 时只返回将被清理的文件列表，不做修改
+func (h *UploadHandler) CollectGarbage(c *gin.Context) {
+	var req struct {
+		DryRun bool `json:"dry_run"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, "请求参数错误")
+			return
+		}
+	}
+	if c.Query("dry_run") == "true" || c.Query("dry_run") == "1" {
+		req.DryRun = true
+	}
+
+	report, err := utils.CollectOrphanedUploads(h.DB, req.DryRun)
+	if err != nil {
+		utils.InternalServerError(c, "清理上传文件失败")
+		return
+	}
+
+	message := fmt.Sprintf("清理完成：%d 个文件移入隔离区，%d 个文件永久删除", len(report.Orphans), len(report.Purged))
+	if req.DryRun {
+		message = fmt.Sprintf("预览：%d 个文件将移入隔离区，%d 个文件将永久删除", len(report.Orphans), len(report.Purged))
+	}
+	utils.SuccessWithMessage(c, message, report)
+}
+
+// ListQuarantine 列出隔离区中的上传文件（清理时移入，保留期满前可以恢复）
+func (h *UploadHandler) ListQuarantine(c *gin.Context) {
+	files, err := utils.ListQuarantinedUploads()
+	if err != nil {
+		utils.InternalServerError(c, "读取隔离区失败")
+		return
+	}
+
+	utils.Success(c, files)
+}
+
+// RestoreQuarantined 把隔离区中的文件移回上传目录（图标连同缩略图），下载权限和下载次数不变
+func (h *UploadHandler) RestoreQuarantined(c *gin.Context) {
+	var req struct {
+		Path string `json:"path" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求参数错误")
+		return
+	}
+
+	if err := utils.RestoreQuarantinedUpload(req.Path); err != nil {
+		switch err {
+		case storage.ErrInvalidKey:
+			utils.BadRequest(c, "无效的文件路径")
+		case storage.ErrNotExist:
+			utils.NotFound(c, "隔离区中没有该文件")
+		default:
+			log.Printf("恢复隔离区文件失败 %s: %v", req.Path, err)
+			utils.InternalServerError(c, "恢复文件失败")
+		}
+		return
+	}
 
-	// 发送文件
-	io.Copy(c.Writer, file)
+	utils.SuccessWithMessage(c, "恢复成功", gin.H{"path": req.Path})
 }
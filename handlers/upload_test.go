@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"database/sql"
+	"nav-admin/config"
+	"nav-admin/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// putHandlerTestUpload 写入上传文件（修改时间在一小时前）并登记上传记录，返回文件路径
+func putHandlerTestUpload(t *testing.T, db *sql.DB, key string) string {
+	t.Helper()
+	file := filepath.Join(config.AppConfig.Upload.Path, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(key), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(file, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO uploads (hash, path) VALUES (?, ?)", key, "/uploads/"+key); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestDeleteFile(t *testing.T) {
+	db := newMergeTestDB(t)
+	unused := putHandlerTestUpload(t, db, "files/unused.txt")
+	referenced := putHandlerTestUpload(t, db, "files/referenced.txt")
+	mergeTestDoc(t, db, parseTestDoc(t, `[
+		{"_id": "a", "classify": "A", "icon": "", "sites": [
+			{"name": "R", "href": "/uploads/files/referenced.txt", "desc": "", "logo": ""}
+		]}
+	]`), false)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := &UploadHandler{DB: db}
+	r.DELETE("/upload", h.DeleteFile)
+	del := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/upload?path="+path, nil))
+		return w.Code
+	}
+
+	if code := del("/uploads/files/referenced.txt"); code != http.StatusBadRequest {
+		t.Errorf("delete referenced file: status %d, want 400", code)
+	}
+	if _, err := os.Stat(referenced); err != nil {
+		t.Errorf("referenced file removed: %v", err)
+	}
+
+	if code := del("/uploads/files/unused.txt"); code != http.StatusOK {
+		t.Fatalf("delete unused file: status %d", code)
+	}
+	if _, err := os.Stat(unused); !os.IsNotExist(err) {
+		t.Errorf("file still exists: %v", err)
+	}
+	if _, err := models.GetUploadByPath(db, "/uploads/files/unused.txt"); err != sql.ErrNoRows {
+		t.Errorf("upload record: %v, want sql.ErrNoRows", err)
+	}
+	var pending int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pending_file_removals").Scan(&pending); err != nil || pending != 0 {
+		t.Errorf("pending removals = %d, %v", pending, err)
+	}
+
+	if code := del("/uploads/files/unused.txt"); code != http.StatusNotFound {
+		t.Errorf("delete missing file: status %d, want 404", code)
+	}
+}
@@ -0,0 +1,82 @@
+package importers
+
+import (
+	"errors"
+	"nav-admin/navdoc"
+	"strings"
+)
+
+// heimdall Heimdall 应用面板导出的JSON（应用数组，或 {"items": [...]}）：
+//
+//	[{"title": "Plex", "url": "https://plex.example.com", "description": "...", "tags": ["Media"]}]
+//
+// 应用按第一个标签分类，没有标签的放入“Heimdall”分类
+type heimdall struct{}
+
+func init() {
+	Register("heimdall", heimdall{})
+}
+
+// heimdallDefaultCategory 没有标签的应用所在的分类
+const heimdallDefaultCategory = "Heimdall"
+
+// Description 格式说明
+func (heimdall) Description() string {
+	return "Heimdall 应用面板导出的JSON：应用按标签分类"
+}
+
+// Parse 解析 Heimdall 导出文件
+func (heimdall) Parse(data []byte) (*navdoc.Document, error) {
+	root, err := decodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	if obj, ok := root.(map[string]interface{}); ok {
+		root = obj["items"]
+	}
+	items, ok := root.([]interface{})
+	if !ok {
+		return nil, errors.New("Heimdall 导出数据应为应用数组")
+	}
+
+	b := newBuilder("heimdall")
+	for _, i := range items {
+		item, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		b.add(heimdallTag(item), "", navdoc.Site{
+			Name: str(item, "title", "name"),
+			Href: str(item, "url", "link"),
+			Desc: str(item, "description", "appdescription"),
+			Logo: str(item, "icon"),
+		})
+	}
+	return b.result()
+}
+
+// heimdallTag 应用的第一个标签（tags 可以是数组或逗号分隔的字符串）
+func heimdallTag(item map[string]interface{}) string {
+	var tags []string
+	switch v := item["tags"].(type) {
+	case []interface{}:
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				tags = append(tags, s)
+			} else if m, ok := t.(map[string]interface{}); ok {
+				tags = append(tags, str(m, "title", "name"))
+			}
+		}
+	case string:
+		tags = strings.Split(v, ",")
+	}
+	if tag := str(item, "tag"); tag != "" {
+		tags = append(tags, tag)
+	}
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			return tag
+		}
+	}
+	return heimdallDefaultCategory
+}
@@ -0,0 +1,113 @@
+package importers
+
+import (
+	"errors"
+	"nav-admin/navdoc"
+	"regexp"
+	"strings"
+)
+
+// homepage Homepage（gethomepage）的 services.yaml 或 bookmarks.yaml，每个分组导入为一个分类：
+//
+//	# services.yaml（服务）和 bookmarks.yaml（书签）
+//	- My First Group:
+//	    - My First Service:
+//	        href: http://localhost/
+//	        description: Homepage is awesome
+//	        icon: sonarr.png
+//	- Developer:
+//	    - Github:
+//	        - abbr: GH
+//	          href: https://github.com/
+//
+// 嵌套的分组导入为“上级 / 下级”分类
+type homepage struct{}
+
+func init() {
+	Register("homepage", homepage{})
+}
+
+// homepageIconCDN Homepage 中只写文件名的图标来自 dashboard-icons 图标库
+const homepageIconCDN = "https://cdn.jsdelivr.net/gh/walkxcode/dashboard-icons/"
+
+// homepageIconFile 图标库中的图标文件名（如 sonarr.png）
+var homepageIconFile = regexp.MustCompile(`^[a-z0-9\-]+\.(png|svg|webp)$`)
+
+// Description 格式说明
+func (homepage) Description() string {
+	return "Homepage（gethomepage）的 services.yaml 或 bookmarks.yaml"
+}
+
+// Parse 解析 services.yaml / bookmarks.yaml
+func (homepage) Parse(data []byte) (*navdoc.Document, error) {
+	root, err := decodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	groups, ok := root.([]interface{})
+	if !ok {
+		return nil, errors.New("Homepage 配置应为分组数组")
+	}
+
+	b := newBuilder("homepage")
+	for _, g := range groups {
+		group, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, name := range sortedKeys(group) {
+			if entries, ok := group[name].([]interface{}); ok {
+				homepageEntries(b, name, entries)
+			}
+		}
+	}
+	return b.result()
+}
+
+// homepageEntries 添加分组中的服务/书签，值为分组数组的条目是嵌套分组
+func homepageEntries(b *builder, group string, entries []interface{}) {
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, name := range sortedKeys(entry) {
+			switch v := entry[name].(type) {
+			case map[string]interface{}:
+				b.add(group, "", homepageSite(name, v))
+			case []interface{}:
+				// 书签的属性写在只有一个元素的数组中
+				if props, ok := firstWithHref(v); ok {
+					b.add(group, "", homepageSite(name, props))
+				} else {
+					homepageEntries(b, group+" / "+name, v)
+				}
+			}
+		}
+	}
+}
+
+// firstWithHref 返回数组中第一个带 href 的对象
+func firstWithHref(list []interface{}) (map[string]interface{}, bool) {
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok && str(m, "href") != "" {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// homepageSite 服务/书签转换为站点
+func homepageSite(name string, props map[string]interface{}) navdoc.Site {
+	logo := str(props, "icon")
+	if homepageIconFile.MatchString(logo) {
+		ext := logo[strings.LastIndex(logo, ".")+1:]
+		logo = homepageIconCDN + ext + "/" + logo
+	}
+	return navdoc.Site{
+		Name: name,
+		Href: str(props, "href"),
+		Desc: str(props, "description"),
+		Logo: logo,
+	}
+}
@@ -0,0 +1,66 @@
+package importers
+
+import (
+	"errors"
+	"nav-admin/navdoc"
+)
+
+// homer Homer 仪表盘的 config.yml，每个 services 分组导入为一个分类：
+//
+//	services:
+//	  - name: "Applications"
+//	    icon: "fas fa-cloud"
+//	    items:
+//	      - name: "Awesome app"
+//	        subtitle: "Bookmark example"
+//	        url: "https://www.reddit.com/r/selfhosted/"
+//	        logo: "assets/tools/sample.png"
+type homer struct{}
+
+func init() {
+	Register("homer", homer{})
+}
+
+// Description 格式说明
+func (homer) Description() string {
+	return "Homer 仪表盘的 config.yml：services 分组和 items"
+}
+
+// Parse 解析 config.yml
+func (homer) Parse(data []byte) (*navdoc.Document, error) {
+	root, err := decodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("Homer 配置应为对象")
+	}
+	services, ok := cfg["services"].([]interface{})
+	if !ok {
+		return nil, errors.New("Homer 配置中没有 services")
+	}
+
+	b := newBuilder("homer")
+	for _, s := range services {
+		group, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, icon := str(group, "name"), str(group, "icon")
+		items, _ := group["items"].([]interface{})
+		for _, i := range items {
+			item, ok := i.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			b.add(name, icon, navdoc.Site{
+				Name: str(item, "name"),
+				Href: str(item, "url"),
+				Desc: str(item, "subtitle"),
+				Logo: str(item, "logo"),
+			})
+		}
+	}
+	return b.result()
+}
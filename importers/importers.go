@@ -0,0 +1,171 @@
+// Package importers 其他导航项目数据格式的导入器
+//
+// 每个导入器把一种外部格式转换为 navdoc.Document（只包含分类和站点），在 init 中用 Register 按名称注册。
+// 转换结果只做结构映射，字段的截断和校验由调用方（handlers.ImportExternal）统一处理。
+package importers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"nav-admin/navdoc"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNoSites 文件中没有找到站点
+var ErrNoSites = errors.New("文件中没有找到站点")
+
+// Importer 外部数据格式的导入器
+type Importer interface {
+	// Description 格式说明（在后台页面显示）
+	Description() string
+	// Parse 解析文件内容，返回分类和站点
+	Parse(data []byte) (*navdoc.Document, error)
+}
+
+// Info 已注册的导入器信息
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Importer{}
+)
+
+// Register 按名称注册导入器，名称重复时 panic
+func Register(name string, imp Importer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("importers: 重复注册 " + name)
+	}
+	registry[name] = imp
+}
+
+// Get 按名称获取导入器
+func Get(name string) (Importer, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	imp, ok := registry[name]
+	return imp, ok
+}
+
+// List 返回所有已注册的导入器（按名称排序）
+func List() []Info {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Info, 0, len(registry))
+	for name, imp := range registry {
+		list = append(list, Info{Name: name, Description: imp.Description()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// builder 按分类名称收集站点（分类按第一次出现的顺序）
+type builder struct {
+	prefix string
+	doc    *navdoc.Document
+	index  map[string]int
+}
+
+// newBuilder 创建 builder，prefix 用于生成分类 _id
+func newBuilder(prefix string) *builder {
+	return &builder{prefix: prefix, doc: &navdoc.Document{Version: navdoc.Version}, index: map[string]int{}}
+}
+
+// add 向分类中添加站点，分类不存在时创建；名称和链接都为空的站点忽略
+func (b *builder) add(category, icon string, site navdoc.Site) {
+	site.Name = strings.TrimSpace(site.Name)
+	site.Href = strings.TrimSpace(site.Href)
+	if site.Name == "" && site.Href == "" {
+		return
+	}
+	i := b.category(category, icon)
+	b.doc.Categories[i].Sites = append(b.doc.Categories[i].Sites, site)
+}
+
+// category 返回分类的位置，不存在时创建
+func (b *builder) category(name, icon string) int {
+	name = strings.TrimSpace(name)
+	if i, ok := b.index[name]; ok {
+		return i
+	}
+	b.index[name] = len(b.doc.Categories)
+	b.doc.Categories = append(b.doc.Categories, navdoc.Category{
+		ID:       CategoryID(b.prefix, name),
+		Classify: name,
+		Icon:     themifyIcon(icon),
+		Sites:    []navdoc.Site{},
+	})
+	return b.index[name]
+}
+
+// result 返回结果，没有站点时返回 ErrNoSites
+func (b *builder) result() (*navdoc.Document, error) {
+	for _, cat := range b.doc.Categories {
+		if len(cat.Sites) > 0 {
+			return b.doc, nil
+		}
+	}
+	return nil, ErrNoSites
+}
+
+// CategoryID 由导入器名称和分类名称生成分类 _id（重复导入同一文件时 _id 相同，可合并导入）
+func CategoryID(prefix, name string) string {
+	sum := sha1.Sum([]byte(name))
+	return prefix + "-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// themifyIcon 只保留 Themify 图标（其他项目常用的 Font Awesome 等图标无法使用）
+func themifyIcon(icon string) string {
+	icon = strings.TrimSpace(icon)
+	if strings.HasPrefix(icon, "ti-") {
+		return icon
+	}
+	return ""
+}
+
+// str 取 YAML/JSON 对象中的字符串字段（数字等标量转为字符串），不存在时返回空字符串
+func str(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case nil:
+		case map[string]interface{}, []interface{}:
+		default:
+			return strings.TrimSpace(fmt.Sprint(v))
+		}
+	}
+	return ""
+}
+
+// decodeYAML 解析 YAML 或 JSON（JSON 是 YAML 的子集），对象解析为 map[string]interface{}
+func decodeYAML(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(bytes.TrimPrefix(data, []byte("\ufeff")), &v); err != nil {
+		return nil, errors.New("文件格式错误: " + err.Error())
+	}
+	return v, nil
+}
+
+// sortedKeys 返回对象的键（排序后，保证结果稳定）
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
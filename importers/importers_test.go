@@ -0,0 +1,131 @@
+package importers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCategory 期望的分类：名称、图标和站点（"名称 链接"）
+type testCategory struct {
+	name  string
+	icon  string
+	sites []string
+}
+
+// 每个导入器的 testdata/<名称>.json 和 testdata/<名称>.yaml 内容相同，解析结果都应与 categories 一致
+var importerTests = map[string]struct {
+	categories []testCategory
+	malformed  map[string]string // 错误的输入 -> 错误信息中应包含的内容
+}{
+	"webstack": {
+		categories: []testCategory{
+			{"常用推荐", "ti-star", []string{"Google https://www.google.com/", "GitHub https://github.com/"}},
+			{"社区资讯 / 资讯", "", []string{"V2EX https://www.v2ex.com/"}},
+			{"社区资讯 / 博客", "", []string{"Go Blog https://go.dev/blog/"}},
+		},
+		malformed: map[string]string{
+			"- taxonomy: [":       "文件格式错误",
+			`{"taxonomy": "a"}`:   "WebStack 数据应为分类数组",
+			`[{"taxonomy": "a"}]`: ErrNoSites.Error(),
+		},
+	},
+	"homer": {
+		categories: []testCategory{
+			{"Applications", "", []string{"Awesome app https://www.reddit.com/r/selfhosted/", "Another one https://www.reddit.com/r/awesome/"}},
+			{"Monitoring", "ti-pulse", []string{"Grafana https://grafana.example.com"}},
+		},
+		malformed: map[string]string{
+			"services: [":                  "文件格式错误",
+			`[{"name": "a"}]`:              "Homer 配置应为对象",
+			"title: Demo":                  "Homer 配置中没有 services",
+			"services:\n  - name: Empty\n": ErrNoSites.Error(),
+		},
+	},
+	"homepage": {
+		categories: []testCategory{
+			{"My First Group", "", []string{"My First Service http://localhost/", "Another Service http://localhost:8080/"}},
+			{"Developer", "", []string{"Github https://github.com/"}},
+			{"Media / Streaming", "", []string{"Plex https://plex.example.com"}},
+		},
+		malformed: map[string]string{
+			"- Group: [":        "文件格式错误",
+			"Group: []":         "Homepage 配置应为分组数组",
+			"- Group:\n    - a": ErrNoSites.Error(),
+		},
+	},
+	"heimdall": {
+		categories: []testCategory{
+			{"Media", "", []string{"Plex https://plex.example.com", "Jellyfin https://jellyfin.example.com"}},
+			{"Network", "", []string{"Router http://192.168.1.1"}},
+			{heimdallDefaultCategory, "", []string{"Notes https://notes.example.com"}},
+		},
+		malformed: map[string]string{
+			`[{"title": "a"`:    "文件格式错误",
+			`{"items": {}}`:     "Heimdall 导出数据应为应用数组",
+			`[{"tags": ["a"]}]`: ErrNoSites.Error(),
+		},
+	},
+}
+
+func TestImporters(t *testing.T) {
+	for _, info := range List() {
+		tt, ok := importerTests[info.Name]
+		if !ok {
+			t.Errorf("importer %q has no test fixtures", info.Name)
+			continue
+		}
+		imp, _ := Get(info.Name)
+
+		for _, ext := range []string{"json", "yaml"} {
+			file := filepath.Join("testdata", info.Name+"."+ext)
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := imp.Parse(data)
+			if err != nil {
+				t.Errorf("%s: %v", file, err)
+				continue
+			}
+			if len(doc.Categories) != len(tt.categories) {
+				t.Errorf("%s: %d categories, want %d", file, len(doc.Categories), len(tt.categories))
+				continue
+			}
+			for i, want := range tt.categories {
+				cat := doc.Categories[i]
+				if cat.Classify != want.name || cat.Icon != want.icon {
+					t.Errorf("%s: category %d = %q (icon %q), want %q (icon %q)", file, i, cat.Classify, cat.Icon, want.name, want.icon)
+				}
+				if cat.ID != CategoryID(info.Name, want.name) {
+					t.Errorf("%s: category %q _id = %q", file, want.name, cat.ID)
+				}
+				var sites []string
+				for _, site := range cat.Sites {
+					sites = append(sites, site.Name+" "+site.Href)
+				}
+				if strings.Join(sites, "\n") != strings.Join(want.sites, "\n") {
+					t.Errorf("%s: category %q sites:\n%s\nwant:\n%s", file, want.name, strings.Join(sites, "\n"), strings.Join(want.sites, "\n"))
+				}
+			}
+		}
+
+		for input, want := range tt.malformed {
+			if _, err := imp.Parse([]byte(input)); err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: Parse(%q) = %v, want error containing %q", info.Name, input, err, want)
+			}
+		}
+	}
+}
+
+func TestHomepageIconCDN(t *testing.T) {
+	doc, err := homepage{}.Parse([]byte("- Group:\n    - A:\n        href: http://a\n        icon: sonarr.png\n    - B:\n        href: http://b\n        icon: https://b/icon.png\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites := doc.Categories[0].Sites
+	if sites[0].Logo != homepageIconCDN+"png/sonarr.png" || sites[1].Logo != "https://b/icon.png" {
+		t.Errorf("logos = %q, %q", sites[0].Logo, sites[1].Logo)
+	}
+}
@@ -0,0 +1,86 @@
+package importers
+
+import (
+	"errors"
+	"nav-admin/navdoc"
+)
+
+// webstack WebStack 系列导航（WebStack-Hugo、WebStack-Jekyll 等）的 webstack.yml 或同结构的JSON：
+//
+//	# webstack.yml
+//	- taxonomy: 常用推荐
+//	  links:
+//	    - title: Google
+//	      url: https://www.google.com/
+//	      description: ...
+//	      logo: google.png
+//	- taxonomy: 社区资讯
+//	  list:
+//	    - term: 资讯
+//	      links: [...]
+//
+// 有二级分类（list）时每个 term 导入为“一级 / 二级”分类
+type webstack struct{}
+
+func init() {
+	Register("webstack", webstack{})
+}
+
+// Description 格式说明
+func (webstack) Description() string {
+	return "WebStack 导航的 webstack.yml（或同结构的JSON）：taxonomy / term / links"
+}
+
+// Parse 解析 webstack.yml
+func (webstack) Parse(data []byte) (*navdoc.Document, error) {
+	root, err := decodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := root.([]interface{})
+	if !ok {
+		return nil, errors.New("WebStack 数据应为分类数组")
+	}
+
+	b := newBuilder("webstack")
+	for _, item := range items {
+		group, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		taxonomy := str(group, "taxonomy", "name")
+		icon := str(group, "icon")
+		webstackLinks(b, taxonomy, icon, group["links"])
+
+		terms, _ := group["list"].([]interface{})
+		for _, t := range terms {
+			term, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := str(term, "term", "name")
+			if taxonomy != "" {
+				name = taxonomy + " / " + name
+			}
+			webstackLinks(b, name, icon, term["links"])
+		}
+	}
+	return b.result()
+}
+
+// webstackLinks 添加 links 中的站点
+func webstackLinks(b *builder, category, icon string, links interface{}) {
+	list, _ := links.([]interface{})
+	for _, l := range list {
+		link, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		b.add(category, icon, navdoc.Site{
+			Name: str(link, "title", "name"),
+			Href: str(link, "url", "href"),
+			Desc: str(link, "description", "desc"),
+			Logo: str(link, "logo"),
+		})
+	}
+}
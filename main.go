@@ -9,6 +9,7 @@ import (
 	"nav-admin/config"
 	"nav-admin/handlers"
 	"nav-admin/middleware"
+	"nav-admin/storage"
 	"nav-admin/utils"
 
 	"github.com/gin-gonic/gin"
@@ -26,6 +27,11 @@ func main() {
 	// 初始化配置
 	config.Init()
 
+	// 初始化上传文件存储（本地目录或S3兼容存储）
+	if err := storage.Init(); err != nil {
+		log.Fatal("存储初始化失败:", err)
+	}
+
 	// 初始化数据库
 	var err error
 	db, err = utils.InitDB(config.AppConfig.Database.Path)
@@ -34,6 +40,28 @@ func main() {
 	}
 	defer db.Close()
 
+	// 启动公告调度器（公告到期/生效时自动更新nav.json）
+	utils.StartAnnouncementScheduler(db)
+
+	// 启动上传文件定时清理（设置了 UPLOAD_GC_INTERVAL 时）
+	utils.StartUploadGC(db)
+
+	// 删除上次运行时登记但未能删除的上传文件
+	go utils.RemovePendingUploads(db)
+
+	// 启动未完成分片上传的定时清理
+	utils.StartChunkCleanup(db)
+
+	// 启动定时备份（设置了 BACKUP_SCHEDULE 时）
+	if err := utils.StartBackupScheduler(db); err != nil {
+		log.Fatal("定时备份配置错误:", err)
+	}
+
+	// 加载图标白名单（用于校验分类图标）
+	if css, err := staticFS.ReadFile("static/themify-icons.css"); err == nil {
+		log.Printf("已加载 %d 个图标", utils.LoadIconWhitelist(css))
+	}
+
 	// 设置Gin模式
 	gin.SetMode(config.AppConfig.Server.Mode)
 
@@ -49,18 +77,20 @@ func main() {
 	staticSubFS, _ := fs.Sub(staticFS, "static")
 	r.StaticFS("/static", http.FS(staticSubFS))
 
-	// 上传文件服务
-	r.Static("/uploads", config.AppConfig.Upload.Path)
-
 	// 初始化处理器
 	authHandler := &handlers.AuthHandler{DB: db}
 	categoryHandler := &handlers.CategoryHandler{DB: db}
 	siteHandler := &handlers.SiteHandler{DB: db}
+	tagHandler := &handlers.TagHandler{DB: db}
 	announcementHandler := &handlers.AnnouncementHandler{DB: db}
-	uploadHandler := &handlers.UploadHandler{}
+	uploadHandler := &handlers.UploadHandler{DB: db}
 	navHandler := &handlers.NavHandler{DB: db}
 	backupHandler := &handlers.BackupHandler{DB: db}
 
+	// 上传文件服务（从配置的存储读取）
+	r.GET("/uploads/*filepath", uploadHandler.ServeFile)
+	r.HEAD("/uploads/*filepath", uploadHandler.ServeFile)
+
 	// 前端页面路由
 	r.GET("/", func(c *gin.Context) {
 		data, _ := templatesFS.ReadFile("templates/index.html")
@@ -83,11 +113,14 @@ func main() {
 		// 公开接口
 		api.POST("/login", authHandler.Login)
 		api.GET("/check-auth", authHandler.CheckAuth)
-		api.GET("/nav", navHandler.GetNavData) // 获取导航数据（前端展示用）
+		api.GET("/nav", navHandler.GetNavData)           // 获取导航数据（前端展示用）
+		api.GET("/nav/schema", navHandler.GetSchema)     // 导航数据文档格式（JSON Schema）
+		api.GET("/download", uploadHandler.DownloadFile) // 下载文件（按文件的下载权限检查）
+		api.HEAD("/download", uploadHandler.DownloadFile)
 
 		// 需要认证的管理接口
 		admin := api.Group("/admin")
-		admin.Use(middleware.AuthMiddleware())
+		admin.Use(middleware.AuthMiddleware(db))
 		{
 			// 认证相关
 			admin.POST("/logout", authHandler.Logout)
@@ -108,6 +141,20 @@ func main() {
 			admin.PUT("/sites/:id", siteHandler.Update)
 			admin.DELETE("/sites/:id", siteHandler.Delete)
 			admin.PUT("/sites/sort", siteHandler.UpdateSort)
+			admin.POST("/sites/metadata", siteHandler.FetchMetadata)
+			admin.GET("/sites/duplicates", siteHandler.GetDuplicates)
+			admin.POST("/sites/duplicates/merge", siteHandler.MergeDuplicates)
+			admin.POST("/sites/import", siteHandler.ImportSites)
+			admin.POST("/sites/bulk", siteHandler.Bulk)
+			admin.PUT("/sites/:id/move", siteHandler.Move)
+			admin.GET("/layout", siteHandler.GetLayout)
+			admin.PUT("/layout", siteHandler.UpdateLayout)
+
+			// 标签管理
+			admin.GET("/tags", tagHandler.GetAll)
+			admin.POST("/tags", tagHandler.Create)
+			admin.PUT("/tags/:id", tagHandler.Update)
+			admin.DELETE("/tags/:id", tagHandler.Delete)
 
 			// 公告管理
 			admin.GET("/announcements", announcementHandler.GetAll)
@@ -128,14 +175,36 @@ func main() {
 			admin.POST("/upload", uploadHandler.UploadFile)
 			admin.DELETE("/upload", uploadHandler.DeleteFile)
 			admin.GET("/files", uploadHandler.ListFiles)
+			admin.PUT("/files/visibility", uploadHandler.UpdateFileVisibility)
+			admin.POST("/files/download-link", uploadHandler.CreateDownloadLink)
+			admin.POST("/uploads/gc", uploadHandler.CollectGarbage)
+			admin.GET("/uploads/quarantine", uploadHandler.ListQuarantine)
+			admin.POST("/uploads/quarantine/restore", uploadHandler.RestoreQuarantined)
+
+			// 分片上传（大文件，支持断点续传）
+			admin.POST("/upload/chunked", uploadHandler.InitChunkedUpload)
+			admin.GET("/upload/chunked/:id", uploadHandler.GetChunkedUpload)
+			admin.PUT("/upload/chunked/:id/chunks/:index", uploadHandler.PutChunk)
+			admin.POST("/upload/chunked/:id/complete", uploadHandler.CompleteChunkedUpload)
+			admin.DELETE("/upload/chunked/:id", uploadHandler.AbortChunkedUpload)
 
 			// 数据导入导出
 			admin.GET("/export", navHandler.ExportData)
 			admin.POST("/import", navHandler.ImportData)
+			admin.POST("/import/bookmarks", navHandler.ImportBookmarks)
+			admin.GET("/import/formats", navHandler.GetImportFormats)
+			admin.POST("/import/external", navHandler.ImportExternal)
 
 			// 完整备份（包含上传文件的zip）
 			admin.GET("/backup/export", backupHandler.ExportBackup)
 			admin.POST("/backup/import", backupHandler.ImportBackup)
+
+			// 服务器上保存的备份（定时备份/手动创建）
+			admin.GET("/backups", backupHandler.ListStoredBackups)
+			admin.POST("/backups", backupHandler.CreateStoredBackup)
+			admin.GET("/backups/:name", backupHandler.DownloadStoredBackup)
+			admin.POST("/backups/:name/restore", backupHandler.RestoreStoredBackup)
+			admin.DELETE("/backups/:name", backupHandler.DeleteStoredBackup)
 		}
 	}
 
@@ -145,6 +214,9 @@ func main() {
 	log.Printf("管理后台: http://localhost%s/admin", addr)
 	log.Printf("登录页面: http://localhost%s/login", addr)
 	log.Println("默认账号: admin / admin")
+	if !utils.DownloadSigningEnabled() {
+		log.Println("未设置 SESSION_SECRET，签名下载链接不可用")
+	}
 
 	if err := r.Run(addr); err != nil {
 		log.Fatal("服务器启动失败:", err)
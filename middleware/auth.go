@@ -1,23 +1,51 @@
 package middleware
 
 import (
+	"database/sql"
+	"nav-admin/models"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// usernameKey 认证通过后保存在 gin.Context 中的用户名
+const usernameKey = "username"
+
 // AuthMiddleware 认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session, err := c.Cookie("session")
-		if err != nil || session == "" {
+		username, ok := SessionUser(c, db)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
 			c.Abort()
 			return
 		}
 
-		// 可以在这里验证session的有效性
-		// 简单实现：只要有session cookie就认为已登录
+		c.Set(usernameKey, username)
 		c.Next()
 	}
 }
+
+// SessionUser 根据 session cookie 在会话表中查找登录用户
+func SessionUser(c *gin.Context, db *sql.DB) (string, bool) {
+	token, err := c.Cookie("session")
+	if err != nil || token == "" {
+		return "", false
+	}
+	username, err := models.GetLoginSessionUser(db, token)
+	if err != nil {
+		return "", false
+	}
+	return username, true
+}
+
+// IsLoggedIn 判断请求是否已登录（用于公开接口中区分登录用户）
+func IsLoggedIn(c *gin.Context, db *sql.DB) bool {
+	_, ok := SessionUser(c, db)
+	return ok
+}
+
+// CurrentUsername 返回 AuthMiddleware 认证通过的用户名
+func CurrentUsername(c *gin.Context) string {
+	return c.GetString(usernameKey)
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"database/sql"
+	"nav-admin/config"
+	"nav-admin/models"
+	"nav-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *sql.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	config.AppConfig = &config.Config{
+		Upload: config.UploadConfig{Path: filepath.Join(dir, "uploads")},
+		Nav:    config.NavConfig{JSONPath: filepath.Join(dir, "nav.json")},
+	}
+	db, err := utils.InitDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/public", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"logged_in": IsLoggedIn(c, db)})
+	})
+	r.GET("/admin", AuthMiddleware(db), func(c *gin.Context) {
+		c.String(http.StatusOK, CurrentUsername(c))
+	})
+	return r, db
+}
+
+func request(r *gin.Engine, path, session string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: "session", Value: session})
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddlewareValidatesSession(t *testing.T) {
+	r, db := newTestRouter(t)
+
+	token, err := models.CreateLoginSession(db, "admin", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := models.CreateLoginSession(db, "admin", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		status  int
+		body    string
+	}{
+		{"no cookie", "", http.StatusUnauthorized, ""},
+		{"forged cookie", "admin_1700000000", http.StatusUnauthorized, ""},
+		{"expired session", expired, http.StatusUnauthorized, ""},
+		{"valid session", token, http.StatusOK, "admin"},
+	}
+	for _, tt := range tests {
+		w := request(r, "/admin", tt.session)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: username = %q, want %q", tt.name, w.Body.String(), tt.body)
+		}
+
+		public := request(r, "/public", tt.session)
+		want := `{"logged_in":false}`
+		if tt.status == http.StatusOK {
+			want = `{"logged_in":true}`
+		}
+		if public.Body.String() != want {
+			t.Errorf("%s: IsLoggedIn = %s, want %s", tt.name, public.Body.String(), want)
+		}
+	}
+
+	// 登出或修改密码后会话失效
+	if err := models.DeleteUserLoginSessions(db, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if w := request(r, "/admin", token); w.Code != http.StatusUnauthorized {
+		t.Errorf("deleted session: status = %d, want 401", w.Code)
+	}
+}
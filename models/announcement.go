@@ -5,10 +5,54 @@ import (
 	"time"
 )
 
+// AnnouncementTimeLayout 公告时间字段的存储格式（本地时间）
+const AnnouncementTimeLayout = "2006-01-02 15:04:05"
+
+// 公告级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
 type Announcement struct {
 	ID        int    `json:"id"`
 	Timestamp string `json:"timestamp"`
 	Content   string `json:"content"`
+	Format    string `json:"format"` // html 或 markdown
+	// PublishAt/ExpireAt 为空表示立即生效/永不过期
+	PublishAt string `json:"publish_at"`
+	ExpireAt  string `json:"expire_at"`
+	Priority  int    `json:"priority"`
+	Pinned    bool   `json:"pinned"`
+	Severity  string `json:"severity"` // info, warning, critical
+}
+
+// announcementColumns 查询公告时使用的字段列表，与 scanAnnouncement 对应
+const announcementColumns = `id, timestamp, content, COALESCE(format, 'html'), COALESCE(publish_at, ''),
+	COALESCE(expire_at, ''), COALESCE(priority, 0), COALESCE(pinned, 0), COALESCE(severity, 'info')`
+
+// announcementOrder 公告排序：置顶优先，其次按优先级从高到低
+const announcementOrder = "ORDER BY pinned DESC, priority DESC, id"
+
+// scanAnnouncement 扫描一行公告数据
+func scanAnnouncement(scanner interface {
+	Scan(dest ...interface{}) error
+}, ann *Announcement) error {
+	return scanner.Scan(&ann.ID, &ann.Timestamp, &ann.Content, &ann.Format, &ann.PublishAt,
+		&ann.ExpireAt, &ann.Priority, &ann.Pinned, &ann.Severity)
+}
+
+// IsActive 判断公告在指定时间是否处于展示期内
+func (a *Announcement) IsActive(now time.Time) bool {
+	current := now.Format(AnnouncementTimeLayout)
+	if a.PublishAt != "" && a.PublishAt > current {
+		return false
+	}
+	if a.ExpireAt != "" && a.ExpireAt <= current {
+		return false
+	}
+	return true
 }
 
 type AnnouncementConfig struct {
@@ -18,9 +62,34 @@ type AnnouncementConfig struct {
 	Announcements []Announcement `json:"announcements"`
 }
 
-// GetAllAnnouncements 获取所有公告
-func GetAllAnnouncements(db *sql.DB) ([]Announcement, error) {
-	rows, err := db.Query("SELECT id, timestamp, content FROM announcements ORDER BY id")
+// GetAllAnnouncements 获取所有公告（支持 *sql.DB 和 *sql.Tx）
+func GetAllAnnouncements(db queryer) ([]Announcement, error) {
+	rows, err := db.Query("SELECT " + announcementColumns + " FROM announcements " + announcementOrder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var announcements []Announcement
+	for rows.Next() {
+		var ann Announcement
+		if err := scanAnnouncement(rows, &ann); err != nil {
+			continue
+		}
+		announcements = append(announcements, ann)
+	}
+	return announcements, nil
+}
+
+// GetActiveAnnouncements 获取指定时间处于展示期内的公告
+func GetActiveAnnouncements(db *sql.DB, now time.Time) ([]Announcement, error) {
+	current := now.Format(AnnouncementTimeLayout)
+	rows, err := db.Query(
+		"SELECT "+announcementColumns+` FROM announcements
+		WHERE (publish_at IS NULL OR publish_at = '' OR publish_at <= ?)
+		AND (expire_at IS NULL OR expire_at = '' OR expire_at > ?) `+announcementOrder,
+		current, current,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +98,7 @@ func GetAllAnnouncements(db *sql.DB) ([]Announcement, error) {
 	var announcements []Announcement
 	for rows.Next() {
 		var ann Announcement
-		if err := rows.Scan(&ann.ID, &ann.Timestamp, &ann.Content); err != nil {
+		if err := scanAnnouncement(rows, &ann); err != nil {
 			continue
 		}
 		announcements = append(announcements, ann)
@@ -37,13 +106,31 @@ func GetAllAnnouncements(db *sql.DB) ([]Announcement, error) {
 	return announcements, nil
 }
 
+// GetNextAnnouncementTransition 获取 now 之后最近一次公告开始或结束展示的时间
+// 没有待发生的变化时返回零值
+func GetNextAnnouncementTransition(db *sql.DB, now time.Time) (time.Time, error) {
+	current := now.Format(AnnouncementTimeLayout)
+	var next sql.NullString
+	err := db.QueryRow(`
+		SELECT MIN(t) FROM (
+			SELECT publish_at AS t FROM announcements WHERE publish_at > ?
+			UNION ALL
+			SELECT expire_at AS t FROM announcements WHERE expire_at > ?
+		)`, current, current,
+	).Scan(&next)
+	if err != nil || !next.Valid || next.String == "" {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(AnnouncementTimeLayout, next.String, time.Local)
+}
+
 // GetAnnouncementByID 根据ID获取公告
 func GetAnnouncementByID(db *sql.DB, id int) (*Announcement, error) {
 	ann := &Announcement{}
-	err := db.QueryRow(
-		"SELECT id, timestamp, content FROM announcements WHERE id = ?",
+	err := scanAnnouncement(db.QueryRow(
+		"SELECT "+announcementColumns+" FROM announcements WHERE id = ?",
 		id,
-	).Scan(&ann.ID, &ann.Timestamp, &ann.Content)
+	), ann)
 
 	if err != nil {
 		return nil, err
@@ -56,10 +143,17 @@ func CreateAnnouncement(tx *sql.Tx, ann *Announcement) (int64, error) {
 	if ann.Timestamp == "" {
 		ann.Timestamp = time.Now().Format("2006-01-02 15:04:05")
 	}
+	if ann.Format == "" {
+		ann.Format = "html"
+	}
+	if ann.Severity == "" {
+		ann.Severity = SeverityInfo
+	}
 
 	result, err := tx.Exec(
-		"INSERT INTO announcements (timestamp, content) VALUES (?, ?)",
-		ann.Timestamp, ann.Content,
+		`INSERT INTO announcements (timestamp, content, format, publish_at, expire_at, priority, pinned, severity)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		ann.Timestamp, ann.Content, ann.Format, ann.PublishAt, ann.ExpireAt, ann.Priority, ann.Pinned, ann.Severity,
 	)
 	if err != nil {
 		return 0, err
@@ -70,9 +164,17 @@ func CreateAnnouncement(tx *sql.Tx, ann *Announcement) (int64, error) {
 
 // UpdateAnnouncement 更新公告
 func UpdateAnnouncement(tx *sql.Tx, id int, ann *Announcement) error {
+	if ann.Format == "" {
+		ann.Format = "html"
+	}
+	if ann.Severity == "" {
+		ann.Severity = SeverityInfo
+	}
+
 	_, err := tx.Exec(
-		"UPDATE announcements SET timestamp = ?, content = ? WHERE id = ?",
-		ann.Timestamp, ann.Content, id,
+		`UPDATE announcements SET timestamp = ?, content = ?, format = ?, publish_at = ?, expire_at = ?,
+		priority = ?, pinned = ?, severity = ? WHERE id = ?`,
+		ann.Timestamp, ann.Content, ann.Format, ann.PublishAt, ann.ExpireAt, ann.Priority, ann.Pinned, ann.Severity, id,
 	)
 	return err
 }
@@ -5,17 +5,21 @@ import (
 )
 
 type Category struct {
-	ID       int    `json:"id,omitempty"`
-	IDStr    string `json:"_id"`
-	Classify string `json:"classify"`
-	Icon     string `json:"icon"`
-	SortNo   int    `json:"sort_no"`
-	Sites    []Site `json:"sites,omitempty"`
+	ID       int        `json:"id,omitempty"`
+	IDStr    string     `json:"_id"`
+	ParentID int        `json:"parent_id"` // 上级分类ID，顶级分类为0
+	Classify string     `json:"classify"`
+	Icon     string     `json:"icon"`
+	SortNo   int        `json:"sort_no"`
+	Depth    int        `json:"depth,omitempty"` // 层级（顶级分类为0），GetAllCategories 查询时计算
+	Sites    []Site     `json:"sites,omitempty"`
+	Children []Category `json:"children,omitempty"`
 }
 
-// GetAllCategories 获取所有分类
-func GetAllCategories(db *sql.DB) ([]Category, error) {
-	rows, err := db.Query("SELECT id, id_str, classify, icon, sort_no FROM categories ORDER BY sort_no, id")
+// GetAllCategories 获取所有分类（支持 *sql.DB 和 *sql.Tx）
+// 按深度优先顺序返回：上级分类在前，紧跟着它的子分类，同级分类按排序号排序
+func GetAllCategories(db queryer) ([]Category, error) {
+	rows, err := db.Query("SELECT id, id_str, parent_id, classify, icon, sort_no FROM categories ORDER BY sort_no, id")
 	if err != nil {
 		return nil, err
 	}
@@ -24,21 +28,86 @@ func GetAllCategories(db *sql.DB) ([]Category, error) {
 	var categories []Category
 	for rows.Next() {
 		var cat Category
-		if err := rows.Scan(&cat.ID, &cat.IDStr, &cat.Classify, &cat.Icon, &cat.SortNo); err != nil {
+		if err := rows.Scan(&cat.ID, &cat.IDStr, &cat.ParentID, &cat.Classify, &cat.Icon, &cat.SortNo); err != nil {
 			continue
 		}
 		categories = append(categories, cat)
 	}
-	return categories, nil
+	return orderCategories(categories), nil
+}
+
+// orderCategories 把按排序号排好的分类整理为深度优先顺序并计算层级
+// 上级分类不存在的分类（以及形成循环的分类）视为顶级分类
+func orderCategories(categories []Category) []Category {
+	exists := make(map[int]bool, len(categories))
+	for _, cat := range categories {
+		exists[cat.ID] = true
+	}
+	children := map[int][]int{} // 上级分类ID -> 子分类位置
+	var roots []int
+	for i, cat := range categories {
+		if cat.ParentID != 0 && cat.ParentID != cat.ID && exists[cat.ParentID] {
+			children[cat.ParentID] = append(children[cat.ParentID], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	result := make([]Category, 0, len(categories))
+	visited := make([]bool, len(categories))
+	var walk func(i, depth int)
+	walk = func(i, depth int) {
+		visited[i] = true
+		cat := categories[i]
+		cat.Depth = depth
+		result = append(result, cat)
+		for _, c := range children[cat.ID] {
+			if !visited[c] {
+				walk(c, depth+1)
+			}
+		}
+	}
+	for _, i := range roots {
+		walk(i, 0)
+	}
+	for i := range categories {
+		if !visited[i] {
+			walk(i, 0)
+		}
+	}
+	return result
+}
+
+// CategoryTree 把 GetAllCategories 返回的分类转换为树，子分类放在上级分类的 Children 中
+func CategoryTree(categories []Category) []Category {
+	var build func(start int) (Category, int)
+	build = func(start int) (Category, int) {
+		cat := categories[start]
+		next := start + 1
+		for next < len(categories) && categories[next].Depth > cat.Depth {
+			var child Category
+			child, next = build(next)
+			cat.Children = append(cat.Children, child)
+		}
+		return cat, next
+	}
+
+	var tree []Category
+	for i := 0; i < len(categories); {
+		var cat Category
+		cat, i = build(i)
+		tree = append(tree, cat)
+	}
+	return tree
 }
 
 // GetCategoryByID 根据ID获取分类
 func GetCategoryByID(db *sql.DB, id int) (*Category, error) {
 	cat := &Category{}
 	err := db.QueryRow(
-		"SELECT id, id_str, classify, icon, sort_no FROM categories WHERE id = ?",
+		"SELECT id, id_str, parent_id, classify, icon, sort_no FROM categories WHERE id = ?",
 		id,
-	).Scan(&cat.ID, &cat.IDStr, &cat.Classify, &cat.Icon, &cat.SortNo)
+	).Scan(&cat.ID, &cat.IDStr, &cat.ParentID, &cat.Classify, &cat.Icon, &cat.SortNo)
 
 	if err != nil {
 		return nil, err
@@ -46,11 +115,38 @@ func GetCategoryByID(db *sql.DB, id int) (*Category, error) {
 	return cat, nil
 }
 
-// CreateCategory 创建分类
+// GetCategoryDescendantIDs 获取分类的所有下级分类ID（子分类、子分类的子分类……）
+func GetCategoryDescendantIDs(db queryer, id int) ([]int, error) {
+	rows, err := db.Query(`
+		WITH RECURSIVE sub(id) AS (
+			SELECT id FROM categories WHERE parent_id = ?
+			UNION
+			SELECT c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+		)
+		SELECT id FROM sub`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var childID int
+		if err := rows.Scan(&childID); err != nil {
+			return nil, err
+		}
+		if childID != id {
+			ids = append(ids, childID)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// CreateCategory 创建分类（排在同级分类的最后）
 func CreateCategory(tx *sql.Tx, cat *Category) (int64, error) {
-	// 获取最大排序号
+	// 获取同级分类的最大排序号
 	var maxSortNo int
-	err := tx.QueryRow("SELECT COALESCE(MAX(sort_no), -1) FROM categories").Scan(&maxSortNo)
+	err := tx.QueryRow("SELECT COALESCE(MAX(sort_no), -1) FROM categories WHERE parent_id = ?", cat.ParentID).Scan(&maxSortNo)
 	if err != nil {
 		return 0, err
 	}
@@ -58,8 +154,8 @@ func CreateCategory(tx *sql.Tx, cat *Category) (int64, error) {
 	cat.SortNo = maxSortNo + 1
 
 	result, err := tx.Exec(
-		"INSERT INTO categories (id_str, classify, icon, sort_no) VALUES (?, ?, ?, ?)",
-		cat.IDStr, cat.Classify, cat.Icon, cat.SortNo,
+		"INSERT INTO categories (id_str, parent_id, classify, icon, sort_no) VALUES (?, ?, ?, ?, ?)",
+		cat.IDStr, cat.ParentID, cat.Classify, cat.Icon, cat.SortNo,
 	)
 	if err != nil {
 		return 0, err
@@ -68,7 +164,20 @@ func CreateCategory(tx *sql.Tx, cat *Category) (int64, error) {
 	return result.LastInsertId()
 }
 
-// UpdateCategory 更新分类
+// MoveCategory 修改分类的上级分类（0为顶级），排在新的同级分类的最后
+// 调用方需检查不会移动到自己或下级分类下
+func MoveCategory(tx *sql.Tx, id int, parentID int) error {
+	var maxSortNo int
+	err := tx.QueryRow("SELECT COALESCE(MAX(sort_no), -1) FROM categories WHERE parent_id = ? AND id != ?", parentID, id).Scan(&maxSortNo)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE categories SET parent_id = ?, sort_no = ? WHERE id = ?", parentID, maxSortNo+1, id)
+	return err
+}
+
+// UpdateCategory 更新分类（不修改上级分类，见 MoveCategory）
 func UpdateCategory(tx *sql.Tx, id int, cat *Category) error {
 	_, err := tx.Exec(
 		"UPDATE categories SET id_str = ?, classify = ?, icon = ? WHERE id = ?",
@@ -77,25 +186,78 @@ func UpdateCategory(tx *sql.Tx, id int, cat *Category) error {
 	return err
 }
 
-// DeleteCategory 删除分类（会级联删除站点）
+// DeleteCategory 删除分类（会级联删除站点），子分类移到被删除分类的上级分类下
 func DeleteCategory(tx *sql.Tx, id int) error {
-	// 先获取该分类下的所有站点，删除关联的上传文件
+	// 先获取该分类下的所有站点，用于删除关联的上传文件
 	sites, err := GetSitesByCategoryID(tx, id)
 	if err != nil {
 		return err
 	}
 
-	// 删除站点关联的文件
+	// 子分类移到上一级
+	var parentID int
+	if err := tx.QueryRow("SELECT parent_id FROM categories WHERE id = ?", id).Scan(&parentID); err != nil {
+		return err
+	}
+	children, err := tx.Query("SELECT id FROM categories WHERE parent_id = ? AND id != ? ORDER BY sort_no, id", id, id)
+	if err != nil {
+		return err
+	}
+	var childIDs []int
+	for children.Next() {
+		var childID int
+		if err := children.Scan(&childID); err != nil {
+			children.Close()
+			return err
+		}
+		childIDs = append(childIDs, childID)
+	}
+	children.Close()
+	for _, childID := range childIDs {
+		if err := MoveCategory(tx, childID, parentID); err != nil {
+			return err
+		}
+	}
+
+	// 删除分类下的站点（及其标签）和分类本身
+	if _, err := tx.Exec("DELETE FROM site_tags WHERE site_id IN (SELECT id FROM sites WHERE cat_id = ?)", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sites WHERE cat_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	// 删除不再被引用的上传文件（事务提交后才从存储中删除）
 	for _, site := range sites {
-		if err := DeleteSiteFile(site.Href); err != nil {
-			// 记录错误但继续执行
-			continue
+		if err := DeleteSiteFile(tx, site.Href); err != nil {
+			return err
+		}
+		if err := DeleteSiteFile(tx, site.Logo); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// 删除分类（会自动级联删除站点）
-	_, err = tx.Exec("DELETE FROM categories WHERE id = ?", id)
-	return err
+// DeleteCategoryTree 删除分类及其所有下级分类（连同其中的站点），返回删除的分类数
+func DeleteCategoryTree(tx *sql.Tx, id int) (int, error) {
+	ids, err := GetCategoryDescendantIDs(tx, id)
+	if err != nil {
+		return 0, err
+	}
+	// 从后往前删除（查询结果按层级排列，先删除较深的分类，减少子分类移到上一级的更新）
+	for i := len(ids) - 1; i >= 0; i-- {
+		if err := DeleteCategory(tx, ids[i]); err != nil {
+			return 0, err
+		}
+	}
+	if err := DeleteCategory(tx, id); err != nil {
+		return 0, err
+	}
+	return len(ids) + 1, nil
 }
 
 // UpdateCategorySortNo 更新分类排序
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"database/sql"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams 归一化时需要去掉的跟踪参数（utm_* 另外处理）
+// 只包含广告和邮件营销平台的点击ID；from、ref 等参数在很多网站有实际含义（如 ?ref=main），不能去掉
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"gbraid":  true,
+	"wbraid":  true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"twclid":  true,
+	"ttclid":  true,
+	"igshid":  true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+}
+
+// DuplicateSite 重复链接中的单个站点
+type DuplicateSite struct {
+	ID       int    `json:"id"`
+	CatID    int    `json:"cat_id"`
+	Classify string `json:"classify"`
+	Name     string `json:"name"`
+	Href     string `json:"href"`
+}
+
+// DuplicateGroup 归一化后地址相同的一组站点
+type DuplicateGroup struct {
+	Normalized string          `json:"normalized"`
+	Sites      []DuplicateSite `json:"sites"`
+}
+
+// NormalizeHref 归一化站点链接，用于判断重复
+// 忽略协议(http/https)、www.前缀、默认端口、末尾斜杠、锚点和常见跟踪参数，查询参数按名称排序
+func NormalizeHref(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || isUploadedFile(href) {
+		return href
+	}
+
+	raw := href
+	if !strings.Contains(raw, "://") && !strings.HasPrefix(raw, "//") {
+		raw = "//" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return href
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "" && scheme != "http" && scheme != "https" {
+		return href
+	}
+
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	path := strings.TrimRight(u.EscapedPath(), "/")
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(key)
+		}
+	}
+
+	normalized := host + path
+	if len(query) > 0 {
+		// url.Values.Encode 按键名排序
+		normalized += "?" + query.Encode()
+	}
+	return normalized
+}
+
+// FindSitesByNormalizedHref 查找归一化后链接与 href 相同的站点
+// excludeID 大于0时排除该站点（用于更新时排除自身）
+func FindSitesByNormalizedHref(db *sql.DB, href string, excludeID int) ([]DuplicateSite, error) {
+	target := NormalizeHref(href)
+	if target == "" {
+		return nil, nil
+	}
+
+	sites, err := getAllSitesWithCategory(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []DuplicateSite
+	for _, site := range sites {
+		if site.ID == excludeID {
+			continue
+		}
+		if NormalizeHref(site.Href) == target {
+			result = append(result, site)
+		}
+	}
+	return result, nil
+}
+
+// GetDuplicateSiteGroups 获取所有归一化链接重复的站点分组
+func GetDuplicateSiteGroups(db *sql.DB) ([]DuplicateGroup, error) {
+	sites, err := getAllSitesWithCategory(db)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]DuplicateSite)
+	var order []string
+	for _, site := range sites {
+		key := NormalizeHref(site.Href)
+		if key == "" {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], site)
+	}
+
+	var result []DuplicateGroup
+	for _, key := range order {
+		if len(groups[key]) < 2 {
+			continue
+		}
+		result = append(result, DuplicateGroup{Normalized: key, Sites: groups[key]})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return len(result[i].Sites) > len(result[j].Sites)
+	})
+
+	if result == nil {
+		result = []DuplicateGroup{}
+	}
+	return result, nil
+}
+
+// MergeDuplicateSites 合并重复站点：保留 keepID，删除 removeIDs
+// 保留站点为空的描述和图标会用被删除站点的值补全，被删除站点的标签合并到保留站点；被删除站点的上传文件仍被引用时不删除
+func MergeDuplicateSites(tx *sql.Tx, keepID int, removeIDs []int) error {
+	keep, err := GetSiteByID(tx, keepID)
+	if err != nil {
+		return err
+	}
+
+	var removed []*Site
+	for _, id := range removeIDs {
+		if id == keepID {
+			continue
+		}
+
+		site, err := GetSiteByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if keep.Desc == "" {
+			keep.Desc = site.Desc
+		}
+		if keep.Logo == "" {
+			keep.Logo = site.Logo
+		}
+
+		if _, err := tx.Exec("INSERT OR IGNORE INTO site_tags (site_id, tag_id) SELECT ?, tag_id FROM site_tags WHERE site_id = ?", keepID, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM site_tags WHERE site_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM sites WHERE id = ?", id); err != nil {
+			return err
+		}
+
+		removed = append(removed, site)
+	}
+
+	_, err = tx.Exec(
+		"UPDATE sites SET description = ?, logo = ? WHERE id = ?",
+		keep.Desc, keep.Logo, keepID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// 上传文件只在没有其他引用时删除（事务提交后才从存储中删除）
+	for _, site := range removed {
+		if err := DeleteSiteFile(tx, site.Href); err != nil {
+			return err
+		}
+		if err := DeleteSiteFile(tx, site.Logo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getAllSitesWithCategory 获取所有站点及其所属分类名称
+func getAllSitesWithCategory(db *sql.DB) ([]DuplicateSite, error) {
+	rows, err := db.Query(`
+		SELECT s.id, s.cat_id, COALESCE(c.classify, ''), s.name, s.href
+		FROM sites s LEFT JOIN categories c ON s.cat_id = c.id
+		ORDER BY c.sort_no, s.cat_id, s.sort_no, s.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sites []DuplicateSite
+	for rows.Next() {
+		var site DuplicateSite
+		if err := rows.Scan(&site.ID, &site.CatID, &site.Classify, &site.Name, &site.Href); err != nil {
+			continue
+		}
+		sites = append(sites, site)
+	}
+	return sites, nil
+}
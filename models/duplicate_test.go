@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestNormalizeHref(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"https://www.example.com/", "http://example.com", true},
+		{"https://example.com:443/a/", "https://example.com/a#top", true},
+		{"https://example.com/?utm_source=x&b=2&a=1", "https://example.com/?a=1&b=2", true},
+		{"https://example.com/?fbclid=abc&gclid=def&msclkid=1", "https://example.com/", true},
+		{"https://github.com/o/r?ref=main", "https://github.com/o/r?ref=dev", false},
+		{"https://example.com/report?from=2024-01-01", "https://example.com/report", false},
+		{"https://example.com/?spm=a1", "https://example.com/", false},
+		{"https://example.com:8080/", "https://example.com/", false},
+	}
+	for _, tt := range tests {
+		if got := NormalizeHref(tt.a) == NormalizeHref(tt.b); got != tt.same {
+			t.Errorf("NormalizeHref(%q) == NormalizeHref(%q): %v, want %v (%q, %q)", tt.a, tt.b, got, tt.same, NormalizeHref(tt.a), NormalizeHref(tt.b))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// CreateLoginSession 为用户创建登录会话，返回写入 session cookie 的随机令牌
+func CreateLoginSession(db *sql.DB, username string, maxAge time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	now := time.Now()
+	// 顺便清理已过期的会话
+	if _, err := db.Exec("DELETE FROM login_sessions WHERE expires_at <= ?", now.Unix()); err != nil {
+		return "", err
+	}
+	_, err := db.Exec(
+		"INSERT INTO login_sessions (token, username, expires_at) VALUES (?, ?, ?)",
+		token, username, now.Add(maxAge).Unix(),
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetLoginSessionUser 返回会话对应的用户名
+// 会话不存在、已过期或用户已被删除时返回 sql.ErrNoRows
+func GetLoginSessionUser(db *sql.DB, token string) (string, error) {
+	var username string
+	err := db.QueryRow(`
+		SELECT u.username FROM login_sessions s
+		JOIN users u ON u.username = s.username
+		WHERE s.token = ? AND s.expires_at > ?`,
+		token, time.Now().Unix(),
+	).Scan(&username)
+	return username, err
+}
+
+// DeleteLoginSession 删除会话（登出）
+func DeleteLoginSession(db *sql.DB, token string) error {
+	_, err := db.Exec("DELETE FROM login_sessions WHERE token = ?", token)
+	return err
+}
+
+// DeleteUserLoginSessions 删除用户的所有会话（修改密码或从备份恢复用户后需要重新登录）
+func DeleteUserLoginSessions(db execer, username string) error {
+	_, err := db.Exec("DELETE FROM login_sessions WHERE username = ?", username)
+	return err
+}
@@ -11,14 +11,16 @@ type PageConfig struct {
 	Logo       string `json:"logo"`
 	FooterText string `json:"footer_text"`
 	ICP        string `json:"icp"`
+	// FooterFormat 页脚内容格式：html 或 markdown
+	FooterFormat string `json:"footer_format"`
 }
 
 // GetPageConfig 获取页面配置
 func GetPageConfig(db *sql.DB) (*PageConfig, error) {
 	config := &PageConfig{}
 	err := db.QueryRow(
-		"SELECT id, title, subtitle, logo, footer_text, icp FROM page_config WHERE id = 1",
-	).Scan(&config.ID, &config.Title, &config.Subtitle, &config.Logo, &config.FooterText, &config.ICP)
+		"SELECT id, title, subtitle, logo, footer_text, icp, COALESCE(footer_format, 'html') FROM page_config WHERE id = 1",
+	).Scan(&config.ID, &config.Title, &config.Subtitle, &config.Logo, &config.FooterText, &config.ICP, &config.FooterFormat)
 
 	if err == sql.ErrNoRows {
 		// 如果没有配置，创建默认配置
@@ -37,9 +39,14 @@ func GetPageConfig(db *sql.DB) (*PageConfig, error) {
 
 // UpdatePageConfig 更新页面配置
 func UpdatePageConfig(tx *sql.Tx, config *PageConfig) error {
-	// 先检查是否存在配置
+	if config.FooterFormat == "" {
+		config.FooterFormat = "html"
+	}
+
+	// 先检查是否存在配置，记录旧Logo用于清理
 	var count int
-	err := tx.QueryRow("SELECT COUNT(*) FROM page_config WHERE id = 1").Scan(&count)
+	var oldLogo string
+	err := tx.QueryRow("SELECT COUNT(*), COALESCE(MAX(logo), '') FROM page_config WHERE id = 1").Scan(&count, &oldLogo)
 	if err != nil {
 		return err
 	}
@@ -47,18 +54,26 @@ func UpdatePageConfig(tx *sql.Tx, config *PageConfig) error {
 	if count == 0 {
 		// 不存在则插入
 		_, err = tx.Exec(
-			`INSERT INTO page_config (id, title, subtitle, logo, footer_text, icp)
-			VALUES (1, ?, ?, ?, ?, ?)`,
-			config.Title, config.Subtitle, config.Logo, config.FooterText, config.ICP,
+			`INSERT INTO page_config (id, title, subtitle, logo, footer_text, icp, footer_format)
+			VALUES (1, ?, ?, ?, ?, ?, ?)`,
+			config.Title, config.Subtitle, config.Logo, config.FooterText, config.ICP, config.FooterFormat,
 		)
 	} else {
 		// 存在则更新
 		_, err = tx.Exec(
-			`UPDATE page_config SET title = ?, subtitle = ?, logo = ?, footer_text = ?, icp = ? WHERE id = 1`,
-			config.Title, config.Subtitle, config.Logo, config.FooterText, config.ICP,
+			`UPDATE page_config SET title = ?, subtitle = ?, logo = ?, footer_text = ?, icp = ?, footer_format = ? WHERE id = 1`,
+			config.Title, config.Subtitle, config.Logo, config.FooterText, config.ICP, config.FooterFormat,
 		)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	// 旧Logo是上传文件且不再被引用时删除（事务提交后才从存储中删除）
+	if oldLogo != config.Logo {
+		return DeleteSiteFile(tx, oldLogo)
+	}
+	return nil
 }
 
 // initPageConfig 初始化页面配置
@@ -74,11 +89,12 @@ func initPageConfig(db *sql.DB) error {
 // getDefaultPageConfig 获取默认配置
 func getDefaultPageConfig() *PageConfig {
 	return &PageConfig{
-		ID:         1,
-		Title:      "网址导航",
-		Subtitle:   "常用网址一键直达",
-		Logo:       "/static/logo.png",
-		FooterText: "",
-		ICP:        "",
+		ID:           1,
+		Title:        "网址导航",
+		Subtitle:     "常用网址一键直达",
+		Logo:         "/static/logo.png",
+		FooterText:   "",
+		ICP:          "",
+		FooterFormat: "html",
 	}
 }
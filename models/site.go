@@ -2,9 +2,6 @@ package models
 
 import (
 	"database/sql"
-	"os"
-	"path/filepath"
-	"strings"
 )
 
 type Site struct {
@@ -15,6 +12,8 @@ type Site struct {
 	Desc   string `json:"desc"`
 	Logo   string `json:"logo"`
 	SortNo int    `json:"sort_no"`
+	// Tags 标签名称；创建和更新时为 nil 表示不修改标签，空数组表示去掉所有标签
+	Tags []string `json:"tags,omitempty"`
 }
 
 // GetSitesByCategoryID 获取指定分类的所有站点
@@ -45,6 +44,17 @@ func GetSitesByCategoryID(db interface{}, catID int) ([]Site, error) {
 		}
 		sites = append(sites, site)
 	}
+	rows.Close()
+
+	if q, ok := db.(queryer); ok && len(sites) > 0 {
+		tags, err := GetSiteTags(q, catID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range sites {
+			sites[i].Tags = tags[sites[i].ID]
+		}
+	}
 	return sites, nil
 }
 
@@ -72,9 +82,39 @@ func GetSiteByID(db interface{}, id int) (*Site, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if q, ok := db.(queryer); ok {
+		tags, err := getTagsOfSite(q, id)
+		if err != nil {
+			return nil, err
+		}
+		site.Tags = tags
+	}
 	return site, nil
 }
 
+// getTagsOfSite 获取一个站点的标签名称（按名称排序）
+func getTagsOfSite(db queryer, id int) ([]string, error) {
+	rows, err := db.Query(
+		"SELECT t.name FROM site_tags st JOIN tags t ON t.id = st.tag_id WHERE st.site_id = ? ORDER BY t.name COLLATE NOCASE, t.id",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
 // CreateSite 创建站点
 func CreateSite(tx *sql.Tx, site *Site) (int64, error) {
 	// 获取该分类下的最大排序号
@@ -94,7 +134,16 @@ func CreateSite(tx *sql.Tx, site *Site) (int64, error) {
 		return 0, err
 	}
 
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if len(site.Tags) > 0 {
+		if err := SetSiteTags(tx, int(id), site.Tags); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
 }
 
 // UpdateSite 更新站点
@@ -105,16 +154,30 @@ func UpdateSite(tx *sql.Tx, id int, site *Site) error {
 		return err
 	}
 
-	// 如果href改变了，且旧的href是上传的文件，则删除旧文件
-	if oldSite.Href != site.Href && isUploadedFile(oldSite.Href) {
-		DeleteSiteFile(oldSite.Href)
-	}
-
 	_, err = tx.Exec(
 		"UPDATE sites SET name = ?, href = ?, description = ?, logo = ? WHERE id = ?",
 		site.Name, site.Href, site.Desc, site.Logo, id,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if site.Tags != nil {
+		if err := SetSiteTags(tx, id, site.Tags); err != nil {
+			return err
+		}
+	}
+
+	// 链接或图标改变后，旧的上传文件不再被引用时删除（事务提交后才从存储中删除）
+	if oldSite.Href != site.Href {
+		if err := DeleteSiteFile(tx, oldSite.Href); err != nil {
+			return err
+		}
+	}
+	if oldSite.Logo != site.Logo {
+		return DeleteSiteFile(tx, oldSite.Logo)
+	}
+	return nil
 }
 
 // DeleteSite 删除站点
@@ -125,13 +188,19 @@ func DeleteSite(tx *sql.Tx, id int) error {
 		return err
 	}
 
-	// 删除关联的上传文件
-	if err := DeleteSiteFile(site.Href); err != nil {
-		// 记录错误但继续执行
+	if _, err := tx.Exec("DELETE FROM site_tags WHERE site_id = ?", id); err != nil {
+		return err
 	}
-
 	_, err = tx.Exec("DELETE FROM sites WHERE id = ?", id)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// 删除不再被引用的上传文件（事务提交后才从存储中删除）
+	if err := DeleteSiteFile(tx, site.Href); err != nil {
+		return err
+	}
+	return DeleteSiteFile(tx, site.Logo)
 }
 
 // UpdateSiteSortNo 更新站点排序
@@ -140,26 +209,72 @@ func UpdateSiteSortNo(tx *sql.Tx, id int, sortNo int) error {
 	return err
 }
 
-// DeleteSiteFile 删除站点关联的上传文件
-func DeleteSiteFile(href string) error {
-	if !isUploadedFile(href) {
-		return nil
+// MoveSite 把站点移动到另一个分类，排在该分类的最后
+func MoveSite(tx *sql.Tx, id int, catID int) error {
+	var maxSortNo int
+	err := tx.QueryRow("SELECT COALESCE(MAX(sort_no), -1) FROM sites WHERE cat_id = ?", catID).Scan(&maxSortNo)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE sites SET cat_id = ?, sort_no = ? WHERE id = ?", catID, maxSortNo+1, id)
+	return err
+}
+
+// MoveSiteTo 把站点移动到指定分类的指定位置（从0开始，超出范围时排在最后），目标分类中的站点重新编号
+func MoveSiteTo(tx *sql.Tx, id int, catID int, position int) error {
+	rows, err := tx.Query("SELECT id FROM sites WHERE cat_id = ? AND id != ? ORDER BY sort_no, id", catID, id)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var siteID int
+		if err := rows.Scan(&siteID); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, siteID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
-	// 提取文件路径
-	filePath := strings.TrimPrefix(href, "/uploads/")
-	fullPath := filepath.Join("./uploads", filePath)
+	if position < 0 || position > len(ids) {
+		position = len(ids)
+	}
+	ids = append(ids[:position], append([]int{id}, ids[position:]...)...)
 
-	// 检查文件是否存在
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return nil
+	for i, siteID := range ids {
+		if err := UpdateSitePosition(tx, siteID, catID, i); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// 删除文件
-	return os.Remove(fullPath)
+// UpdateSitePosition 更新站点所在分类和排序
+func UpdateSitePosition(tx *sql.Tx, id int, catID int, sortNo int) error {
+	_, err := tx.Exec("UPDATE sites SET cat_id = ?, sort_no = ? WHERE id = ?", catID, sortNo, id)
+	return err
 }
 
-// isUploadedFile 判断是否是上传的文件
-func isUploadedFile(href string) bool {
-	return strings.HasPrefix(href, "/uploads/") || strings.HasPrefix(href, "./uploads/")
+// GetSiteLayout 获取所有站点的分布：分类ID -> 按排序的站点ID列表
+func GetSiteLayout(db queryer) (map[int][]int, error) {
+	rows, err := db.Query("SELECT id, cat_id FROM sites ORDER BY sort_no, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	layout := map[int][]int{}
+	for rows.Next() {
+		var id, catID int
+		if err := rows.Scan(&id, &catID); err != nil {
+			return nil, err
+		}
+		layout[catID] = append(layout[catID], id)
+	}
+	return layout, rows.Err()
 }
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Tag 站点标签（一个站点可以有多个标签）
+type Tag struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	SiteCount int    `json:"site_count"` // 使用该标签的站点数，GetAllTags 查询时计算
+}
+
+// GetAllTags 获取所有标签及其站点数，按名称排序
+func GetAllTags(db queryer) ([]Tag, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.name, COUNT(st.site_id)
+		FROM tags t LEFT JOIN site_tags st ON st.tag_id = t.id
+		GROUP BY t.id ORDER BY t.name COLLATE NOCASE, t.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.SiteCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetTagByID 根据ID获取标签，不存在时返回 sql.ErrNoRows
+func GetTagByID(db rowQueryer, id int) (*Tag, error) {
+	tag := &Tag{}
+	err := db.QueryRow(
+		"SELECT t.id, t.name, (SELECT COUNT(*) FROM site_tags WHERE tag_id = t.id) FROM tags t WHERE t.id = ?",
+		id,
+	).Scan(&tag.ID, &tag.Name, &tag.SiteCount)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// GetTagIDByName 按名称（不区分大小写）查找标签，不存在时返回 sql.ErrNoRows
+func GetTagIDByName(db rowQueryer, name string) (int, error) {
+	var id int
+	err := db.QueryRow("SELECT id FROM tags WHERE name = ? COLLATE NOCASE", name).Scan(&id)
+	return id, err
+}
+
+// CreateTag 创建标签
+func CreateTag(tx *sql.Tx, tag *Tag) (int64, error) {
+	result, err := tx.Exec("INSERT INTO tags (name) VALUES (?)", tag.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// UpdateTag 修改标签名称（站点上的标签随之改变）
+func UpdateTag(tx *sql.Tx, id int, tag *Tag) error {
+	_, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", tag.Name, id)
+	return err
+}
+
+// DeleteTag 删除标签，并从所有站点上去掉
+func DeleteTag(tx *sql.Tx, id int) error {
+	if _, err := tx.Exec("DELETE FROM site_tags WHERE tag_id = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM tags WHERE id = ?", id)
+	return err
+}
+
+// SetSiteTags 把站点的标签设置为 names（按名称匹配已有标签，不存在的标签自动创建）
+func SetSiteTags(tx *sql.Tx, siteID int, names []string) error {
+	if _, err := tx.Exec("DELETE FROM site_tags WHERE site_id = ?", siteID); err != nil {
+		return err
+	}
+	for _, name := range names {
+		tagID, err := GetTagIDByName(tx, name)
+		if err == sql.ErrNoRows {
+			id, createErr := CreateTag(tx, &Tag{Name: name})
+			if createErr != nil {
+				return createErr
+			}
+			tagID, err = int(id), nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO site_tags (site_id, tag_id) VALUES (?, ?)", siteID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSiteTags 获取站点的标签名称（站点ID -> 按名称排序的标签），catID 为0时获取所有站点的标签
+func GetSiteTags(db queryer, catID int) (map[int][]string, error) {
+	query := `SELECT st.site_id, t.name FROM site_tags st JOIN tags t ON t.id = st.tag_id`
+	var args []interface{}
+	if catID != 0 {
+		query += ` JOIN sites s ON s.id = st.site_id WHERE s.cat_id = ?`
+		args = append(args, catID)
+	}
+	rows, err := db.Query(query+` ORDER BY t.name COLLATE NOCASE, t.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := map[int][]string{}
+	for rows.Next() {
+		var siteID int
+		var name string
+		if err := rows.Scan(&siteID, &name); err != nil {
+			return nil, err
+		}
+		tags[siteID] = append(tags[siteID], name)
+	}
+	return tags, rows.Err()
+}
+
+// SameTags 判断两组标签是否相同（不区分大小写和顺序）
+func SameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int, len(a))
+	for _, name := range a {
+		count[strings.ToLower(name)]++
+	}
+	for _, name := range b {
+		key := strings.ToLower(name)
+		if count[key] == 0 {
+			return false
+		}
+		count[key]--
+	}
+	return true
+}
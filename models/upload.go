@@ -0,0 +1,292 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"nav-admin/storage"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 上传文件的下载权限
+const (
+	VisibilityPublic = "public" // 所有人可下载
+	VisibilityLogin  = "login"  // 仅登录用户
+	VisibilitySigned = "signed" // 仅持有未过期签名链接的用户（登录用户不受限制）
+)
+
+// Upload 上传文件记录，文件按内容哈希命名，相同内容只保存一份
+type Upload struct {
+	ID             int    `json:"id"`
+	Hash           string `json:"hash"`
+	Path           string `json:"path"`
+	OriginalName   string `json:"original_name"`
+	Mime           string `json:"mime"`
+	Size           int64  `json:"size"`
+	Uploader       string `json:"uploader"`
+	Visibility     string `json:"visibility"`
+	DownloadCount  int    `json:"download_count"`
+	LastDownloadAt string `json:"last_download_at"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// uploadColumns 查询上传记录的字段（与 scanUpload 对应）
+const uploadColumns = "id, hash, path, original_name, mime, size, uploader, visibility, download_count, last_download_at, created_at"
+
+// scanUpload 读取一行上传记录
+func scanUpload(row interface{ Scan(...interface{}) error }) (*Upload, error) {
+	upload := &Upload{}
+	err := row.Scan(&upload.ID, &upload.Hash, &upload.Path, &upload.OriginalName, &upload.Mime, &upload.Size,
+		&upload.Uploader, &upload.Visibility, &upload.DownloadCount, &upload.LastDownloadAt, &upload.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// rowQueryer *sql.DB 和 *sql.Tx 共有的查询方法
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// queryer *sql.DB 和 *sql.Tx 共有的多行查询方法
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// execer *sql.DB 和 *sql.Tx 共有的执行方法
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// GetUploadByPath 根据访问路径获取上传记录，不存在时返回 sql.ErrNoRows
+func GetUploadByPath(db rowQueryer, path string) (*Upload, error) {
+	return scanUpload(db.QueryRow("SELECT "+uploadColumns+" FROM uploads WHERE path = ?", canonicalUploadPath(path)))
+}
+
+// GetAllUploads 获取所有上传记录，按访问路径索引
+func GetAllUploads(db *sql.DB) (map[string]*Upload, error) {
+	rows, err := db.Query("SELECT " + uploadColumns + " FROM uploads")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uploads := make(map[string]*Upload)
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			continue
+		}
+		uploads[upload.Path] = upload
+	}
+	return uploads, nil
+}
+
+// CreateUpload 登记上传文件，相同路径已存在时不重复登记
+func CreateUpload(tx *sql.Tx, upload *Upload) error {
+	_, err := tx.Exec(
+		"INSERT OR IGNORE INTO uploads (hash, path, original_name, mime, size, uploader) VALUES (?, ?, ?, ?, ?, ?)",
+		upload.Hash, canonicalUploadPath(upload.Path), upload.OriginalName, upload.Mime, upload.Size, upload.Uploader,
+	)
+	return err
+}
+
+// RestoreUpload 从备份恢复上传记录，相同路径已存在时覆盖（保留备份中的下载权限和下载次数）
+func RestoreUpload(tx *sql.Tx, upload *Upload) error {
+	_, err := tx.Exec(
+		`INSERT INTO uploads (hash, path, original_name, mime, size, uploader, visibility, download_count, last_download_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET hash = excluded.hash, original_name = excluded.original_name,
+			mime = excluded.mime, size = excluded.size, uploader = excluded.uploader, visibility = excluded.visibility,
+			download_count = excluded.download_count, last_download_at = excluded.last_download_at, created_at = excluded.created_at`,
+		upload.Hash, canonicalUploadPath(upload.Path), upload.OriginalName, upload.Mime, upload.Size, upload.Uploader,
+		upload.Visibility, upload.DownloadCount, upload.LastDownloadAt, upload.CreatedAt,
+	)
+	return err
+}
+
+// UpdateUploadVisibility 设置上传文件的下载权限，记录不存在时返回 sql.ErrNoRows
+func UpdateUploadVisibility(tx *sql.Tx, path, visibility string) error {
+	result, err := tx.Exec("UPDATE uploads SET visibility = ? WHERE path = ?", visibility, canonicalUploadPath(path))
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// IncrementDownloadCount 下载次数加一，并记录最后下载时间
+func IncrementDownloadCount(tx *sql.Tx, path string) error {
+	_, err := tx.Exec(
+		"UPDATE uploads SET download_count = download_count + 1, last_download_at = ? WHERE path = ?",
+		time.Now().Format("2006-01-02 15:04:05"), canonicalUploadPath(path),
+	)
+	return err
+}
+
+// DeleteUpload 删除上传记录
+func DeleteUpload(tx *sql.Tx, path string) error {
+	_, err := tx.Exec("DELETE FROM uploads WHERE path = ?", canonicalUploadPath(path))
+	return err
+}
+
+// CountUploadReferences 统计上传文件被引用的次数
+// 包括站点链接、站点图标、页面Logo，以及公告内容和页脚中的链接（分类只有图标类名，不引用上传文件）
+func CountUploadReferences(db rowQueryer, path string) (int, error) {
+	canonical := canonicalUploadPath(path)
+	relative := "." + canonical
+	contains := "%" + canonical + "%"
+
+	var refs int
+	err := db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM sites WHERE href IN (?, ?)) +
+			(SELECT COUNT(*) FROM sites WHERE logo IN (?, ?)) +
+			(SELECT COUNT(*) FROM page_config WHERE logo IN (?, ?)) +
+			(SELECT COUNT(*) FROM page_config WHERE footer_text LIKE ?) +
+			(SELECT COUNT(*) FROM announcements WHERE content LIKE ?)
+	`, canonical, relative, canonical, relative, canonical, relative, contains, contains).Scan(&refs)
+	return refs, err
+}
+
+// DeleteSiteFile 删除站点关联的上传文件记录，并登记为待删除文件
+// 文件仍被其他站点或页面配置引用时保留，调用前需先在事务中删除或修改当前引用。
+// 存储中的文件在事务提交后由 RemovePendingFiles 删除，事务回滚时登记随之撤销，文件不受影响
+func DeleteSiteFile(tx *sql.Tx, href string) error {
+	if !isUploadedFile(href) {
+		return nil
+	}
+
+	refs, err := CountUploadReferences(tx, href)
+	if err != nil {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+
+	if err := DeleteUpload(tx, href); err != nil {
+		return err
+	}
+	return QueueFileRemoval(tx, href)
+}
+
+// QueueFileRemoval 在事务中把上传文件登记为待删除文件，事务提交后由 RemovePendingFiles 删除
+func QueueFileRemoval(tx *sql.Tx, href string) error {
+	_, err := tx.Exec(
+		"INSERT OR REPLACE INTO pending_file_removals (path, queued_at) VALUES (?, ?)",
+		canonicalUploadPath(href), time.Now().UnixNano(),
+	)
+	return err
+}
+
+// pendingRemovalMu 保证同一时间只有一个 RemovePendingFiles 在删除文件
+var pendingRemovalMu sync.Mutex
+
+// RemovePendingFiles 从存储中删除已提交的事务登记的待删除文件（见 DeleteSiteFile）
+// 文件重新被引用、重新登记了上传记录或登记后重新上传过时只取消登记；删除失败的文件保留登记，下次调用时重试
+func RemovePendingFiles(db *sql.DB) error {
+	pendingRemovalMu.Lock()
+	defer pendingRemovalMu.Unlock()
+
+	rows, err := db.Query("SELECT path, queued_at FROM pending_file_removals ORDER BY queued_at")
+	if err != nil {
+		return err
+	}
+	type pendingFile struct {
+		path     string
+		queuedAt int64
+	}
+	var pending []pendingFile
+	for rows.Next() {
+		var f pendingFile
+		if err := rows.Scan(&f.path, &f.queuedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, f := range pending {
+		remove, err := isPendingFileRemovable(db, f.path, time.Unix(0, f.queuedAt))
+		if err == nil && remove {
+			err = removeUploadedFile(f.path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.path, err))
+			continue
+		}
+		if _, err := db.Exec("DELETE FROM pending_file_removals WHERE path = ? AND queued_at = ?", f.path, f.queuedAt); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isPendingFileRemovable 判断待删除文件现在是否仍可删除：没有引用和上传记录，且登记后没有重新上传
+func isPendingFileRemovable(db *sql.DB, accessPath string, queuedAt time.Time) (bool, error) {
+	refs, err := CountUploadReferences(db, accessPath)
+	if err != nil || refs > 0 {
+		return false, err
+	}
+	if _, err := GetUploadByPath(db, accessPath); err != sql.ErrNoRows {
+		return false, err
+	}
+
+	key, err := storage.KeyFromURL(accessPath)
+	if err != nil {
+		return false, nil
+	}
+	info, err := storage.Default.Stat(key)
+	if err == storage.ErrNotExist {
+		// 文件已不存在，只需取消登记
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// 上传相同内容的文件时会重新写入（见 handlers.saveUploadObject），修改时间不早于登记时间说明可能刚重新上传。
+	// 部分存储的修改时间只精确到秒，按秒比较
+	return info.ModTime.Before(queuedAt.Truncate(time.Second)), nil
+}
+
+// removeUploadedFile 从存储中删除上传文件及其缩略图
+func removeUploadedFile(href string) error {
+	// 路径不合法（如包含..）时不做任何删除
+	key, err := storage.KeyFromURL(href)
+	if err != nil {
+		return nil
+	}
+
+	// 图标缩略图保存在同级 thumbs 目录，命名为 <文件名>_<尺寸>.<格式>（见 utils.ThumbnailPath）
+	dir, name := path.Split(key)
+	base := strings.TrimSuffix(name, path.Ext(name))
+	if thumbs, err := storage.Default.List(dir + "thumbs/" + base + "_"); err == nil {
+		for _, thumb := range thumbs {
+			storage.Default.Delete(thumb.Key)
+		}
+	}
+
+	return storage.Default.Delete(key)
+}
+
+// isUploadedFile 判断是否是上传的文件
+func isUploadedFile(href string) bool {
+	return strings.HasPrefix(href, "/uploads/") || strings.HasPrefix(href, "./uploads/")
+}
+
+// canonicalUploadPath 统一上传文件路径为 /uploads/... 形式
+func canonicalUploadPath(href string) string {
+	return strings.TrimPrefix(href, ".")
+}
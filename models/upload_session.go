@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 分片上传会话状态
+const (
+	UploadSessionPending    = "pending"    // 接收分片中
+	UploadSessionCompleting = "completing" // 正在合并分片
+)
+
+// UploadSession 分片上传会话
+type UploadSession struct {
+	ID        string `json:"upload_id"`
+	Filename  string `json:"filename"`
+	Type      string `json:"type"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunk_size"`
+	SHA256    string `json:"sha256"`
+	Status    string `json:"status"`
+	Uploader  string `json:"uploader"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// TotalChunks 分片总数
+func (s *UploadSession) TotalChunks() int {
+	return int((s.Size + s.ChunkSize - 1) / s.ChunkSize)
+}
+
+// ChunkLength 返回第 index 个分片应有的大小（最后一个分片可能较小）
+func (s *UploadSession) ChunkLength(index int) int64 {
+	if index == s.TotalChunks()-1 {
+		return s.Size - int64(index)*s.ChunkSize
+	}
+	return s.ChunkSize
+}
+
+// GetUploadSession 获取分片上传会话，不存在时返回 sql.ErrNoRows
+func GetUploadSession(db rowQueryer, id string) (*UploadSession, error) {
+	session := &UploadSession{}
+	err := db.QueryRow(
+		"SELECT id, filename, type, size, chunk_size, sha256, status, uploader, created_at, updated_at FROM upload_sessions WHERE id = ?",
+		id,
+	).Scan(&session.ID, &session.Filename, &session.Type, &session.Size, &session.ChunkSize, &session.SHA256, &session.Status, &session.Uploader, &session.CreatedAt, &session.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetExpiredUploadSessionIDs 获取最后更新时间早于 before 的会话ID（正在合并分片的会话除外）
+func GetExpiredUploadSessionIDs(db *sql.DB, before time.Time) ([]string, error) {
+	rows, err := db.Query(
+		"SELECT id FROM upload_sessions WHERE updated_at < ? AND status = ?",
+		before.UTC().Format("2006-01-02 15:04:05"), UploadSessionPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CreateUploadSession 创建分片上传会话
+func CreateUploadSession(tx *sql.Tx, session *UploadSession) error {
+	_, err := tx.Exec(
+		"INSERT INTO upload_sessions (id, filename, type, size, chunk_size, sha256, status, uploader) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		session.ID, session.Filename, session.Type, session.Size, session.ChunkSize, session.SHA256, UploadSessionPending, session.Uploader,
+	)
+	return err
+}
+
+// TouchUploadSession 更新会话的最后活动时间（避免上传中的会话被当作已放弃清理）
+func TouchUploadSession(tx *sql.Tx, id string) error {
+	_, err := tx.Exec("UPDATE upload_sessions SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+	return err
+}
+
+// SetUploadSessionStatus 将会话状态从 from 改为 to，返回是否修改成功
+// 用于保证同一会话只有一个请求在合并分片
+func SetUploadSessionStatus(tx *sql.Tx, id, from, to string) (bool, error) {
+	result, err := tx.Exec(
+		"UPDATE upload_sessions SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?",
+		to, id, from,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ResetCompletingUploadSessions 将所有合并中的会话恢复为接收分片状态，返回恢复的会话数
+// 只在启动时调用：此时没有请求在合并分片，合并中的会话是上次合并时服务中断留下的
+func ResetCompletingUploadSessions(db *sql.DB) (int64, error) {
+	result, err := db.Exec(
+		"UPDATE upload_sessions SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE status = ?",
+		UploadSessionPending, UploadSessionCompleting,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteUploadSession 删除分片上传会话
+func DeleteUploadSession(tx *sql.Tx, id string) error {
+	_, err := tx.Exec("DELETE FROM upload_sessions WHERE id = ?", id)
+	return err
+}
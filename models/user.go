@@ -88,3 +88,38 @@ func UpdatePassword(db *sql.DB, username string, newPassword string) error {
 	}
 	return nil
 }
+
+// GetAllUsers 获取所有用户（包含密码哈希，仅用于备份）
+func GetAllUsers(db *sql.DB) ([]User, error) {
+	rows, err := db.Query("SELECT id, username, password, created_at, updated_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// IsPasswordHash 检查是否为bcrypt密码哈希（恢复备份时拒绝明文密码）
+func IsPasswordHash(hash string) bool {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+// RestoreUser 从备份恢复用户：用户名已存在时更新密码哈希，不存在时创建
+func RestoreUser(tx *sql.Tx, user *User) error {
+	_, err := tx.Exec(
+		`INSERT INTO users (username, password, created_at, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(username) DO UPDATE SET password = excluded.password, updated_at = excluded.updated_at`,
+		user.Username, user.Password, user.CreatedAt, user.UpdatedAt,
+	)
+	return err
+}
@@ -0,0 +1,524 @@
+// Package navdoc 导航数据文档（nav.json 格式）的类型定义和编解码
+//
+// nav.json、/api/nav、JSON导入导出和备份中的 nav.json 使用同一格式：一个JSON数组，
+// 依次为页面配置（type=page_config，带格式版本号 version）、公告配置（type=announcement_config）和分类。
+//
+// 分类可以有子分类，有两种表示方式：平铺（所有分类都在数组中，子分类的 parent 为上级分类的 _id）
+// 和嵌套（数组中只有顶级分类，子分类在上级分类的 children 中）。解码时两种方式都支持，统一转换为平铺。
+// 站点可以有标签（tags，标签名称数组），没有标签时不输出该字段。
+package navdoc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Version 当前的文档格式版本，写在页面配置的 version 字段中（没有该字段的旧数据视为版本1）
+// 版本2增加了分类层级（parent、children），版本3增加了站点标签（tags）
+const Version = 3
+
+// 数组中特殊条目的 type 值，没有 type 的条目是分类
+const (
+	TypePageConfig         = "page_config"
+	TypeAnnouncementConfig = "announcement_config"
+)
+
+// AnnouncementConfigID 公告配置条目的 _id
+const AnnouncementConfigID = "announcement_config"
+
+// maxErrors 解码最多返回的错误条数
+const maxErrors = 100
+
+// maxNesting 解码时 children 最多嵌套的层数（层级限制由调用方校验，这里只防止过深的递归）
+const maxNesting = 32
+
+// ErrInvalidDocument 文档不是JSON数组
+var ErrInvalidDocument = errors.New("导航数据必须是JSON数组")
+
+// Document 导航数据文档
+type Document struct {
+	Version            int
+	PageConfig         *PageConfig         // 没有页面配置时为 nil
+	AnnouncementConfig *AnnouncementConfig // 没有公告配置时为 nil
+	Categories         []Category          // 平铺的分类（上级分类在子分类之前时按深度优先顺序）
+
+	// Nested 为 true 时编码为嵌套结构（子分类放在上级分类的 children 中），否则平铺
+	Nested bool
+}
+
+// PageConfig 页面配置
+type PageConfig struct {
+	Title      string `json:"title"`
+	Subtitle   string `json:"subtitle"`
+	Logo       string `json:"logo"`
+	FooterText string `json:"footer_text"`
+	ICP        string `json:"icp"`
+	// FooterFormat 页脚原始内容的格式（导出和备份中保留，导入时原样恢复）；nav.json 中页脚已渲染为HTML，不输出该字段
+	FooterFormat string `json:"footer_format,omitempty"`
+
+	Index int `json:"-"` // 在文档数组中的位置（解码时设置，用于定位错误）
+}
+
+// AnnouncementConfig 公告配置
+type AnnouncementConfig struct {
+	Interval      *int           `json:"interval,omitempty"` // 轮播间隔（毫秒），为 nil 时不修改
+	Announcements []Announcement `json:"announcements"`
+
+	Index int `json:"-"`
+}
+
+// Announcement 公告；nav.json 中只输出展示需要的字段
+type Announcement struct {
+	ID        int    `json:"id,omitempty"`
+	Timestamp string `json:"timestamp"`
+	Content   string `json:"content"`
+	Format    string `json:"format,omitempty"`
+	PublishAt string `json:"publish_at,omitempty"`
+	ExpireAt  string `json:"expire_at,omitempty"`
+	Priority  int    `json:"priority,omitempty"`
+	Pinned    bool   `json:"pinned"`
+	Severity  string `json:"severity"`
+}
+
+// Category 分类及其站点
+type Category struct {
+	ID       string     `json:"_id"`
+	Parent   string     `json:"parent,omitempty"` // 上级分类的 _id（平铺时使用），顶级分类为空
+	Classify string     `json:"classify"`
+	Icon     string     `json:"icon"`
+	Sites    []Site     `json:"sites"`
+	Children []Category `json:"children,omitempty"` // 子分类（嵌套时使用）
+
+	Index int    `json:"-"`
+	Path  string `json:"-"` // 在文档中的位置（如 [2].children[0]，解码时设置，用于定位错误）
+}
+
+// Field 分类在文档中的位置，用作错误字段的前缀
+func (c *Category) Field() string {
+	if c.Path != "" {
+		return c.Path
+	}
+	return fmt.Sprintf("[%d]", c.Index)
+}
+
+// Site 站点
+type Site struct {
+	Name string   `json:"name"`
+	Href string   `json:"href"`
+	Desc string   `json:"desc"`
+	Logo string   `json:"logo"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// pageConfigItem 页面配置在数组中的形式
+type pageConfigItem struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	*PageConfig
+}
+
+// announcementConfigItem 公告配置在数组中的形式
+type announcementConfigItem struct {
+	ID   string `json:"_id"`
+	Type string `json:"type"`
+	*AnnouncementConfig
+}
+
+// MarshalJSON 编码为数组形式：页面配置、公告配置、分类
+func (d *Document) MarshalJSON() ([]byte, error) {
+	items := make([]interface{}, 0, len(d.Categories)+2)
+	if d.PageConfig != nil {
+		items = append(items, pageConfigItem{Type: TypePageConfig, Version: Version, PageConfig: d.PageConfig})
+	}
+	if d.AnnouncementConfig != nil {
+		cfg := *d.AnnouncementConfig
+		if cfg.Announcements == nil {
+			cfg.Announcements = []Announcement{}
+		}
+		items = append(items, announcementConfigItem{ID: AnnouncementConfigID, Type: TypeAnnouncementConfig, AnnouncementConfig: &cfg})
+	}
+	categories := d.Categories
+	if d.Nested {
+		categories = Tree(categories)
+	}
+	for _, cat := range categories {
+		items = append(items, withEmptySites(cat))
+	}
+	return json.Marshal(items)
+}
+
+// withEmptySites 没有站点的分类（包括子分类）输出空数组而不是 null
+func withEmptySites(cat Category) Category {
+	if cat.Sites == nil {
+		cat.Sites = []Site{}
+	}
+	if len(cat.Children) > 0 {
+		children := make([]Category, len(cat.Children))
+		for i, child := range cat.Children {
+			children[i] = withEmptySites(child)
+		}
+		cat.Children = children
+	}
+	return cat
+}
+
+// Tree 把平铺的分类转换为嵌套结构：子分类按原来的顺序放在上级分类的 Children 中，Parent 清空
+// 上级分类不存在（或 _id 重复时不是第一个）的分类、以及形成循环的分类作为顶级分类
+func Tree(categories []Category) []Category {
+	first := make(map[string]int, len(categories)) // _id -> 第一次出现的位置
+	for i, cat := range categories {
+		if _, ok := first[cat.ID]; !ok {
+			first[cat.ID] = i
+		}
+	}
+	children := map[int][]int{}
+	var roots []int
+	for i, cat := range categories {
+		if p, ok := first[cat.Parent]; ok && cat.Parent != "" && p != i {
+			children[p] = append(children[p], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	visited := make([]bool, len(categories))
+	var build func(i int) Category
+	build = func(i int) Category {
+		visited[i] = true
+		cat := categories[i]
+		cat.Parent = ""
+		cat.Children = nil
+		for _, c := range children[i] {
+			if !visited[c] {
+				cat.Children = append(cat.Children, build(c))
+			}
+		}
+		return cat
+	}
+
+	result := make([]Category, 0, len(roots))
+	for _, i := range roots {
+		result = append(result, build(i))
+	}
+	for i := range categories {
+		if !visited[i] {
+			result = append(result, build(i))
+		}
+	}
+	return result
+}
+
+// Hierarchical 文档是否包含分类层级信息（版本2及以上，或有分类指定了上级分类）
+// 不包含时导入不应修改已有分类的层级
+func (d *Document) Hierarchical() bool {
+	if d.Version >= 2 {
+		return true
+	}
+	for _, cat := range d.Categories {
+		if cat.Parent != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Tagged 文档是否包含站点标签信息（版本3及以上，或有站点带标签）
+// 不包含时导入不应修改已有站点的标签；包含时没有 tags 的站点表示没有标签
+func (d *Document) Tagged() bool {
+	if d.Version >= 3 {
+		return true
+	}
+	for _, cat := range d.Categories {
+		for _, site := range cat.Sites {
+			if len(site.Tags) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterTag 只保留带指定标签（不区分大小写）的站点；没有这样的站点、子分类中也没有的分类去掉
+// 只处理平铺的分类（Decode 和 utils.BuildNavDocument 得到的都是平铺的分类）
+func (d *Document) FilterTag(tag string) {
+	keep := make([]bool, len(d.Categories))
+	first := make(map[string]int, len(d.Categories)) // _id -> 第一次出现的位置
+	for i := range d.Categories {
+		cat := &d.Categories[i]
+		if _, ok := first[cat.ID]; !ok {
+			first[cat.ID] = i
+		}
+		sites := make([]Site, 0, len(cat.Sites))
+		for _, site := range cat.Sites {
+			if hasTag(site.Tags, tag) {
+				sites = append(sites, site)
+			}
+		}
+		cat.Sites = sites
+		keep[i] = len(sites) > 0
+	}
+
+	// 保留有站点的分类的所有上级分类；遇到已保留的分类时停止（它的上级分类已经或将会被处理，也避免循环）
+	for i := range d.Categories {
+		if len(d.Categories[i].Sites) == 0 {
+			continue
+		}
+		for parent := d.Categories[i].Parent; parent != ""; {
+			p, ok := first[parent]
+			if !ok || keep[p] {
+				break
+			}
+			keep[p] = true
+			parent = d.Categories[p].Parent
+		}
+	}
+
+	categories := make([]Category, 0, len(d.Categories))
+	for i, cat := range d.Categories {
+		if keep[i] {
+			categories = append(categories, cat)
+		}
+	}
+	d.Categories = categories
+}
+
+// hasTag 标签列表中是否有指定标签（不区分大小写）
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON 解码数组形式的文档，见 Decode
+func (d *Document) UnmarshalJSON(data []byte) error {
+	doc, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	*d = *doc
+	return nil
+}
+
+// IsEmpty 文档中没有任何条目
+func (d *Document) IsEmpty() bool {
+	return d.PageConfig == nil && d.AnnouncementConfig == nil && len(d.Categories) == 0
+}
+
+// FieldError 解码错误，Field 为出错的位置（如 [2].sites[0].href）
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// DecodeErrors 解码时发现的字段错误
+type DecodeErrors []FieldError
+
+// Error 实现 error 接口
+func (e DecodeErrors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fe := range e {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Decode 解码导航数据文档
+// 不是JSON数组时返回 ErrInvalidDocument；字段类型错误、未知的条目类型、重复的配置条目和不支持的版本返回 DecodeErrors。
+// 只检查结构，字段内容（长度、链接格式等）由调用方校验
+func Decode(data []byte) (*Document, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, ErrInvalidDocument
+	}
+
+	d := &decoder{doc: &Document{Version: 1}}
+	for i, raw := range items {
+		if len(d.errs) >= maxErrors {
+			break
+		}
+		d.item(i, raw)
+	}
+
+	if len(d.errs) > 0 {
+		if len(d.errs) > maxErrors {
+			d.errs = d.errs[:maxErrors]
+		}
+		return nil, d.errs
+	}
+	return d.doc, nil
+}
+
+// decoder 解码状态
+type decoder struct {
+	doc  *Document
+	errs DecodeErrors
+}
+
+// add 记录一个错误
+func (d *decoder) add(field, message string) {
+	d.errs = append(d.errs, FieldError{Field: field, Message: message})
+}
+
+// item 解码数组中的一个条目
+func (d *decoder) item(index int, raw json.RawMessage) {
+	prefix := fmt.Sprintf("[%d]", index)
+
+	var head struct {
+		Type string `json:"type"`
+	}
+	if !d.unmarshal(prefix, raw, &head) {
+		return
+	}
+
+	switch head.Type {
+	case "":
+		d.category(prefix, index, raw, "", 0)
+	case TypePageConfig:
+		d.pageConfig(prefix, index, raw)
+	case TypeAnnouncementConfig:
+		d.announcementConfig(prefix, index, raw)
+	default:
+		d.add(prefix+".type", "未知的数据类型: "+head.Type)
+	}
+}
+
+// pageConfig 解码页面配置条目
+func (d *decoder) pageConfig(prefix string, index int, raw json.RawMessage) {
+	if d.doc.PageConfig != nil {
+		d.add(prefix+".type", "页面配置重复")
+		return
+	}
+
+	var item struct {
+		Version *int `json:"version"`
+		PageConfig
+	}
+	if !d.unmarshal(prefix, raw, &item) {
+		return
+	}
+	if item.Version != nil {
+		if *item.Version < 1 || *item.Version > Version {
+			d.add(prefix+".version", fmt.Sprintf("不支持的格式版本 %d（最高支持 %d）", *item.Version, Version))
+			return
+		}
+		d.doc.Version = *item.Version
+	}
+
+	cfg := item.PageConfig
+	cfg.Index = index
+	d.doc.PageConfig = &cfg
+}
+
+// announcementConfig 解码公告配置条目
+func (d *decoder) announcementConfig(prefix string, index int, raw json.RawMessage) {
+	if d.doc.AnnouncementConfig != nil {
+		d.add(prefix+".type", "公告配置重复")
+		return
+	}
+
+	var item struct {
+		Interval      *int              `json:"interval"`
+		Announcements []json.RawMessage `json:"announcements"`
+	}
+	if !d.unmarshal(prefix, raw, &item) {
+		return
+	}
+
+	cfg := &AnnouncementConfig{Interval: item.Interval, Announcements: []Announcement{}, Index: index}
+	for i, rawAnn := range item.Announcements {
+		var ann Announcement
+		if d.unmarshal(fmt.Sprintf("%s.announcements[%d]", prefix, i), rawAnn, &ann) {
+			cfg.Announcements = append(cfg.Announcements, ann)
+		}
+	}
+	d.doc.AnnouncementConfig = cfg
+}
+
+// category 解码分类条目，children 中的子分类跟在上级分类后面平铺，parent 设为上级分类的 _id
+func (d *decoder) category(prefix string, index int, raw json.RawMessage, parent string, depth int) {
+	var item struct {
+		ID       string            `json:"_id"`
+		Parent   string            `json:"parent"`
+		Classify string            `json:"classify"`
+		Icon     string            `json:"icon"`
+		Sites    []json.RawMessage `json:"sites"`
+		Children []json.RawMessage `json:"children"`
+	}
+	if !d.unmarshal(prefix, raw, &item) {
+		return
+	}
+	if parent != "" {
+		if item.Parent != "" && item.Parent != parent {
+			d.add(prefix+".parent", "与所在的上级分类不一致")
+		}
+		item.Parent = parent
+	}
+
+	cat := Category{ID: item.ID, Parent: item.Parent, Classify: item.Classify, Icon: item.Icon, Sites: []Site{}, Index: index, Path: prefix}
+	for i, rawSite := range item.Sites {
+		var site Site
+		if d.unmarshal(fmt.Sprintf("%s.sites[%d]", prefix, i), rawSite, &site) {
+			cat.Sites = append(cat.Sites, site)
+		}
+	}
+	d.doc.Categories = append(d.doc.Categories, cat)
+
+	if len(item.Children) > 0 && depth >= maxNesting {
+		d.add(prefix+".children", "分类嵌套过深")
+		return
+	}
+	for i, rawChild := range item.Children {
+		d.category(fmt.Sprintf("%s.children[%d]", prefix, i), index, rawChild, item.ID, depth+1)
+	}
+}
+
+// unmarshal 解码一个值，失败时按字段记录错误
+// null 视为空对象（与缺少该条目的字段相同），由调用方的校验处理必填字段
+func (d *decoder) unmarshal(prefix string, raw json.RawMessage, v interface{}) bool {
+	err := json.Unmarshal(raw, v)
+	if err == nil {
+		return true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := prefix
+		if typeErr.Field != "" {
+			field += "." + typeErr.Field
+		}
+		d.add(field, "必须是"+kindName(typeErr.Type))
+		return false
+	}
+	d.add(prefix, "格式错误")
+	return false
+}
+
+// kindName 类型的中文名称（用于错误提示）
+func kindName(t reflect.Type) string {
+	if t == nil {
+		return "有效的值"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "字符串"
+	case reflect.Bool:
+		return "布尔值"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "整数"
+	case reflect.Float32, reflect.Float64:
+		return "数字"
+	case reflect.Slice, reflect.Array:
+		return "数组"
+	case reflect.Ptr:
+		return kindName(t.Elem())
+	default:
+		return "对象"
+	}
+}
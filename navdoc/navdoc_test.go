@@ -0,0 +1,205 @@
+package navdoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// seedDocuments 读取 testdata 中版本1到3、平铺和嵌套形式的示例文档
+func seedDocuments(tb testing.TB) map[string][]byte {
+	tb.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil || len(files) == 0 {
+		tb.Fatalf("no seed documents: %v", err)
+	}
+	seeds := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		seeds[filepath.Base(file)] = data
+	}
+	return seeds
+}
+
+// withoutPositions 去掉解码时记录的位置信息，便于比较两次解码的结果
+func withoutPositions(doc *Document) Document {
+	d := *doc
+	d.Nested = false
+	if d.PageConfig != nil {
+		cfg := *d.PageConfig
+		cfg.Index = 0
+		d.PageConfig = &cfg
+	}
+	if d.AnnouncementConfig != nil {
+		cfg := *d.AnnouncementConfig
+		cfg.Index = 0
+		d.AnnouncementConfig = &cfg
+	}
+	d.Categories = make([]Category, len(doc.Categories))
+	for i, cat := range doc.Categories {
+		cat.Index, cat.Path = 0, ""
+		sites := make([]Site, len(cat.Sites))
+		for j, site := range cat.Sites {
+			if len(site.Tags) == 0 {
+				site.Tags = nil // 空标签数组编码时省略
+			}
+			sites[j] = site
+		}
+		cat.Sites = sites
+		d.Categories[i] = cat
+	}
+	return d
+}
+
+func TestDecodeSeedDocuments(t *testing.T) {
+	tests := map[string]struct {
+		version    int
+		categories []string // 解码后平铺的分类 _id:parent
+	}{
+		"v1_flat.json":   {1, []string{"tools:", "empty:"}},
+		"v1_nested.json": {1, []string{"dev:", "dev-docs:dev"}},
+		"v2_flat.json":   {2, []string{"dev:", "dev-docs:dev", "dev-tools:dev"}},
+		"v2_nested.json": {2, []string{"dev:", "dev-docs:dev", "dev-docs-spec:dev-docs", "news:"}},
+		"v3_flat.json":   {3, []string{"tools:", "tools-online:tools"}},
+		"v3_nested.json": {3, []string{"tools:", "tools-online:tools", "tools-local:tools"}},
+	}
+	seeds := seedDocuments(t)
+	for name, want := range tests {
+		doc, err := Decode(seeds[name])
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if doc.Version != want.version {
+			t.Errorf("%s: version = %d, want %d", name, doc.Version, want.version)
+		}
+		var got []string
+		for _, cat := range doc.Categories {
+			got = append(got, cat.ID+":"+cat.Parent)
+		}
+		if strings.Join(got, ",") != strings.Join(want.categories, ",") {
+			t.Errorf("%s: categories = %v, want %v", name, got, want.categories)
+		}
+
+		// 平铺和嵌套编码后再解码，得到相同的文档
+		for _, nested := range []bool{false, true} {
+			doc.Nested = nested
+			data, err := json.Marshal(doc)
+			if err != nil {
+				t.Fatalf("%s: marshal: %v", name, err)
+			}
+			again, err := Decode(data)
+			if err != nil {
+				t.Errorf("%s: decode marshaled (nested=%v): %v", name, nested, err)
+				continue
+			}
+			expected := withoutPositions(doc)
+			if doc.PageConfig != nil {
+				expected.Version = Version // 编码时写入当前版本号
+			}
+			if got := withoutPositions(again); !reflect.DeepEqual(got, expected) {
+				t.Errorf("%s: round trip (nested=%v)\n got %+v\nwant %+v", name, nested, got, expected)
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		data  string
+		field string
+	}{
+		{`{"_id": "a"}`, ""},
+		{`[{"_id": "a", "classify": 1}]`, "[0].classify"},
+		{`[{"type": "unknown"}]`, "[0].type"},
+		{`[{"type": "page_config"}, {"type": "page_config"}]`, "[1].type"},
+		{`[{"type": "page_config", "version": 99}]`, "[0].version"},
+		{`[{"_id": "a", "sites": [{"name": "x", "href": 1}]}]`, "[0].sites[0].href"},
+		{`[{"_id": "a", "children": [{"_id": "b", "parent": "c"}]}]`, "[0].children[0].parent"},
+		{`[{"_id": "a", "sites": [{"tags": "x"}]}]`, "[0].sites[0].tags"},
+	}
+	for _, tt := range tests {
+		_, err := Decode([]byte(tt.data))
+		if tt.field == "" {
+			if err != ErrInvalidDocument {
+				t.Errorf("Decode(%s) = %v, want ErrInvalidDocument", tt.data, err)
+			}
+			continue
+		}
+		errs, ok := err.(DecodeErrors)
+		if !ok || len(errs) == 0 || errs[0].Field != tt.field {
+			t.Errorf("Decode(%s) = %v, want error at %s", tt.data, err, tt.field)
+		}
+	}
+}
+
+func TestDecodeDeepNesting(t *testing.T) {
+	data := strings.Repeat(`[{"_id": "a", "children": `, maxNesting+2) + "[]" + strings.Repeat("}]", maxNesting+2)
+	_, err := Decode([]byte(data))
+	errs, ok := err.(DecodeErrors)
+	if !ok || len(errs) != 1 || errs[0].Message != "分类嵌套过深" {
+		t.Fatalf("err = %v, want nesting error", err)
+	}
+}
+
+// FuzzDecode 任意输入都不应导致 panic；解码成功的文档编码后能再次解码，且再次编码的结果不变
+func FuzzDecode(f *testing.F) {
+	for _, data := range seedDocuments(f) {
+		f.Add(data)
+	}
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(`[null, {}, {"type": null}]`))
+	f.Add([]byte(`[{"_id": "a", "parent": "a"}, {"_id": "b", "parent": "c"}, {"_id": "c", "parent": "b"}]`))
+	f.Add([]byte(`[{"_id": "a"}, {"_id": "a", "children": [{"_id": "a"}]}]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := Decode(data)
+		if err != nil {
+			if _, ok := err.(DecodeErrors); !ok && err != ErrInvalidDocument {
+				t.Fatalf("unexpected error type %T: %v", err, err)
+			}
+			return
+		}
+
+		flat, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		again, err := Decode(flat)
+		if err != nil {
+			t.Fatalf("decode marshaled document: %v\n%s", err, flat)
+		}
+		flatAgain, err := json.Marshal(again)
+		if err != nil {
+			t.Fatalf("marshal again: %v", err)
+		}
+		if !bytes.Equal(flat, flatAgain) {
+			t.Fatalf("round trip changed the document\nfirst:  %s\nsecond: %s", flat, flatAgain)
+		}
+
+		// 嵌套编码：所有分类都保留（层级过深时解码报错）
+		doc.Nested = true
+		nested, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("marshal nested: %v", err)
+		}
+		fromNested, err := Decode(nested)
+		if err != nil {
+			if errs, ok := err.(DecodeErrors); ok && len(errs) == 1 && errs[0].Message == "分类嵌套过深" {
+				return
+			}
+			t.Fatalf("decode nested document: %v\n%s", err, nested)
+		}
+		if len(fromNested.Categories) != len(doc.Categories) {
+			t.Fatalf("nested round trip has %d categories, want %d", len(fromNested.Categories), len(doc.Categories))
+		}
+	})
+}
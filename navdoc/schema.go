@@ -0,0 +1,9 @@
+package navdoc
+
+import _ "embed"
+
+// Schema 文档格式的 JSON Schema（draft 2020-12），由 GET /api/nav/schema 提供
+// 修改 Document 的结构或 Version 时需同步更新 schema.json
+//
+//go:embed schema.json
+var Schema []byte
@@ -47,6 +47,68 @@ function getArrayIndex(arr, obj) {
 
 var f_Array = "";
 
+// 标签筛选：在页面顶部列出所有站点标签，点击后只显示带该标签的站点（再次点击取消）
+// 页面地址带 ?tag= 时默认选中该标签
+function initTagFilter(siteTags) {
+    var names = {};
+    $.each(siteTags, function(i, tags) {
+        $.each(tags, function(j, tag) {
+            var key = tag.toLowerCase();
+            if (!names[key]) {
+                names[key] = tag;
+            }
+        });
+    });
+    var keys = Object.keys(names).sort(function(a, b) {
+        return names[a].localeCompare(names[b]);
+    });
+    if (keys.length === 0) {
+        return;
+    }
+
+    var bar = $("<div class='box tag-filter'></div>");
+    $.each(keys, function(i, key) {
+        $("<a href='javascript:void(0)' class='tag-chip'></a>").text(names[key]).attr("data-tag", key).appendTo(bar);
+    });
+    $(".about").after(bar);
+
+    function apply(tag) {
+        bar.children(".tag-chip").each(function() {
+            $(this).toggleClass("active", $(this).attr("data-tag") === tag);
+        });
+        $(".box_default").each(function() {
+            var visible = 0;
+            $(this).find("a[data-site]").each(function() {
+                var tags = siteTags[$(this).attr("data-site")];
+                var match = !tag || tags.some(function(t) {
+                    return t.toLowerCase() === tag;
+                });
+                $(this).toggle(match);
+                if (match) {
+                    visible++;
+                }
+            });
+            $(this).toggle(visible > 0 || !tag);
+        });
+    }
+
+    var current = "";
+    bar.on("click", ".tag-chip", function() {
+        var tag = $(this).attr("data-tag");
+        current = current === tag ? "" : tag;
+        apply(current);
+    });
+
+    var initial = getQueryVariable("tag");
+    if (initial) {
+        initial = decodeURIComponent(initial.replace(/\+/g, " ")).toLowerCase();
+        if (names[initial]) {
+            current = initial;
+            apply(current);
+        }
+    }
+}
+
 $(function() {
     // Load nav.json from runtime-generated file
     var navUrl = "/nav.json?t=" + new Date().getTime();
@@ -76,7 +138,8 @@ $(function() {
 
             // Update footer text
             if (pageConfig.footer_text) {
-                $('.footer .copyright .copyright-text p').text(pageConfig.footer_text);
+                // footer_text 已由后端按白名单过滤
+                $('.footer .copyright .copyright-text p').html(pageConfig.footer_text);
             }
 
             // Update ICP
@@ -102,17 +165,44 @@ $(function() {
             });
         }
 
+        // 嵌套格式（子分类在 children 中）展开为平铺，子分类紧跟在上级分类后面
+        var flat = [];
+        (function flatten(list, parent) {
+            $.each(list, function(i, item) {
+                if (parent) {
+                    item["parent"] = parent;
+                }
+                flat.push(item);
+                if (item["children"]) {
+                    flatten(item["children"], item["_id"]);
+                }
+            });
+        })(data, "");
+        data = flat;
+
         var strHtml = "";
+        // 子分类（带 parent 字段）在左侧菜单中按层级缩进
+        var depths = {};
+        // 每个站点的标签（按站点在页面中的顺序），用于标签筛选
+        var siteTags = [];
         $.each(data, function(infoIndex, info) {
             var navstr = "";
             var navtitle = "";
-            strHtml += "<li><a href='#" + info["_id"] + "'><span class ='" + info["icon"] + "'></span>" + info["classify"] + "</a></li>";
+            var depth = info["parent"] && depths[info["parent"]] !== undefined ? depths[info["parent"]] + 1 : 0;
+            depths[info["_id"]] = depth;
+            if (depth > 0) {
+                strHtml += "<li class='sub-nav' style='padding-left:" + (12 + depth * 16) + "px'>";
+            } else {
+                strHtml += "<li>";
+            }
+            strHtml += "<a href='#" + info["_id"] + "'><span class ='" + info["icon"] + "'></span>" + info["classify"] + "</a></li>";
             navtitle += "<div class='box box_default'><a href='#' id='" + info["_id"] + "'></a> <div class='sub-category'> <div><span class='" + info["icon"] + "'></span>" + info["classify"] + "</div> </div><div>";
             $.each(info["sites"], function(i, str) {
                 if (str["logo"] == "no-logo") {
                     str["logo"] = "/static/logo.svg";
                 }
-                navstr += '<a target="_blank" href="' + str["href"] + '">';
+                navstr += '<a target="_blank" href="' + str["href"] + '" data-site="' + siteTags.length + '">';
+                siteTags.push(str["tags"] || []);
                 navstr += '<div class="item">';
                 navstr += '    <div class="logo">'
                 navstr += '       <img src="' + str["logo"] + '"></div> ';
@@ -123,6 +213,7 @@ $(function() {
             $(".footer").before(navstr);
         })
         $("#navItem").append(strHtml);
+        initTagFilter(siteTags);
     });
 
     // Custom module rendering
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage 本地文件系统存储
+type LocalStorage struct {
+	Root string
+}
+
+// NewLocalStorage 创建本地存储，root 为上传目录
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{Root: root}
+}
+
+// path 返回对象键对应的本地路径
+func (s *LocalStorage) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+// Put 写入对象，先写临时文件再重命名，避免其他请求读到写了一半的文件
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Get 读取对象
+func (s *LocalStorage) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(fullPath)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		return nil, nil, ErrNotExist
+	}
+	return file, localObjectInfo(key, info), nil
+}
+
+// Stat 获取对象信息
+func (s *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return localObjectInfo(key, info), nil
+}
+
+// Delete 删除对象
+func (s *LocalStorage) Delete(key string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List 列出对象，跳过写入中的临时文件
+func (s *LocalStorage) List(prefix string) ([]ObjectInfo, error) {
+	// 只遍历前缀所在的目录
+	walkRoot := s.Root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir, err := CleanKey(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		walkRoot = filepath.Join(s.Root, filepath.FromSlash(dir))
+	}
+
+	var objects []ObjectInfo
+	err := filepath.Walk(walkRoot, func(fullPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(info.Name(), ".tmp") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Root, fullPath)
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, *localObjectInfo(key, info))
+		}
+		return nil
+	})
+	return objects, err
+}
+
+// URL 本地文件由服务端直接提供
+func (s *LocalStorage) URL(key string) (string, error) {
+	return "", nil
+}
+
+func localObjectInfo(key string, info os.FileInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:         key,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		ContentType: ContentType(key),
+	}
+}
@@ -0,0 +1,545 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3兼容存储（AWS S3、MinIO、阿里云OSS、腾讯云COS等），使用AWS签名V4，不依赖SDK
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+	s3MaxPresign      = 7 * 24 * time.Hour
+
+	// 连接和等待响应头的超时。不设置整个请求的超时（http.Client.Timeout），
+	// 否则上传、代理下载和备份大文件时传输超过该时间会被中断
+	s3DialTimeout           = 10 * time.Second
+	s3TLSHandshakeTimeout   = 10 * time.Second
+	s3ResponseHeaderTimeout = 60 * time.Second
+)
+
+// S3Options S3存储配置
+type S3Options struct {
+	Endpoint      string // 如 https://s3.amazonaws.com 或 http://minio:9000
+	Region        string
+	Bucket        string
+	AccessKey     string
+	SecretKey     string
+	Prefix        string        // 对象键前缀，如 nav/
+	PathStyle     bool          // 使用 endpoint/bucket/key 形式的地址（MinIO需要）
+	Presign       bool          // 访问文件时重定向到预签名地址，否则由服务端代理
+	PresignExpiry time.Duration // 预签名地址有效期
+}
+
+// S3Storage S3兼容存储
+type S3Storage struct {
+	opts     S3Options
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Storage 创建S3存储
+func NewS3Storage(opts S3Options) (*S3Storage, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("S3存储需要配置 S3_ENDPOINT 和 S3_BUCKET")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("S3_ENDPOINT 格式错误: %s", opts.Endpoint)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.PresignExpiry <= 0 || opts.PresignExpiry > s3MaxPresign {
+		opts.PresignExpiry = 15 * time.Minute
+	}
+	if opts.Prefix != "" && !strings.HasSuffix(opts.Prefix, "/") {
+		opts.Prefix += "/"
+	}
+
+	return &S3Storage{
+		opts:     opts,
+		endpoint: endpoint,
+		client:   newS3Client(),
+		now:      time.Now,
+	}, nil
+}
+
+// newS3Client 创建访问S3的HTTP客户端，只限制连接、TLS握手和等待响应头的时间，不限制传输时间
+func newS3Client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   s3DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   s3TLSHandshakeTimeout,
+			ResponseHeaderTimeout: s3ResponseHeaderTimeout,
+			ExpectContinueTimeout: time.Second,
+			MaxIdleConnsPerHost:   16,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+// objectURL 返回对象的请求地址（key 为空时返回存储桶地址）
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	objectPath := ""
+	if key != "" {
+		objectPath = "/" + s.opts.Prefix + key
+	}
+	if s.opts.PathStyle {
+		u.Path = u.Path + "/" + s.opts.Bucket + objectPath
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+		u.Path = u.Path + objectPath
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawPath = s3EncodePath(u.Path)
+	return &u
+}
+
+// do 发送签名后的请求
+func (s *S3Storage) do(method string, u *url.URL, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req)
+	return s.client.Do(req)
+}
+
+// Put 上传对象
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = ContentType(key)
+	}
+	if size < 0 {
+		// S3要求提供内容长度
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	resp, err := s.do(http.MethodPut, s.objectURL(key), r, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+// Get 下载对象
+func (s *S3Storage) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.do(http.MethodGet, s.objectURL(key), nil, 0, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s3Error(resp); err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+	info := s3ObjectInfo(key, resp)
+	return &s3Object{s: s, key: key, size: info.Size, body: resp.Body}, info, nil
+}
+
+// getRange 从 offset 开始读取对象
+func (s *S3Storage) getRange(key string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	s.sign(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s3Error(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent && offset > 0 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3不支持Range请求(%d)", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// s3Object 可Seek的对象读取器，Seek到其他位置后下次读取时用Range请求重新获取，
+// 使代理下载也能支持断点续传（http.ServeContent）
+type s3Object struct {
+	s       *S3Storage
+	key     string
+	size    int64
+	body    io.ReadCloser
+	bodyPos int64 // body 的当前读取位置
+	pos     int64 // 调用方的读取位置
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil || o.bodyPos != o.pos {
+		if o.body != nil {
+			o.body.Close()
+			o.body = nil
+		}
+		body, err := o.s.getRange(o.key, o.pos)
+		if err != nil {
+			return 0, err
+		}
+		o.body, o.bodyPos = body, o.pos
+	}
+
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	o.bodyPos += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = o.pos + offset
+	case io.SeekEnd:
+		pos = o.size + offset
+	default:
+		return 0, errors.New("无效的whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("无效的偏移量")
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	return o.body.Close()
+}
+
+// Stat 获取对象信息
+func (s *S3Storage) Stat(key string) (*ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(http.MethodHead, s.objectURL(key), nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if err := s3Error(resp); err != nil {
+		return nil, err
+	}
+	return s3ObjectInfo(key, resp), nil
+}
+
+// Delete 删除对象（S3删除不存在的对象同样返回成功）
+func (s *S3Storage) Delete(key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodDelete, s.objectURL(key), nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := s3Error(resp); err != nil && err != ErrNotExist {
+		return err
+	}
+	return nil
+}
+
+// s3ListResult ListObjectsV2 的响应
+type s3ListResult struct {
+	Contents []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List 列出对象，自动处理分页
+func (s *S3Storage) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+	for {
+		u := s.objectURL("")
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.opts.Prefix+prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = s3CanonicalQuery(query)
+
+		resp, err := s.do(http.MethodGet, u, nil, 0, "")
+		if err != nil {
+			return nil, err
+		}
+		if err := s3Error(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析S3列表失败: %v", err)
+		}
+
+		for _, item := range result.Contents {
+			modTime, _ := time.Parse(time.RFC3339, item.LastModified)
+			key := strings.TrimPrefix(item.Key, s.opts.Prefix)
+			objects = append(objects, ObjectInfo{
+				Key:         key,
+				Size:        item.Size,
+				ModTime:     modTime,
+				ContentType: ContentType(key),
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// URL 开启预签名时返回预签名下载地址
+func (s *S3Storage) URL(key string) (string, error) {
+	if !s.opts.Presign {
+		return "", nil
+	}
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.presign(http.MethodGet, s.objectURL(key), s.opts.PresignExpiry), nil
+}
+
+// sign 为请求添加签名V4的认证头
+func (s *S3Storage) sign(req *http.Request) {
+	now := s.now().UTC()
+	amzDate := now.Format(s3TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": s3UnsignedPayload,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+
+	scope := s.scope(now)
+	signature := s.signature(now, scope, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.opts.AccessKey, scope, signedHeaders, signature))
+}
+
+// presign 生成预签名地址
+func (s *S3Storage) presign(method string, u *url.URL, expiry time.Duration) string {
+	now := s.now().UTC()
+	scope := s.scope(now)
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.opts.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		s3CanonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s.signature(now, scope, canonicalRequest))
+	signed := *u
+	signed.RawQuery = s3CanonicalQuery(query)
+	return signed.String()
+}
+
+// scope 签名范围：日期/区域/s3/aws4_request
+func (s *S3Storage) scope(now time.Time) string {
+	return now.Format(s3DateFormat) + "/" + s.opts.Region + "/s3/aws4_request"
+}
+
+// signature 计算签名
+func (s *S3Storage) signature(now time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		now.Format(s3TimeFormat),
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := s3HMAC([]byte("AWS4"+s.opts.SecretKey), now.Format(s3DateFormat))
+	key = s3HMAC(key, s.opts.Region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	return hex.EncodeToString(s3HMAC(key, stringToSign))
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape 按AWS规则编码：只保留 A-Z a-z 0-9 - _ . ~
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3EncodePath 编码请求路径
+func s3EncodePath(p string) string {
+	return s3Escape(p, true)
+}
+
+// s3CanonicalQuery 规范化查询字符串（按键名排序，请求地址和签名使用同一编码）
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3ObjectInfo 从响应头读取对象信息
+func s3ObjectInfo(key string, resp *http.Response) *ObjectInfo {
+	info := &ObjectInfo{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	if info.ContentType == "" {
+		info.ContentType = ContentType(key)
+	}
+	return info
+}
+
+// s3Error 将错误响应转换为error
+func s3Error(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotExist
+	}
+
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 4096)); err == nil {
+		xml.Unmarshal(data, &body)
+	}
+	if body.Code != "" {
+		return fmt.Errorf("S3请求失败(%d): %s %s", resp.StatusCode, body.Code, body.Message)
+	}
+	return fmt.Errorf("S3请求失败(%d)", resp.StatusCode)
+}
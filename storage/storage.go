@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"nav-admin/config"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotExist 对象不存在
+var ErrNotExist = errors.New("文件不存在")
+
+// ErrInvalidKey 对象键不合法（为空、绝对路径或包含..）
+var ErrInvalidKey = errors.New("无效的文件路径")
+
+// ObjectInfo 对象信息
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// Storage 上传文件存储接口
+// 对象键为相对上传目录的路径，如 logos/<hash>.png，对外访问路径为 /uploads/<键>
+type Storage interface {
+	// Put 写入对象，已存在时覆盖
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，不存在时返回 ErrNotExist
+	Get(key string) (io.ReadCloser, *ObjectInfo, error)
+	// Stat 获取对象信息，不存在时返回 ErrNotExist
+	Stat(key string) (*ObjectInfo, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(key string) error
+	// List 列出键以 prefix 开头的所有对象（包含子目录）
+	List(prefix string) ([]ObjectInfo, error)
+	// URL 返回可直接访问对象的临时地址（如S3预签名地址），返回空字符串表示需要由服务端代理
+	URL(key string) (string, error)
+}
+
+// Default 当前使用的存储，由 Init 根据配置创建
+var Default Storage
+
+// Init 根据配置初始化存储
+func Init() error {
+	cfg := config.AppConfig.Storage
+	switch cfg.Driver {
+	case "", "local":
+		Default = NewLocalStorage(config.AppConfig.Upload.Path)
+		log.Printf("上传文件存储: 本地目录 %s", config.AppConfig.Upload.Path)
+	case "s3":
+		s3, err := NewS3Storage(S3Options{
+			Endpoint:      cfg.S3Endpoint,
+			Region:        cfg.S3Region,
+			Bucket:        cfg.S3Bucket,
+			AccessKey:     cfg.S3AccessKey,
+			SecretKey:     cfg.S3SecretKey,
+			Prefix:        cfg.S3Prefix,
+			PathStyle:     cfg.S3PathStyle,
+			Presign:       cfg.S3Presign,
+			PresignExpiry: cfg.S3PresignExpiry,
+		})
+		if err != nil {
+			return err
+		}
+		Default = s3
+		log.Printf("上传文件存储: S3 %s/%s", cfg.S3Endpoint, cfg.S3Bucket)
+	default:
+		return fmt.Errorf("不支持的存储类型: %s", cfg.Driver)
+	}
+	return nil
+}
+
+// CleanKey 检查并规范化对象键，拒绝路径穿越
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || strings.Contains(key, "\\") || strings.Contains(key, "\x00") {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	return path.Clean(key), nil
+}
+
+// KeyFromURL 将访问路径（/uploads/logos/a.png 或 ./uploads/logos/a.png）转换为对象键
+func KeyFromURL(accessPath string) (string, error) {
+	accessPath = strings.TrimPrefix(accessPath, ".")
+	if !strings.HasPrefix(accessPath, "/uploads/") {
+		return "", ErrInvalidKey
+	}
+	return CleanKey(strings.TrimPrefix(accessPath, "/uploads/"))
+}
+
+// URLFromKey 返回对象键对应的访问路径
+func URLFromKey(key string) string {
+	return "/uploads/" + key
+}
+
+// ContentType 根据扩展名判断内容类型
+func ContentType(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"nav-admin/config"
+	"nav-admin/models"
+	"nav-admin/storage"
+	"os"
+	"sort"
+	"time"
+)
+
+// BackupVersion 备份格式版本，备份内容或结构变化时递增
+// 1: nav.json + 上传文件；2: nav.json 增加页面配置，新增 uploads.json（上传记录）和可选的 users.json
+const BackupVersion = 2
+
+// BackupManifestName 备份清单在zip中的文件名
+const BackupManifestName = "manifest.json"
+
+// 备份内容分类，记录在清单的 contents 中，导入时按分类选择恢复
+const (
+	BackupContentData     = "data"     // 分类、站点、公告、上传文件及其记录
+	BackupContentSettings = "settings" // 页面配置、公告轮播间隔
+	BackupContentUsers    = "users"    // 管理员账号（密码为bcrypt哈希）
+)
+
+// 备份中由程序生成的文件
+const (
+	BackupNavFile      = "nav.json"
+	BackupUploadsFile  = "uploads.json"
+	BackupUsersFile    = "users.json"
+	BackupDatabaseFile = "database.db" // 数据库快照，仅定时备份包含
+)
+
+// BackupEntry 写入备份的数据文件（上传文件之外），File 不为空时从该本地文件读取内容
+type BackupEntry struct {
+	Name string
+	Data []byte
+	File string
+}
+
+// BackupUser 备份中的用户
+type BackupUser struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// BackupManifest 备份清单，写在zip的最后（写完所有文件后才能得到校验和）
+type BackupManifest struct {
+	Format        string               `json:"format"` // 固定为 nav-admin-backup
+	BackupVersion int                  `json:"backup_version"`
+	AppVersion    string               `json:"app_version"`
+	SchemaVersion int                  `json:"schema_version"`
+	CreatedAt     string               `json:"created_at"`
+	Contents      []string             `json:"contents"`
+	Files         []BackupManifestFile `json:"files"`
+}
+
+// BackupManifestFile 备份中的文件及其SHA-256
+type BackupManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// CollectBackup 读取需要备份的数据库内容：nav.json（含设置）、uploads.json，includeUsers 为 true 时包含 users.json
+func CollectBackup(db *sql.DB, includeUsers bool) ([]BackupEntry, []string, error) {
+	navData, err := BuildNavExport(db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取导航数据失败: %w", err)
+	}
+	navJSON, err := json.MarshalIndent(navData, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uploads, err := models.GetAllUploads(db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取上传记录失败: %w", err)
+	}
+	uploadList := make([]*models.Upload, 0, len(uploads))
+	for _, upload := range uploads {
+		uploadList = append(uploadList, upload)
+	}
+	sort.Slice(uploadList, func(i, j int) bool { return uploadList[i].Path < uploadList[j].Path })
+	uploadsJSON, err := json.MarshalIndent(uploadList, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := []BackupEntry{{Name: BackupNavFile, Data: navJSON}, {Name: BackupUploadsFile, Data: uploadsJSON}}
+	contents := []string{BackupContentData, BackupContentSettings}
+
+	if includeUsers {
+		users, err := models.GetAllUsers(db)
+		if err != nil {
+			return nil, nil, fmt.Errorf("获取用户失败: %w", err)
+		}
+		backupUsers := make([]BackupUser, 0, len(users))
+		for _, user := range users {
+			backupUsers = append(backupUsers, BackupUser{
+				Username:     user.Username,
+				PasswordHash: user.Password,
+				CreatedAt:    user.CreatedAt,
+				UpdatedAt:    user.UpdatedAt,
+			})
+		}
+		usersJSON, err := json.MarshalIndent(backupUsers, "", "  ")
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, BackupEntry{Name: BackupUsersFile, Data: usersJSON})
+		contents = append(contents, BackupContentUsers)
+	}
+
+	return entries, contents, nil
+}
+
+// WriteBackup 将备份以zip格式流式写入 w：数据文件、存储中的所有上传文件，最后写入清单
+// 任何文件读取或写入失败都会返回错误（已写入的内容不完整，调用方需丢弃）
+func WriteBackup(w io.Writer, entries []BackupEntry, contents []string) (*BackupManifest, error) {
+	// 先列出文件，列出失败时还没有写入任何内容
+	objects, err := storage.Default.List("")
+	if err != nil {
+		return nil, fmt.Errorf("列出上传文件失败: %w", err)
+	}
+
+	now := time.Now()
+	manifest := &BackupManifest{
+		Format:        "nav-admin-backup",
+		BackupVersion: BackupVersion,
+		AppVersion:    config.Version,
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     now.Format("2006-01-02 15:04:05"),
+		Contents:      contents,
+		Files:         []BackupManifestFile{},
+	}
+
+	zipWriter := zip.NewWriter(w)
+
+	for _, data := range entries {
+		data := data
+		entry, err := writeBackupEntry(zipWriter, data.Name, now, func(dst io.Writer) error {
+			if data.File == "" {
+				_, err := dst.Write(data.Data)
+				return err
+			}
+			f, err := os.Open(data.File)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(dst, f)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, *entry)
+	}
+
+	for _, object := range objects {
+		rc, _, err := storage.Default.Get(object.Key)
+		if err == storage.ErrNotExist {
+			// 列出后被删除（如同时在清理未引用文件），不属于备份错误
+			log.Printf("备份时文件已被删除，跳过: %s", object.Key)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", object.Key, err)
+		}
+
+		entry, err := writeBackupEntry(zipWriter, "uploads/"+object.Key, object.ModTime, func(dst io.Writer) error {
+			_, err := io.Copy(dst, rc)
+			return err
+		})
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, *entry)
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writeBackupEntry(zipWriter, BackupManifestName, now, func(dst io.Writer) error {
+		_, err := dst.Write(manifestJSON)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("写入zip目录失败: %w", err)
+	}
+	return manifest, nil
+}
+
+// writeBackupEntry 在zip中写入一个文件，同时计算大小和SHA-256
+func writeBackupEntry(zipWriter *zip.Writer, name string, modTime time.Time, write func(io.Writer) error) (*BackupManifestFile, error) {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	if !modTime.IsZero() {
+		header.Modified = modTime
+	}
+	dst, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(dst, hash)}
+	if err := write(counter); err != nil {
+		return nil, fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	return &BackupManifestFile{Path: name, Size: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// VerifyBackupFiles 按清单校验zip中文件的SHA-256并返回清单，没有清单的旧版本备份不校验（返回nil）
+// 有清单时zip中除清单和目录外的每个文件都必须列在清单中，且不能有同名文件，避免导入未经校验的文件
+func VerifyBackupFiles(zipReader *zip.Reader) (*BackupManifest, error) {
+	var manifestFile *zip.File
+	files := make(map[string]*zip.File, len(zipReader.File))
+	var duplicate string
+	for _, f := range zipReader.File {
+		if _, ok := files[f.Name]; ok && duplicate == "" {
+			duplicate = f.Name
+		}
+		files[f.Name] = f
+		if f.Name == BackupManifestName {
+			manifestFile = f
+		}
+	}
+	if manifestFile == nil {
+		return nil, nil
+	}
+
+	rc, err := manifestFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取备份清单失败: %w", err)
+	}
+	var manifest BackupManifest
+	err = json.NewDecoder(io.LimitReader(rc, 10*1024*1024)).Decode(&manifest)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("备份清单格式无效: %w", err)
+	}
+	if manifest.BackupVersion > BackupVersion {
+		return nil, fmt.Errorf("备份格式版本 %d 高于当前程序支持的版本 %d，请升级程序后再导入", manifest.BackupVersion, BackupVersion)
+	}
+	if duplicate != "" {
+		return nil, fmt.Errorf("备份中有重复的文件: %s", duplicate)
+	}
+
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		listed[entry.Path] = true
+	}
+	for _, f := range zipReader.File {
+		if f.Name != BackupManifestName && !listed[f.Name] && !f.FileInfo().IsDir() {
+			return nil, fmt.Errorf("备份中有清单未列出的文件: %s", f.Name)
+		}
+	}
+
+	for _, entry := range manifest.Files {
+		f, ok := files[entry.Path]
+		if !ok {
+			return nil, fmt.Errorf("备份不完整，缺少文件: %s", entry.Path)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", entry.Path, err)
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", entry.Path, err)
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != entry.SHA256 {
+			return nil, fmt.Errorf("文件校验失败（SHA-256不一致）: %s", entry.Path)
+		}
+	}
+	return &manifest, nil
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// repackBackup 复制备份中除 skip 外的文件，再用 extra 追加文件
+func repackBackup(t *testing.T, data []byte, skip string, extra func(w *zip.Writer)) *zip.Reader {
+	t.Helper()
+	src, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range src.File {
+		if f.Name == skip {
+			continue
+		}
+		if err := w.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if extra != nil {
+		extra(w)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func addZipFile(t *testing.T, w *zip.Writer, name, content string) {
+	t.Helper()
+	dst, err := w.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyBackupFiles(t *testing.T) {
+	db := newTestDB(t)
+	putTestUpload(t, db, "files/a.txt", 0)
+
+	var buf bytes.Buffer
+	if _, err := WriteBackup(&buf, []BackupEntry{{Name: "nav.json", Data: []byte("[]")}}, []string{"nav"}); err != nil {
+		t.Fatal(err)
+	}
+	backup := buf.Bytes()
+
+	tests := []struct {
+		name  string
+		skip  string
+		extra func(w *zip.Writer)
+		err   string // 为空表示校验通过
+	}{
+		{name: "valid"},
+		{
+			name:  "directory entry",
+			extra: func(w *zip.Writer) { addZipFile(t, w, "uploads/files/", "") },
+		},
+		{
+			name:  "file not in manifest",
+			extra: func(w *zip.Writer) { addZipFile(t, w, "uploads/files/evil.svg", "<svg onload=alert(1)>") },
+			err:   "清单未列出的文件: uploads/files/evil.svg",
+		},
+		{
+			name:  "data file not in manifest",
+			extra: func(w *zip.Writer) { addZipFile(t, w, "users.json", "[]") },
+			err:   "清单未列出的文件: users.json",
+		},
+		{
+			name:  "duplicate entry",
+			extra: func(w *zip.Writer) { addZipFile(t, w, "uploads/files/a.txt", "other content") },
+			err:   "重复的文件: uploads/files/a.txt",
+		},
+		{
+			name: "missing file",
+			skip: "uploads/files/a.txt",
+			err:  "缺少文件: uploads/files/a.txt",
+		},
+		{
+			name: "modified file",
+			skip: "nav.json",
+			extra: func(w *zip.Writer) {
+				addZipFile(t, w, "nav.json", `[{"_id": "x"}]`)
+			},
+			err: "SHA-256不一致）: nav.json",
+		},
+	}
+	for _, tt := range tests {
+		manifest, err := VerifyBackupFiles(repackBackup(t, backup, tt.skip, tt.extra))
+		if tt.err == "" {
+			if err != nil || manifest == nil || len(manifest.Files) != 2 {
+				t.Errorf("%s: manifest = %+v, err = %v", tt.name, manifest, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	// 没有清单的旧版本备份不校验
+	manifest, err := VerifyBackupFiles(repackBackup(t, backup, BackupManifestName, func(w *zip.Writer) {
+		addZipFile(t, w, "uploads/files/b.txt", "b")
+	}))
+	if manifest != nil || err != nil {
+		t.Errorf("backup without manifest: %+v, %v", manifest, err)
+	}
+}
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// Bookmark 浏览器书签
+type Bookmark struct {
+	Title       string
+	Href        string
+	Icon        string // ICON 属性，通常是 data: URI
+	Description string // 书签后的 <DD> 内容
+}
+
+// BookmarkFolder 书签文件夹，Path 为从根开始的各级文件夹名称（根目录下的书签 Path 为空）
+type BookmarkFolder struct {
+	Path      []string
+	Bookmarks []Bookmark
+}
+
+// ErrNoBookmarks 文件中没有找到书签
+var ErrNoBookmarks = errors.New("文件中没有找到书签，请使用浏览器导出的HTML书签文件")
+
+// ParseNetscapeBookmarks 解析 Netscape 格式的书签文件（Chrome、Firefox、Edge、Safari 导出的 bookmarks.html）
+// 按文件夹分组返回书签（文件夹按在文件中出现的顺序，不包含书签的文件夹不返回）
+func ParseNetscapeBookmarks(r io.Reader) ([]BookmarkFolder, error) {
+	tokenizer := xhtml.NewTokenizer(r)
+
+	var (
+		stack   []string           // 当前所在的各级文件夹（<DL>），根目录为空字符串
+		pending *string            // 刚读到的 <H3> 文件夹名，下一个 <DL> 属于它
+		folders = map[string]int{} // 文件夹路径 -> 在结果中的位置
+		result  []BookmarkFolder
+		text    *strings.Builder // 正在读取的 <H3> 或 <A> 的文本
+		current *Bookmark        // 正在读取的 <A>
+		desc    *Bookmark        // 最近读到的书签，之后的 <DD> 文本是它的描述
+		inH3    bool
+	)
+
+	addBookmark := func(b Bookmark) {
+		var path []string
+		for _, name := range stack {
+			if name != "" {
+				path = append(path, name)
+			}
+		}
+		key := strings.Join(path, "\x00")
+		i, ok := folders[key]
+		if !ok {
+			i = len(result)
+			folders[key] = i
+			result = append(result, BookmarkFolder{Path: path})
+		}
+		result[i].Bookmarks = append(result[i].Bookmarks, b)
+		desc = &result[i].Bookmarks[len(result[i].Bookmarks)-1]
+	}
+
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case xhtml.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, err
+			}
+			if len(result) == 0 {
+				return nil, ErrNoBookmarks
+			}
+			return result, nil
+
+		case xhtml.TextToken:
+			switch {
+			case text != nil:
+				text.Write(tokenizer.Text())
+			case desc != nil:
+				desc.Description += string(tokenizer.Text())
+			}
+
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			tag := string(name)
+			if tag != "p" && tag != "dd" {
+				// 紧跟书签的 <DD> 是它的描述，到下一个标签为止（<p> 是文件中常见的无意义标签）
+				desc = nil
+			}
+			switch tag {
+			case "h3":
+				inH3 = true
+				text = &strings.Builder{}
+			case "a":
+				current = &Bookmark{}
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					switch string(key) {
+					case "href":
+						current.Href = string(val)
+					case "icon":
+						current.Icon = string(val)
+					}
+				}
+				text = &strings.Builder{}
+			case "dl":
+				folder := ""
+				if pending != nil {
+					folder = *pending
+					pending = nil
+				}
+				stack = append(stack, folder)
+			}
+
+		case xhtml.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "h3":
+				if inH3 && text != nil {
+					folder := strings.TrimSpace(text.String())
+					pending = &folder
+				}
+				inH3 = false
+				text = nil
+			case "a":
+				if current != nil && text != nil {
+					current.Title = strings.TrimSpace(text.String())
+					addBookmark(*current)
+				}
+				current = nil
+				text = nil
+			case "dl":
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+				desc = nil
+			}
+		}
+	}
+}
+
+// dataURIImageExts 书签图标 data: URI 中允许的图片类型
+var dataURIImageExts = map[string]string{
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"image/svg+xml":            ".svg",
+}
+
+// DecodeImageDataURI 解码图片 data: URI（如 data:image/png;base64,...），返回对应的扩展名和内容
+// maxSize 为解码后的大小限制
+func DecodeImageDataURI(uri string, maxSize int64) (string, []byte, error) {
+	if !strings.HasPrefix(uri, "data:") {
+		return "", nil, errors.New("不是 data: URI")
+	}
+	meta, payload, ok := strings.Cut(uri[len("data:"):], ",")
+	if !ok {
+		return "", nil, errors.New("data: URI 格式错误")
+	}
+
+	params := strings.Split(meta, ";")
+	ext, ok := dataURIImageExts[strings.ToLower(strings.TrimSpace(params[0]))]
+	if !ok {
+		return "", nil, errors.New("不支持的图标类型: " + params[0])
+	}
+	isBase64 := false
+	for _, p := range params[1:] {
+		if strings.EqualFold(strings.TrimSpace(p), "base64") {
+			isBase64 = true
+		}
+	}
+
+	// 解码前按长度粗略检查大小，避免解码超大内容
+	if int64(len(payload)) > maxSize*4/3+4 {
+		return "", nil, errors.New("图标过大")
+	}
+
+	var data []byte
+	if isBase64 {
+		var err error
+		data, err = base64.StdEncoding.DecodeString(strings.TrimSpace(payload))
+		if err != nil {
+			return "", nil, errors.New("图标 base64 内容无效")
+		}
+	} else {
+		decoded, err := url.PathUnescape(payload)
+		if err != nil {
+			return "", nil, errors.New("图标内容无效")
+		}
+		data = []byte(decoded)
+	}
+	if int64(len(data)) > maxSize {
+		return "", nil, errors.New("图标过大")
+	}
+	if len(data) == 0 {
+		return "", nil, errors.New("图标内容为空")
+	}
+	return ext, data, nil
+}
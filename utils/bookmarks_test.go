@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testBookmarkFolder 期望的文件夹："路径" 和书签（"标题 链接"）
+type testBookmarkFolder struct {
+	path      string
+	bookmarks []string
+}
+
+func TestParseNetscapeBookmarks(t *testing.T) {
+	// testdata 中是 Chrome 和 Firefox 导出文件的片段，包含未闭合的 <DT>、<p>，嵌套文件夹和图标
+	tests := map[string][]testBookmarkFolder{
+		"bookmarks_chrome.html": {
+			{"Bookmarks bar", []string{"The Go Programming Language https://go.dev/"}},
+			{"Bookmarks bar/Dev", []string{"GitHub https://github.com/", "Go Packages https://pkg.go.dev/search?q=html&m=package"}},
+			{"", []string{"Hacker News https://news.ycombinator.com/"}},
+		},
+		"bookmarks_firefox.html": {
+			{"", []string{"Getting Started https://www.mozilla.org/en-US/firefox/central/"}},
+			{"Mozilla Firefox", []string{"Get Help https://support.mozilla.org/en-US/products/firefox", "About Us https://www.mozilla.org/en-US/about/"}},
+			{"Bookmarks Toolbar", []string{"Docs https://docs.example.com/"}},
+			{"Bookmarks Toolbar/Nested", []string{"Deep & nested https://deep.example.com/"}},
+		},
+	}
+	parsed := map[string][]BookmarkFolder{}
+	for name, want := range tests {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		folders, err := ParseNetscapeBookmarks(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		parsed[name] = folders
+		if len(folders) != len(want) {
+			t.Errorf("%s: %d folders, want %d", name, len(folders), len(want))
+			continue
+		}
+		for i, w := range want {
+			folder := folders[i]
+			if strings.Join(folder.Path, "/") != w.path || (w.path == "" && folder.Path != nil) {
+				t.Errorf("%s: folder %d path = %q, want %q", name, i, folder.Path, w.path)
+			}
+			var bookmarks []string
+			for _, b := range folder.Bookmarks {
+				bookmarks = append(bookmarks, b.Title+" "+b.Href)
+			}
+			if strings.Join(bookmarks, "\n") != strings.Join(w.bookmarks, "\n") {
+				t.Errorf("%s: folder %q bookmarks:\n%s\nwant:\n%s", name, w.path, strings.Join(bookmarks, "\n"), strings.Join(w.bookmarks, "\n"))
+			}
+		}
+	}
+
+	// 图标和描述
+	if folders := parsed["bookmarks_chrome.html"]; len(folders) == 3 {
+		if ext, _, err := DecodeImageDataURI(folders[0].Bookmarks[0].Icon, 1024); ext != ".png" || err != nil {
+			t.Errorf("chrome icon: %q, %v", ext, err)
+		}
+		if icon := folders[1].Bookmarks[0].Icon; icon != "" {
+			t.Errorf("bookmark without ICON has icon %q", icon)
+		}
+	}
+	if folders := parsed["bookmarks_firefox.html"]; len(folders) == 4 {
+		if ext, _, err := DecodeImageDataURI(folders[3].Bookmarks[0].Icon, 1024); ext != ".svg" || err != nil {
+			t.Errorf("firefox svg icon: %q, %v", ext, err)
+		}
+		if desc := strings.TrimSpace(folders[2].Bookmarks[0].Description); desc != "Reference & guides" {
+			t.Errorf("description = %q", desc)
+		}
+		if desc := folders[1].Bookmarks[0].Description; strings.TrimSpace(desc) != "" {
+			t.Errorf("bookmark without <DD> has description %q", desc)
+		}
+	}
+
+	// 不在任何 <DL> 中的书签也属于根目录
+	folders, err := ParseNetscapeBookmarks(strings.NewReader(`<DT><A HREF="https://a.example.com/">A</A>`))
+	if err != nil || len(folders) != 1 || folders[0].Path != nil || folders[0].Bookmarks[0].Href != "https://a.example.com/" {
+		t.Errorf("bookmark outside any folder: %+v, %v", folders, err)
+	}
+
+	for _, input := range []string{
+		``,
+		`<html><body><p>not bookmarks</p></body></html>`,
+		"<!DOCTYPE NETSCAPE-Bookmark-file-1>\n<DL><p>\n    <DT><H3>Empty</H3>\n    <DL><p>\n    </DL><p>\n</DL><p>\n",
+	} {
+		if _, err := ParseNetscapeBookmarks(strings.NewReader(input)); !errors.Is(err, ErrNoBookmarks) {
+			t.Errorf("ParseNetscapeBookmarks(%q) error = %v, want ErrNoBookmarks", input, err)
+		}
+	}
+}
+
+func TestDecodeImageDataURI(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	pngURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+
+	tests := []struct {
+		name, uri, ext, data string
+	}{
+		{"png", pngURI, ".png", string(png)},
+		{"upper case type", "data:IMAGE/JPEG;BASE64," + base64.StdEncoding.EncodeToString([]byte("jpg")), ".jpg", "jpg"},
+		{"ico", "data:image/vnd.microsoft.icon;base64,AAABAA==", ".ico", "\x00\x00\x01\x00"},
+		{"percent encoded svg", "data:image/svg+xml,%3Csvg%2F%3E", ".svg", "<svg/>"},
+	}
+	for _, tt := range tests {
+		ext, data, err := DecodeImageDataURI(tt.uri, 1024)
+		if err != nil || ext != tt.ext || string(data) != tt.data {
+			t.Errorf("%s: DecodeImageDataURI = %q, %q, %v; want %q, %q", tt.name, ext, data, err, tt.ext, tt.data)
+		}
+	}
+
+	errorTests := []struct {
+		name, uri string
+		maxSize   int64
+		err       string
+	}{
+		{"not data uri", "https://example.com/favicon.ico", 1024, "不是 data: URI"},
+		{"no comma", "data:image/png;base64", 1024, "格式错误"},
+		{"html", "data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==", 1024, "不支持的图标类型: text/html"},
+		{"no type", "data:;base64,AAAA", 1024, "不支持的图标类型"},
+		{"too large before decoding", "data:image/png;base64," + strings.Repeat("A", 400), 100, "图标过大"},
+		{"too large after decoding", pngURI, int64(len(png)) - 1, "图标过大"},
+		{"too large percent encoded", "data:image/svg+xml," + strings.Repeat("a", 11), 10, "图标过大"},
+		{"bad base64", "data:image/png;base64,!!!!", 1024, "base64 内容无效"},
+		{"bad percent encoding", "data:image/svg+xml,%zz", 1024, "图标内容无效"},
+		{"empty", "data:image/png;base64,", 1024, "图标内容为空"},
+	}
+	for _, tt := range errorTests {
+		if _, _, err := DecodeImageDataURI(tt.uri, tt.maxSize); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"nav-admin/config"
+	"nav-admin/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrChunkSize 分片大小与会话约定不一致
+var ErrChunkSize = errors.New("分片大小不正确")
+
+// ErrChunkChecksum 分片内容与客户端提供的SHA-256不一致
+var ErrChunkChecksum = errors.New("分片校验失败，请重新上传该分片")
+
+// chunkSuffix 分片文件后缀，分片保存为 <序号>.part
+const chunkSuffix = ".part"
+
+// NewUploadSessionID 生成分片上传会话ID（32位十六进制）
+func NewUploadSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// IsUploadSessionID 检查会话ID格式，防止拼接临时目录时路径穿越
+func IsUploadSessionID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// chunkDir 返回会话的分片临时目录
+func chunkDir(id string) string {
+	return filepath.Join(config.AppConfig.Upload.ChunkPath, id)
+}
+
+// SaveChunk 保存一个分片，长度必须为 length，checksum 不为空时校验SHA-256
+// 先写临时文件再重命名，重复上传同一分片会覆盖之前的内容
+func SaveChunk(id string, index int, r io.Reader, length int64, checksum string) error {
+	dir := chunkDir(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".chunk-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, length+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n != length {
+		return ErrChunkSize
+	}
+	if checksum != "" && !strings.EqualFold(checksum, hex.EncodeToString(hash.Sum(nil))) {
+		return ErrChunkChecksum
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, strconv.Itoa(index)+chunkSuffix))
+}
+
+// ReceivedChunks 返回已收到的分片序号（升序）
+func ReceivedChunks(id string) ([]int, error) {
+	entries, err := os.ReadDir(chunkDir(id))
+	if os.IsNotExist(err) {
+		return []int{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := []int{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, chunkSuffix) {
+			continue
+		}
+		if index, err := strconv.Atoi(strings.TrimSuffix(name, chunkSuffix)); err == nil {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// AssembleChunks 按顺序合并所有分片，返回合并后的临时文件路径和SHA-256
+// 合并后的文件位于分片目录中，随 RemoveChunks 一起删除
+func AssembleChunks(session *models.UploadSession) (string, string, error) {
+	dir := chunkDir(session.ID)
+	assembled := filepath.Join(dir, "assembled")
+
+	out, err := os.Create(assembled)
+	if err != nil {
+		return "", "", err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	w := io.MultiWriter(out, hash)
+	for i := 0; i < session.TotalChunks(); i++ {
+		if err := appendChunk(w, filepath.Join(dir, strconv.Itoa(i)+chunkSuffix), session.ChunkLength(i)); err != nil {
+			os.Remove(assembled)
+			return "", "", fmt.Errorf("分片 %d: %w", i, err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(assembled)
+		return "", "", err
+	}
+	return assembled, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// appendChunk 将分片内容追加到 w，并检查分片大小
+func appendChunk(w io.Writer, chunkPath string, length int64) error {
+	f, err := os.Open(chunkPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(w, f)
+	if err != nil {
+		return err
+	}
+	if n != length {
+		return ErrChunkSize
+	}
+	return nil
+}
+
+// RemoveChunks 删除会话的分片临时目录
+func RemoveChunks(id string) error {
+	return os.RemoveAll(chunkDir(id))
+}
+
+// CleanupAbandonedUploads 清理超过 UPLOAD_CHUNK_EXPIRY 未更新的分片上传会话及其临时文件，
+// 以及没有对应会话的临时目录，返回清理的会话数。
+// 正在合并分片的会话不清理；UPLOAD_CHUNK_EXPIRY 不大于0时不清理任何会话
+func CleanupAbandonedUploads(db *sql.DB) (int, error) {
+	expiry := config.AppConfig.Upload.ChunkExpiry
+	if expiry <= 0 {
+		return 0, nil
+	}
+	before := time.Now().Add(-expiry)
+
+	ids, err := models.GetExpiredUploadSessionIDs(db, before)
+	if err != nil {
+		return 0, err
+	}
+
+	cleaned := 0
+	for _, id := range ids {
+		tx, err := db.Begin()
+		if err != nil {
+			return cleaned, err
+		}
+		if err := models.DeleteUploadSession(tx, id); err != nil {
+			tx.Rollback()
+			return cleaned, err
+		}
+		if err := tx.Commit(); err != nil {
+			return cleaned, err
+		}
+		if IsUploadSessionID(id) {
+			RemoveChunks(id)
+		}
+		cleaned++
+	}
+
+	// 会话记录已删除但临时目录残留（如合并过程中服务重启）
+	entries, err := os.ReadDir(config.AppConfig.Upload.ChunkPath)
+	if err != nil {
+		return cleaned, nil
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !IsUploadSessionID(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(before) {
+			continue
+		}
+		if _, err := models.GetUploadSession(db, entry.Name()); err == sql.ErrNoRows {
+			RemoveChunks(entry.Name())
+		}
+	}
+
+	return cleaned, nil
+}
+
+// StartChunkCleanup 启动已放弃分片上传的定时清理（启动时执行一次，之后每小时执行）
+// 启动时先恢复上次服务中断时正在合并的会话；UPLOAD_CHUNK_EXPIRY 不大于0时不启动清理
+func StartChunkCleanup(db *sql.DB) {
+	if n, err := models.ResetCompletingUploadSessions(db); err != nil {
+		log.Printf("恢复分片上传会话状态失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已恢复 %d 个合并中断的分片上传", n)
+	}
+
+	expiry := config.AppConfig.Upload.ChunkExpiry
+	if expiry <= 0 {
+		log.Println("UPLOAD_CHUNK_EXPIRY 不大于0，不清理未完成的分片上传")
+		return
+	}
+	interval := time.Hour
+	if expiry < interval {
+		interval = expiry
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if cleaned, err := CleanupAbandonedUploads(db); err != nil {
+				log.Printf("清理分片上传临时文件失败: %v", err)
+			} else if cleaned > 0 {
+				log.Printf("已清理 %d 个未完成的分片上传", cleaned)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"nav-admin/config"
+	"nav-admin/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanupAbandonedUploads(t *testing.T) {
+	db := newTestDB(t)
+	config.AppConfig.Upload.ChunkPath = filepath.Join(t.TempDir(), "chunks")
+
+	old := time.Now().Add(-2 * time.Hour)
+	sessions := []struct {
+		id, status string
+		updated    time.Time
+	}{
+		{strings.Repeat("a", 32), models.UploadSessionPending, old},
+		{strings.Repeat("b", 32), models.UploadSessionCompleting, old},
+		{strings.Repeat("c", 32), models.UploadSessionPending, time.Now()},
+	}
+	for _, s := range sessions {
+		mustExec(t, db, "INSERT INTO upload_sessions (id, filename, size, chunk_size, status, updated_at) VALUES (?, 'a.zip', 10, 5, ?, ?)",
+			s.id, s.status, s.updated.UTC().Format("2006-01-02 15:04:05"))
+		writeTestFile(t, filepath.Join(config.AppConfig.Upload.ChunkPath, s.id, "0.part"))
+	}
+	// 没有会话的旧临时目录
+	orphan := filepath.Join(config.AppConfig.Upload.ChunkPath, strings.Repeat("d", 32))
+	writeTestFile(t, filepath.Join(orphan, "0.part"))
+	if err := os.Chtimes(orphan, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	remaining := func() []string {
+		entries, err := os.ReadDir(config.AppConfig.Upload.ChunkPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dirs []string
+		for _, e := range entries {
+			dirs = append(dirs, e.Name()[:1])
+		}
+		sort.Strings(dirs)
+		return dirs
+	}
+
+	// 不大于0时不清理
+	for _, expiry := range []time.Duration{0, -time.Hour} {
+		config.AppConfig.Upload.ChunkExpiry = expiry
+		if n, err := CleanupAbandonedUploads(db); err != nil || n != 0 {
+			t.Fatalf("expiry %s: cleaned %d, %v", expiry, n, err)
+		}
+		if got := strings.Join(remaining(), ""); got != "abcd" {
+			t.Fatalf("expiry %s: remaining %s, want abcd", expiry, got)
+		}
+	}
+
+	// 只清理过期的 pending 会话和没有会话的旧目录，合并中的会话保留
+	config.AppConfig.Upload.ChunkExpiry = time.Hour
+	if n, err := CleanupAbandonedUploads(db); err != nil || n != 1 {
+		t.Fatalf("cleaned %d, %v, want 1", n, err)
+	}
+	if got := strings.Join(remaining(), ""); got != "bc" {
+		t.Errorf("remaining %s, want bc", got)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM upload_sessions"); n != 2 {
+		t.Errorf("sessions = %d, want 2", n)
+	}
+
+	// 启动时合并中断的会话恢复为 pending，之后按更新时间重新计算
+	if n, err := models.ResetCompletingUploadSessions(db); err != nil || n != 1 {
+		t.Fatalf("reset %d, %v", n, err)
+	}
+	session, err := models.GetUploadSession(db, strings.Repeat("b", 32))
+	if err != nil || session.Status != models.UploadSessionPending {
+		t.Fatalf("session = %+v, %v", session, err)
+	}
+	if n, err := CleanupAbandonedUploads(db); err != nil || n != 0 {
+		t.Errorf("cleaned %d after reset, %v, want 0", n, err)
+	}
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 五段式cron表达式：分 时 日 月 周
+// 每段支持 *、数字、列表（1,15）、范围（1-5）和步长（*/10、0-30/5），周日为0或7
+// 日和周都有限制时，两者满足其一即可（与标准cron一致）；取值覆盖整个范围（*、*/1、1-31 等）的段视为没有限制
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// cronAliases 常用的预定义表达式
+var cronAliases = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// ParseCron 解析cron表达式
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if alias, ok := cronAliases[expr]; ok {
+		expr = alias
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron表达式需要5段（分 时 日 月 周）: %q", expr)
+	}
+
+	s := &CronSchedule{}
+	ranges := []struct {
+		dst      *uint64
+		min, max int
+		name     string
+	}{
+		{&s.minute, 0, 59, "分"},
+		{&s.hour, 0, 23, "时"},
+		{&s.dom, 1, 31, "日"},
+		{&s.month, 1, 12, "月"},
+		{&s.dow, 0, 7, "周"},
+	}
+	for i, r := range ranges {
+		bits, err := parseCronField(fields[i], r.min, r.max)
+		if err != nil {
+			return nil, fmt.Errorf("cron表达式的“%s”段无效: %v", r.name, err)
+		}
+		*r.dst = bits
+	}
+	// 周日可以写成7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	// 按取值集合而不是写法判断是否有限制，*/1 与 * 相同
+	s.domAny = s.dom == cronFieldBits(1, 31)
+	s.dowAny = s.dow&cronFieldBits(0, 6) == cronFieldBits(0, 6)
+	return s, nil
+}
+
+// cronFieldBits 返回 lo 到 hi 全部取值的位集合
+func cronFieldBits(lo, hi int) uint64 {
+	return (1<<uint(hi+1) - 1) &^ (1<<uint(lo) - 1)
+}
+
+// parseCronField 解析cron表达式中的一段，返回按位表示的取值集合
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("步长无效: %s", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || lo > hi {
+				return 0, fmt.Errorf("范围无效: %s", part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("数值无效: %s", part)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = max // 如 5/15 表示从5开始每15个
+			}
+		}
+		if lo < min || hi > max {
+			return 0, fmt.Errorf("超出范围 %d-%d: %s", min, max, part)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next 返回 t 之后（不含 t 所在的这一分钟）下一次触发的时间，使用 t 的时区
+// 表达式永远不会触发时（如 2月30日）返回零值
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// 最多向后查找5年（覆盖闰年2月29日）
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 检查日期是否满足“日”和“周”两段
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowMatch
+	case s.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// 2024-01-01 是周一
+	tests := []struct {
+		name, expr, from, want string
+	}{
+		{"every minute", "* * * * *", "2024-01-01 10:07", "2024-01-01 10:08"},
+		{"minute step", "*/15 * * * *", "2024-01-01 10:07", "2024-01-01 10:15"},
+		{"current minute excluded", "*/15 * * * *", "2024-01-01 10:15", "2024-01-01 10:30"},
+		{"step from value", "5/20 * * * *", "2024-01-01 10:45", "2024-01-01 11:05"},
+		{"range with step", "0 9-17/4 * * *", "2024-01-01 13:00", "2024-01-01 17:00"},
+		{"range with step wraps to next day", "0 9-17/4 * * *", "2024-01-01 17:00", "2024-01-02 09:00"},
+		{"hour range", "30 8 * * 1-5", "2024-01-05 09:00", "2024-01-08 08:30"},
+		{"list", "0 0 1,15 * *", "2024-01-02 00:00", "2024-01-15 00:00"},
+		{"list of ranges", "0 12 * * 1-2,5", "2024-01-02 12:00", "2024-01-05 12:00"},
+		{"sunday as 7", "0 0 * * 7", "2024-01-01 00:00", "2024-01-07 00:00"},
+		{"alias", "@daily", "2024-01-01 10:00", "2024-01-02 00:00"},
+
+		// 日和周都有限制时满足其一即可
+		{"dom or dow: dow first", "0 0 13 * 5", "2024-01-01 00:00", "2024-01-05 00:00"},
+		{"dom or dow: dom first", "0 0 13 * 5", "2024-01-12 00:00", "2024-01-13 00:00"},
+		// 取值覆盖整个范围的段与 * 相同，只按另一段匹配
+		{"dom */1 is unrestricted", "0 0 */1 * 5", "2024-01-01 00:00", "2024-01-05 00:00"},
+		{"dom 1-31 is unrestricted", "0 0 1-31 * 5", "2024-01-05 00:00", "2024-01-12 00:00"},
+		{"dow */1 is unrestricted", "0 0 13 * */1", "2024-01-01 00:00", "2024-01-13 00:00"},
+		{"dow 0-7 is unrestricted", "0 0 13 * 0-7", "2024-01-13 00:00", "2024-02-13 00:00"},
+		{"dom step is restricted", "0 0 */10 * 0", "2024-01-02 00:00", "2024-01-07 00:00"},
+
+		// 跨月、跨年
+		{"month rollover", "0 0 1 * *", "2024-01-31 23:59", "2024-02-01 00:00"},
+		{"skip months without day 31", "0 0 31 * *", "2024-01-31 12:00", "2024-03-31 00:00"},
+		{"month list", "0 0 1 3,6 *", "2024-03-01 00:00", "2024-06-01 00:00"},
+		{"year rollover", "59 23 31 12 *", "2024-12-31 23:59", "2025-12-31 23:59"},
+		{"leap day", "0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%s: ParseCron(%q): %v", tt.name, tt.expr, err)
+			continue
+		}
+		if got := s.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+			t.Errorf("%s: %q from %s = %s, want %s", tt.name, tt.expr, tt.from, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+func TestCronNextNever(t *testing.T) {
+	s, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %s, want zero time", got)
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"@sometimes",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want error", expr)
+		}
+	}
+}
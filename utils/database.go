@@ -8,6 +8,9 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// SchemaVersion 数据库表结构版本，新增表或字段时递增（记录在备份清单中）
+const SchemaVersion = 5
+
 // InitDB 初始化数据库
 func InitDB(dbPath string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite", dbPath)
@@ -25,6 +28,11 @@ func InitDB(dbPath string) (*sql.DB, error) {
 		return nil, err
 	}
 
+	// 升级旧版本数据库的表结构
+	if err := migrateTables(db); err != nil {
+		return nil, err
+	}
+
 	// 创建默认用户
 	if err := models.CreateDefaultUser(db); err != nil {
 		log.Printf("创建默认用户失败: %v", err)
@@ -72,6 +80,7 @@ func createTables(db *sql.DB) error {
 		CREATE TABLE IF NOT EXISTS categories (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			id_str TEXT NOT NULL,
+			parent_id INTEGER DEFAULT 0,
 			classify TEXT NOT NULL,
 			icon TEXT NOT NULL,
 			sort_no INTEGER DEFAULT 0
@@ -98,12 +107,48 @@ func createTables(db *sql.DB) error {
 		return err
 	}
 
+	// 标签表（名称不区分大小写唯一）
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 站点-标签关联表
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS site_tags (
+			site_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (site_id, tag_id),
+			FOREIGN KEY (site_id) REFERENCES sites(id) ON DELETE CASCADE,
+			FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_site_tags_tag ON site_tags(tag_id)")
+	if err != nil {
+		return err
+	}
+
 	// 公告表
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS announcements (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			timestamp TEXT NOT NULL,
-			content TEXT NOT NULL
+			content TEXT NOT NULL,
+			format TEXT DEFAULT 'html',
+			publish_at TEXT DEFAULT '',
+			expire_at TEXT DEFAULT '',
+			priority INTEGER DEFAULT 0,
+			pinned INTEGER DEFAULT 0,
+			severity TEXT DEFAULT 'info'
 		)
 	`)
 	if err != nil {
@@ -129,7 +174,75 @@ func createTables(db *sql.DB) error {
 			subtitle TEXT DEFAULT '常用网址一键直达',
 			logo TEXT DEFAULT '/static/logo.png',
 			footer_text TEXT DEFAULT '',
-			icp TEXT DEFAULT ''
+			icp TEXT DEFAULT '',
+			footer_format TEXT DEFAULT 'html'
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 上传文件表（文件按内容哈希命名，path唯一）
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS uploads (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			hash TEXT NOT NULL,
+			path TEXT UNIQUE NOT NULL,
+			original_name TEXT DEFAULT '',
+			mime TEXT DEFAULT '',
+			size INTEGER DEFAULT 0,
+			uploader TEXT DEFAULT '',
+			visibility TEXT DEFAULT 'public',
+			download_count INTEGER DEFAULT 0,
+			last_download_at TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_uploads_hash ON uploads(hash)")
+	if err != nil {
+		return err
+	}
+
+	// 待删除的上传文件（事务中登记，提交后由 models.RemovePendingFiles 从存储中删除）
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS pending_file_removals (
+			path TEXT PRIMARY KEY,
+			queued_at INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 登录会话表（token 为 session cookie 的值）
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS login_sessions (
+			token TEXT PRIMARY KEY,
+			username TEXT NOT NULL,
+			expires_at INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// 分片上传会话表（分片内容保存在 UPLOAD_CHUNK_PATH/<id>/ 下）
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS upload_sessions (
+			id TEXT PRIMARY KEY,
+			filename TEXT NOT NULL,
+			type TEXT DEFAULT 'file',
+			size INTEGER NOT NULL,
+			chunk_size INTEGER NOT NULL,
+			sha256 TEXT DEFAULT '',
+			status TEXT DEFAULT 'pending',
+			uploader TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
 	if err != nil {
@@ -139,6 +252,64 @@ func createTables(db *sql.DB) error {
 	return nil
 }
 
+// migrateTables 为旧版本数据库补充新增的字段
+// CREATE TABLE IF NOT EXISTS 不会修改已存在的表，新增字段需要在这里登记
+func migrateTables(db *sql.DB) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"categories", "parent_id", "INTEGER DEFAULT 0"},
+		{"announcements", "format", "TEXT DEFAULT 'html'"},
+		{"announcements", "publish_at", "TEXT DEFAULT ''"},
+		{"announcements", "expire_at", "TEXT DEFAULT ''"},
+		{"announcements", "priority", "INTEGER DEFAULT 0"},
+		{"announcements", "pinned", "INTEGER DEFAULT 0"},
+		{"announcements", "severity", "TEXT DEFAULT 'info'"},
+		{"page_config", "footer_format", "TEXT DEFAULT 'html'"},
+		{"uploads", "visibility", "TEXT DEFAULT 'public'"},
+		{"uploads", "download_count", "INTEGER DEFAULT 0"},
+		{"uploads", "last_download_at", "TEXT DEFAULT ''"},
+	}
+
+	for _, col := range columns {
+		if err := addColumnIfNotExists(db, col.table, col.column, col.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumnIfNotExists 表中不存在该字段时添加
+func addColumnIfNotExists(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	if err == nil {
+		log.Printf("数据库升级: %s 表新增字段 %s", table, column)
+	}
+	return err
+}
+
 // initAnnouncementConfig 初始化公告配置
 func initAnnouncementConfig(db *sql.DB) error {
 	var count int
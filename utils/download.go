@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"nav-admin/config"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDefaultSessionSecret SESSION_SECRET 仍是公开的默认值，任何人都能伪造签名
+var ErrDefaultSessionSecret = errors.New("SESSION_SECRET 仍为默认值，请设置后再使用签名下载链接")
+
+// DownloadSigningEnabled 是否可以生成和接受签名下载链接（已设置非默认的 SESSION_SECRET）
+func DownloadSigningEnabled() bool {
+	secret := config.AppConfig.Session.Secret
+	return secret != "" && secret != config.DefaultSessionSecret
+}
+
+// SignDownload 生成下载签名，签名内容为访问路径和过期时间（Unix秒）
+func SignDownload(accessPath string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.Session.Secret))
+	fmt.Fprintf(mac, "download\n%s\n%d", accessPath, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDownloadSignature 校验下载签名是否正确且未过期
+func VerifyDownloadSignature(accessPath, expires, signature string) bool {
+	if !DownloadSigningEnabled() {
+		return false
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	expected := SignDownload(accessPath, exp)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
+
+// SignedDownloadURL 生成带签名的下载链接，SESSION_SECRET 为默认值时返回 ErrDefaultSessionSecret
+func SignedDownloadURL(accessPath string, expires time.Time) (string, error) {
+	if !DownloadSigningEnabled() {
+		return "", ErrDefaultSessionSecret
+	}
+	query := url.Values{}
+	query.Set("path", accessPath)
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("sig", SignDownload(accessPath, expires.Unix()))
+	return "/api/download?" + query.Encode(), nil
+}
+
+// ContentDisposition 生成符合 RFC 6266 的 Content-Disposition 头
+// filename 参数为ASCII兼容名（非ASCII字符替换为下划线），filename* 参数为UTF-8编码的原始文件名（RFC 5987）
+func ContentDisposition(disposition, filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1 // 去掉控制字符，防止响应头注入
+		}
+		return r
+	}, path.Base(strings.ReplaceAll(filename, "\\", "/")))
+	if filename == "" || filename == "." || filename == "/" {
+		filename = "download"
+	}
+
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r > 0x7e:
+			fallback.WriteByte('_')
+			ascii = false
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+
+	value := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback.String())
+	if !ascii {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return value
+}
+
+// encodeRFC5987 按 RFC 5987 的 attr-char 规则对文件名进行百分号编码
+func encodeRFC5987(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
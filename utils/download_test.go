@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"nav-admin/config"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignedDownloadURLRequiresSessionSecret(t *testing.T) {
+	config.AppConfig = &config.Config{Session: config.SessionConfig{Secret: config.DefaultSessionSecret}}
+	accessPath := "/uploads/files/report.pdf"
+	expires := time.Now().Add(time.Hour)
+
+	if _, err := SignedDownloadURL(accessPath, expires); err != ErrDefaultSessionSecret {
+		t.Fatalf("err = %v, want ErrDefaultSessionSecret", err)
+	}
+	// 用公开的默认密钥伪造的签名不被接受
+	exp := expires.Unix()
+	forged := SignDownload(accessPath, exp)
+	if VerifyDownloadSignature(accessPath, strconv.FormatInt(exp, 10), forged) {
+		t.Fatal("signature accepted with default secret")
+	}
+
+	config.AppConfig.Session.Secret = "test-secret"
+	link, err := SignedDownloadURL(accessPath, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("path") != accessPath {
+		t.Errorf("path = %q", query.Get("path"))
+	}
+	if !VerifyDownloadSignature(accessPath, query.Get("expires"), query.Get("sig")) {
+		t.Error("valid signature rejected")
+	}
+	if VerifyDownloadSignature("/uploads/files/other.pdf", query.Get("expires"), query.Get("sig")) {
+		t.Error("signature accepted for another path")
+	}
+	if VerifyDownloadSignature(accessPath, query.Get("expires"), forged) {
+		t.Error("signature made with default secret accepted")
+	}
+
+	past := time.Now().Add(-time.Minute)
+	link, _ = SignedDownloadURL(accessPath, past)
+	u, _ = url.Parse(link)
+	if VerifyDownloadSignature(accessPath, u.Query().Get("expires"), u.Query().Get("sig")) {
+		t.Error("expired signature accepted")
+	}
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLength 内容检测读取的最大字节数
+const sniffLength = 8192
+
+// utf8BOM UTF-8字节序标记
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// fileSignature 文件头特征
+type fileSignature struct {
+	offset int
+	magic  []byte
+}
+
+var (
+	sigPNG  = []fileSignature{{0, []byte("\x89PNG\r\n\x1a\n")}}
+	sigJPEG = []fileSignature{{0, []byte{0xFF, 0xD8, 0xFF}}}
+	sigGIF  = []fileSignature{{0, []byte("GIF87a")}, {0, []byte("GIF89a")}}
+	sigICO  = []fileSignature{{0, []byte{0x00, 0x00, 0x01, 0x00}}}
+	sigPDF  = []fileSignature{{0, []byte("%PDF-")}}
+	sigZIP  = []fileSignature{{0, []byte("PK\x03\x04")}, {0, []byte("PK\x05\x06")}}
+	sigRAR  = []fileSignature{{0, []byte("Rar!\x1a\x07")}}
+	sig7Z   = []fileSignature{{0, []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}}}
+	sigOLE  = []fileSignature{{0, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}}}
+)
+
+// extensionSignatures 各扩展名对应的文件头特征
+// docx/xlsx/pptx 是zip格式，doc/xls/ppt 是OLE复合文档格式
+var extensionSignatures = map[string][]fileSignature{
+	".png":  sigPNG,
+	".jpg":  sigJPEG,
+	".jpeg": sigJPEG,
+	".gif":  sigGIF,
+	".ico":  sigICO,
+	".pdf":  sigPDF,
+	".zip":  sigZIP,
+	".docx": sigZIP,
+	".xlsx": sigZIP,
+	".pptx": sigZIP,
+	".rar":  sigRAR,
+	".7z":   sig7Z,
+	".doc":  sigOLE,
+	".xls":  sigOLE,
+	".ppt":  sigOLE,
+}
+
+// ReadFileHead 读取文件开头用于内容检测
+func ReadFileHead(r io.Reader) ([]byte, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// CheckFileContent 检查文件内容与扩展名是否一致，防止伪造扩展名上传
+// head 为文件开头的内容（至少包含文件头）
+func CheckFileContent(ext string, head []byte) error {
+	ext = strings.ToLower(ext)
+	if len(head) == 0 {
+		return errors.New("文件内容为空")
+	}
+
+	switch ext {
+	case ".webp":
+		if len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WEBP" {
+			return nil
+		}
+	case ".svg":
+		if looksLikeSVG(head) {
+			return nil
+		}
+	case ".txt":
+		if isTextContent(head) {
+			return nil
+		}
+	default:
+		signatures, ok := extensionSignatures[ext]
+		if !ok {
+			return fmt.Errorf("无法识别 %s 类型的文件内容", ext)
+		}
+		for _, sig := range signatures {
+			if len(head) >= sig.offset+len(sig.magic) && bytes.Equal(head[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+				return nil
+			}
+		}
+	}
+
+	if detected := DetectFileType(head); detected != "" {
+		return fmt.Errorf("文件内容与扩展名不符（扩展名为 %s，实际内容为 %s）", ext, detected)
+	}
+	return fmt.Errorf("文件内容与扩展名 %s 不符", ext)
+}
+
+// DetectFileType 根据文件头判断文件类型，返回对应的扩展名，无法识别时返回空字符串
+func DetectFileType(head []byte) string {
+	// 按固定顺序检测，zip和OLE格式返回通用扩展名
+	for _, ext := range []string{".png", ".jpg", ".gif", ".ico", ".pdf", ".zip", ".rar", ".7z", ".doc"} {
+		for _, sig := range extensionSignatures[ext] {
+			if len(head) >= sig.offset+len(sig.magic) && bytes.Equal(head[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+				return ext
+			}
+		}
+	}
+	if len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WEBP" {
+		return ".webp"
+	}
+	if looksLikeSVG(head) {
+		return ".svg"
+	}
+	if isTextContent(head) {
+		return ".txt"
+	}
+	return ""
+}
+
+// isTextContent 判断是否是文本内容：不含NUL等控制字符
+// 不要求是UTF-8，GBK编码的文本同样可以通过
+func isTextContent(head []byte) bool {
+	for _, b := range head {
+		if b == 0 || (b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1b) {
+			return false
+		}
+	}
+	return true
+}
+
+// looksLikeSVG 判断内容是否是SVG：文本内容且第一个元素是<svg>
+func looksLikeSVG(head []byte) bool {
+	// 读取的内容可能在多字节字符中间截断，忽略末尾不完整的字符
+	valid := head
+	for i := 0; i < utf8.UTFMax-1 && len(valid) > 0 && !utf8.Valid(valid); i++ {
+		valid = valid[:len(valid)-1]
+	}
+	if !utf8.Valid(valid) {
+		return false
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(head, utf8BOM)))
+	decoder.Strict = false
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return strings.EqualFold(start.Name.Local, "svg")
+		}
+	}
+}
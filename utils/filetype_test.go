@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckFileContent(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F'}
+	pdf := []byte("%PDF-1.7\n")
+	zip := []byte("PK\x03\x04\x14\x00")
+	ole := []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1, 0x00}
+	webp := []byte("RIFF\x24\x00\x00\x00WEBPVP8L")
+	svg := []byte("\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- logo -->\n<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")
+	html := []byte("<html><body><svg></svg></body></html>")
+	exe := []byte("MZ\x90\x00\x03\x00\x00\x00")
+
+	tests := []struct {
+		ext     string
+		head    []byte
+		wantErr string // 空字符串表示通过
+	}{
+		{".png", png, ""},
+		{".PNG", png, ""},
+		{".jpg", jpeg, ""},
+		{".jpeg", jpeg, ""},
+		{".gif", []byte("GIF89a\x01\x00"), ""},
+		{".pdf", pdf, ""},
+		{".docx", zip, ""},
+		{".xls", ole, ""},
+		{".webp", webp, ""},
+		{".svg", svg, ""},
+		{".txt", []byte("中文\r\n\tline"), ""},
+
+		// 扩展名与内容不符
+		{".png", jpeg, "实际内容为 .jpg"},
+		{".jpg", png, "实际内容为 .png"},
+		{".pdf", zip, "实际内容为 .zip"},
+		{".docx", ole, "实际内容为 .doc"},
+		{".webp", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "不符"},
+		{".svg", html, "实际内容为 .txt"},
+		{".svg", png, "实际内容为 .png"},
+		{".txt", exe, "不符"},
+		{".gif", []byte("GIF88a"), "不符"},
+		{".png", png[:4], "不符"},
+		{".png", nil, "文件内容为空"},
+		{".exe", exe, "无法识别"},
+	}
+	for _, tt := range tests {
+		err := CheckFileContent(tt.ext, tt.head)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("CheckFileContent(%s, %q) = %v", tt.ext, tt.head, err)
+		case tt.wantErr != "" && err == nil:
+			t.Errorf("CheckFileContent(%s, %q) accepted mismatched content", tt.ext, tt.head)
+		case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+			t.Errorf("CheckFileContent(%s, %q) = %v, want error containing %q", tt.ext, tt.head, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"nav-admin/storage"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// maxImagePixels 允许解码的最大像素数，防止解压炸弹
+const maxImagePixels = 40 * 1000 * 1000
+
+// jpegQuality 重新编码JPEG时使用的质量
+const jpegQuality = 90
+
+// ThumbnailDir 缩略图目录（位于logos目录下）
+const ThumbnailDir = "thumbs"
+
+// ThumbnailFormats 缩略图输出格式
+var ThumbnailFormats = []string{".png", ".webp"}
+
+// IsImageExt 判断扩展名是否是可处理的位图格式
+func IsImageExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// ErrImageCorrupted 图片文件损坏或格式错误
+var ErrImageCorrupted = errors.New("图片文件已损坏或格式错误")
+
+// StripImageMetadata 去除图片中的EXIF、XMP、文本注释等元数据
+// 只删除元数据块，不重新编码图片，画质不受影响
+func StripImageMetadata(ext string, data []byte) ([]byte, error) {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return stripJPEGMetadata(data)
+	case ".png":
+		return stripPNGMetadata(data)
+	case ".webp":
+		return stripWebPMetadata(data)
+	}
+	return data, nil
+}
+
+// stripJPEGMetadata 删除JPEG中的APP1(EXIF/XMP)、APP13(IPTC)和COM段
+// 保留APP0(JFIF)、APP2(ICC色彩配置)和APP14(Adobe)，它们影响颜色显示
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrImageCorrupted
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	i := 2
+	for i+2 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, ErrImageCorrupted
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// 填充字节
+			i++
+			continue
+		case marker == 0xD9:
+			return append(out, data[i:]...), nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// 无长度字段的标记
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, ErrImageCorrupted
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, ErrImageCorrupted
+		}
+
+		// 扫描数据开始，之后是压缩数据，原样保留
+		if marker == 0xDA {
+			return append(out, data[i:]...), nil
+		}
+
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out = append(out, data[i:i+2+length]...)
+		}
+		i += 2 + length
+	}
+	return nil, ErrImageCorrupted
+}
+
+// pngMetadataChunks PNG中需要删除的元数据块
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// stripPNGMetadata 删除PNG中的EXIF、文本和时间块
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	const signatureLength = 8
+	if len(data) < signatureLength || !bytes.Equal(data[:signatureLength], sigPNG[0].magic) {
+		return nil, ErrImageCorrupted
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:signatureLength]...)
+	i := signatureLength
+	for i+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 12 + length // 长度(4) + 类型(4) + 数据 + CRC(4)
+		if length < 0 || end > len(data) {
+			return nil, ErrImageCorrupted
+		}
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+		if chunkType == "IEND" {
+			return out, nil
+		}
+	}
+	return nil, ErrImageCorrupted
+}
+
+// stripWebPMetadata 删除WebP中的EXIF和XMP块，并清除VP8X头中对应的标志位
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrImageCorrupted
+	}
+
+	var body []byte
+	i := 12
+	for i+8 <= len(data) {
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size&1 // 块大小为奇数时有一个填充字节
+		if size < 0 || i+8+size > len(data) {
+			return nil, ErrImageCorrupted
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if size > 0 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF、XMP 标志位
+			}
+			body = append(body, chunk...)
+		default:
+			body = append(body, data[i:end]...)
+		}
+		i = end
+	}
+
+	out := make([]byte, 12, 12+len(body))
+	copy(out, data[:12])
+	binary.LittleEndian.PutUint32(out[4:], uint32(4+len(body)))
+	return append(out, body...), nil
+}
+
+// DecodeImage 解码位图，限制最大像素数
+func DecodeImage(ext string, data []byte) (image.Image, error) {
+	ext = strings.ToLower(ext)
+
+	var decodeConfig func([]byte) (image.Config, error)
+	var decode func([]byte) (image.Image, error)
+	switch ext {
+	case ".png":
+		decodeConfig = func(b []byte) (image.Config, error) { return png.DecodeConfig(bytes.NewReader(b)) }
+		decode = func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) }
+	case ".jpg", ".jpeg":
+		decodeConfig = func(b []byte) (image.Config, error) { return jpeg.DecodeConfig(bytes.NewReader(b)) }
+		decode = func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) }
+	case ".gif":
+		// 动图只取第一帧
+		decodeConfig = func(b []byte) (image.Config, error) { return gif.DecodeConfig(bytes.NewReader(b)) }
+		decode = func(b []byte) (image.Image, error) { return gif.Decode(bytes.NewReader(b)) }
+	case ".webp":
+		decodeConfig = func(b []byte) (image.Config, error) { return webp.DecodeConfig(bytes.NewReader(b)) }
+		decode = func(b []byte) (image.Image, error) { return webp.Decode(bytes.NewReader(b)) }
+	default:
+		return nil, fmt.Errorf("不支持处理 %s 格式的图片", ext)
+	}
+
+	cfg, err := decodeConfig(data)
+	if err != nil {
+		return nil, ErrImageCorrupted
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errors.New("图片尺寸过大")
+	}
+
+	img, err := decode(data)
+	if err != nil {
+		return nil, ErrImageCorrupted
+	}
+	return img, nil
+}
+
+// EncodeImage 按扩展名编码图片，WebP使用无损编码
+func EncodeImage(ext string, img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch strings.ToLower(ext) {
+	case ".png":
+		err = png.Encode(&buf, img)
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case ".webp":
+		err = EncodeWebP(&buf, img)
+	default:
+		return nil, fmt.Errorf("不支持编码 %s 格式的图片", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FitImage 将图片等比缩小到不超过 maxSize×maxSize，图片本身更小时原样返回
+func FitImage(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxSize/width)
+		width = maxSize
+	} else {
+		width = max(1, width*maxSize/height)
+		height = maxSize
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// ResizeLogo 将超过 maxSize 的图标等比缩小到标准尺寸，返回处理后的内容和是否缩放
+// GIF（可能是动图）保持原样
+func ResizeLogo(ext string, data []byte, maxSize int) ([]byte, bool, error) {
+	ext = strings.ToLower(ext)
+	if ext == ".gif" || !IsImageExt(ext) {
+		return data, false, nil
+	}
+
+	img, err := DecodeImage(ext, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resized := FitImage(img, maxSize)
+	if resized == img {
+		return data, false, nil
+	}
+
+	out, err := EncodeImage(ext, resized)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// ThumbnailPath 返回图标缩略图的对象键
+// 例如 logos/a.png 的64px WebP缩略图为 logos/thumbs/a_64.webp
+func ThumbnailPath(logoKey string, size int, format string) string {
+	dir, name := path.Split(logoKey)
+	base := strings.TrimSuffix(name, path.Ext(name))
+	return path.Join(dir, ThumbnailDir, fmt.Sprintf("%s_%d%s", base, size, format))
+}
+
+// GenerateThumbnails 为图标生成各尺寸的PNG和WebP缩略图并保存到存储，返回生成的对象键
+func GenerateThumbnails(logoKey string, data []byte, sizes []int) ([]string, error) {
+	ext := strings.ToLower(path.Ext(logoKey))
+	if !IsImageExt(ext) {
+		return nil, nil
+	}
+
+	img, err := DecodeImage(ext, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, size := range sizes {
+		thumb := FitImage(img, size)
+		for _, format := range ThumbnailFormats {
+			out, err := EncodeImage(format, thumb)
+			if err != nil {
+				RemoveThumbnails(logoKey, sizes)
+				return nil, err
+			}
+			key := ThumbnailPath(logoKey, size, format)
+			if err := storage.Default.Put(key, bytes.NewReader(out), int64(len(out)), storage.ContentType(key)); err != nil {
+				RemoveThumbnails(logoKey, sizes)
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// RemoveThumbnails 删除图标对应的缩略图
+func RemoveThumbnails(logoKey string, sizes []int) {
+	for _, size := range sizes {
+		for _, format := range ThumbnailFormats {
+			storage.Default.Delete(ThumbnailPath(logoKey, size, format))
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package utils
+
+import (
+	"archive/zip"
+	"database/sql"
+	"nav-admin/models"
+	"nav-admin/navdoc"
+	"nav-admin/storage"
+	"strconv"
+	"strings"
+)
+
+// ImportOptions 导入策略
+type ImportOptions struct {
+	// Merge 为 false 时整体替换分类、站点和公告；为 true 时按分类 _id 和站点归一化链接合并，
+	// 已存在的更新、不存在的新增（新增的分类和站点排在已有数据之后）
+	Merge bool
+	// KeepUnmatched 合并时保留导入数据中没有的分类、站点和公告（为 false 时删除）
+	KeepUnmatched bool
+}
+
+// ImportDiff 导入预览：导入后与当前数据相比的变化
+type ImportDiff struct {
+	Categories    CategoryDiff     `json:"categories"`
+	Sites         SiteDiff         `json:"sites"`
+	Announcements AnnouncementDiff `json:"announcements"`
+	Settings      []FieldChange    `json:"settings"`
+	Uploads       *UploadDiff      `json:"uploads,omitempty"` // 仅完整备份
+	Users         *UserDiff        `json:"users,omitempty"`   // 仅恢复范围为 all 时
+}
+
+// FieldChange 字段变化
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// CategoryChange 分类的变化（按 _id 匹配）
+type CategoryChange struct {
+	ID       string        `json:"_id"`
+	Classify string        `json:"classify"`
+	Changes  []FieldChange `json:"changes,omitempty"`
+}
+
+// CategoryDiff 分类变化汇总
+type CategoryDiff struct {
+	Added     []CategoryChange `json:"added"`
+	Removed   []CategoryChange `json:"removed"`
+	Changed   []CategoryChange `json:"changed"`
+	Unchanged int              `json:"unchanged"`
+}
+
+// SiteChange 站点的变化（按归一化链接匹配：替换时优先匹配同一分类中的站点，合并时只匹配同一分类中的站点）
+type SiteChange struct {
+	Category string        `json:"category"` // 分类 _id
+	Name     string        `json:"name"`
+	Href     string        `json:"href"`
+	Changes  []FieldChange `json:"changes,omitempty"`
+}
+
+// SiteDiff 站点变化汇总
+type SiteDiff struct {
+	Added     []SiteChange `json:"added"`
+	Removed   []SiteChange `json:"removed"`
+	Changed   []SiteChange `json:"changed"`
+	Unchanged int          `json:"unchanged"`
+}
+
+// AnnouncementDiff 公告变化汇总（按发布时间和内容匹配）
+type AnnouncementDiff struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// UploadDiff 备份中的上传文件：覆盖已存在的文件或新增
+type UploadDiff struct {
+	Overwritten []string `json:"overwritten"`
+	Added       []string `json:"added"`
+}
+
+// UserDiff 备份中的用户：更新已存在用户的密码或新建
+type UserDiff struct {
+	Updated []string `json:"updated"`
+	Added   []string `json:"added"`
+}
+
+// CurrentSite 当前数据中的站点及其所在分类的 _id
+type CurrentSite struct {
+	Category string
+	Site     models.Site
+}
+
+// DiffNavDocument 对比导入的nav数据（已通过 ValidateNavDocument 校验）与当前数据库中的分类、站点和公告
+func DiffNavDocument(db *sql.DB, doc *navdoc.Document, opts ImportOptions) (*ImportDiff, error) {
+	diff := &ImportDiff{
+		Categories:    CategoryDiff{Added: []CategoryChange{}, Removed: []CategoryChange{}, Changed: []CategoryChange{}},
+		Sites:         SiteDiff{Added: []SiteChange{}, Removed: []SiteChange{}, Changed: []SiteChange{}},
+		Announcements: AnnouncementDiff{Added: []string{}, Removed: []string{}},
+		Settings:      []FieldChange{},
+	}
+
+	// 当前数据
+	categories, err := models.GetAllCategories(db)
+	if err != nil {
+		return nil, err
+	}
+	currentCats := make(map[string]models.Category, len(categories))
+	currentCatPos := make(map[string]int, len(categories))
+	currentIDStrs := make(map[int]string, len(categories)) // 分类ID -> _id（用于比较上级分类）
+	var currentSites []CurrentSite
+	for i, cat := range categories {
+		currentCats[cat.IDStr] = cat
+		currentCatPos[cat.IDStr] = i
+		currentIDStrs[cat.ID] = cat.IDStr
+		sites, err := models.GetSitesByCategoryID(db, cat.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, site := range sites {
+			currentSites = append(currentSites, CurrentSite{Category: cat.IDStr, Site: site})
+		}
+	}
+
+	announcements, err := models.GetAllAnnouncements(db)
+	if err != nil {
+		return nil, err
+	}
+	currentAnns := map[string]int{}
+	for _, ann := range announcements {
+		currentAnns[ann.Timestamp+"\x00"+ann.Content]++
+	}
+
+	// 导入数据
+	seenCats := map[string]bool{}
+	matchedSites := map[int]bool{}
+	if doc.AnnouncementConfig != nil {
+		for _, ann := range doc.AnnouncementConfig.Announcements {
+			// 数据库中保存的是过滤后的内容
+			key := ann.Timestamp + "\x00" + SanitizeRichText(ann.Content, ann.Format)
+			if currentAnns[key] > 0 {
+				currentAnns[key]--
+				diff.Announcements.Unchanged++
+			} else {
+				diff.Announcements.Added = append(diff.Announcements.Added, ann.Content)
+			}
+		}
+	}
+
+	siteMatches := MatchSites(doc, currentSites)
+	for catPos, cat := range doc.Categories {
+		seenCats[cat.ID] = true
+
+		if cur, ok := currentCats[cat.ID]; ok {
+			var changes []FieldChange
+			changes = appendChange(changes, "classify", cur.Classify, cat.Classify)
+			changes = appendChange(changes, "icon", cur.Icon, cat.Icon)
+			if doc.Hierarchical() || !opts.Merge {
+				// 合并没有分类层级的文档时不修改上级分类
+				changes = appendChange(changes, "parent", currentIDStrs[cur.ParentID], cat.Parent)
+			}
+			if !opts.Merge {
+				// 合并时已有分类保持原来的位置
+				changes = appendChange(changes, "position", strconv.Itoa(currentCatPos[cat.ID]+1), strconv.Itoa(catPos+1))
+			}
+			if len(changes) > 0 {
+				diff.Categories.Changed = append(diff.Categories.Changed, CategoryChange{ID: cat.ID, Classify: cat.Classify, Changes: changes})
+			} else {
+				diff.Categories.Unchanged++
+			}
+		} else {
+			diff.Categories.Added = append(diff.Categories.Added, CategoryChange{ID: cat.ID, Classify: cat.Classify})
+		}
+
+		for sitePos, site := range cat.Sites {
+			cur := siteMatches[catPos][sitePos]
+			if cur == nil {
+				diff.Sites.Added = append(diff.Sites.Added, SiteChange{Category: cat.ID, Name: site.Name, Href: site.Href})
+				continue
+			}
+			matchedSites[cur.Site.ID] = true
+
+			var changes []FieldChange
+			changes = appendChange(changes, "category", cur.Category, cat.ID)
+			changes = appendChange(changes, "name", cur.Site.Name, site.Name)
+			changes = appendChange(changes, "href", cur.Site.Href, site.Href)
+			changes = appendChange(changes, "desc", cur.Site.Desc, site.Desc)
+			changes = appendChange(changes, "logo", cur.Site.Logo, site.Logo)
+			if (doc.Tagged() || !opts.Merge) && !models.SameTags(cur.Site.Tags, site.Tags) {
+				// 合并没有标签信息的文档时不修改站点的标签
+				changes = appendChange(changes, "tags", strings.Join(cur.Site.Tags, ", "), strings.Join(site.Tags, ", "))
+			}
+			if len(changes) > 0 {
+				diff.Sites.Changed = append(diff.Sites.Changed, SiteChange{Category: cat.ID, Name: site.Name, Href: site.Href, Changes: changes})
+			} else {
+				diff.Sites.Unchanged++
+			}
+		}
+	}
+
+	// 导入后将被删除的数据（合并并保留未匹配数据时没有）
+	if opts.Merge && opts.KeepUnmatched {
+		return diff, nil
+	}
+	for _, cat := range categories {
+		if !seenCats[cat.IDStr] {
+			diff.Categories.Removed = append(diff.Categories.Removed, CategoryChange{ID: cat.IDStr, Classify: cat.Classify})
+		}
+	}
+	for _, s := range currentSites {
+		if !matchedSites[s.Site.ID] {
+			diff.Sites.Removed = append(diff.Sites.Removed, SiteChange{Category: s.Category, Name: s.Site.Name, Href: s.Site.Href})
+		}
+	}
+	for _, ann := range announcements {
+		key := ann.Timestamp + "\x00" + ann.Content
+		if currentAnns[key] > 0 {
+			currentAnns[key]--
+			diff.Announcements.Removed = append(diff.Announcements.Removed, ann.Content)
+		}
+	}
+
+	return diff, nil
+}
+
+// MatchSites 按归一化链接把导入数据中的站点与当前站点一一匹配，
+// 返回值 [i][j] 对应 doc.Categories[i].Sites[j]，为 nil 时表示没有匹配的当前站点（新站点）。
+// 先匹配同一分类（按 _id）中的站点，剩下的再匹配其他分类中的站点（导入后移动到导入数据中的分类），
+// 因此导入数据中多个分类包含同一链接时，原分类中的站点留在原分类
+func MatchSites(doc *navdoc.Document, current []CurrentSite) [][]*CurrentSite {
+	byHref := map[string][]*CurrentSite{} // 归一化链接 -> 当前站点
+	for i := range current {
+		key := models.NormalizeHref(current[i].Site.Href)
+		byHref[key] = append(byHref[key], &current[i])
+	}
+
+	matches := make([][]*CurrentSite, len(doc.Categories))
+	for i, cat := range doc.Categories {
+		matches[i] = make([]*CurrentSite, len(cat.Sites))
+	}
+	matched := map[int]bool{}
+	match := func(sameCategory bool) {
+		for i, cat := range doc.Categories {
+			for j, site := range cat.Sites {
+				if matches[i][j] != nil {
+					continue
+				}
+				for _, cur := range byHref[models.NormalizeHref(site.Href)] {
+					if matched[cur.Site.ID] || (sameCategory && cur.Category != cat.ID) {
+						continue
+					}
+					matches[i][j] = cur
+					matched[cur.Site.ID] = true
+					break
+				}
+			}
+		}
+	}
+	match(true)
+	match(false)
+	return matches
+}
+
+// appendChange 值不同时记录字段变化
+func appendChange(changes []FieldChange, field, old, new string) []FieldChange {
+	if old == new {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, Old: old, New: new})
+}
+
+// DiffSettings 对比导入数据中的公告轮播间隔，以及 pageConfig（不为nil时）与当前页面配置
+func DiffSettings(db *sql.DB, doc *navdoc.Document, pageConfig *models.PageConfig) ([]FieldChange, error) {
+	changes := []FieldChange{}
+
+	if cfg := doc.AnnouncementConfig; cfg != nil && cfg.Interval != nil {
+		current, err := models.GetAnnouncementInterval(db)
+		if err != nil {
+			return nil, err
+		}
+		changes = appendChange(changes, "announcement.interval", strconv.Itoa(current), strconv.Itoa(*cfg.Interval))
+	}
+
+	if pageConfig != nil {
+		current, err := models.GetPageConfig(db)
+		if err != nil {
+			return nil, err
+		}
+		changes = appendChange(changes, "page_config.title", current.Title, pageConfig.Title)
+		changes = appendChange(changes, "page_config.subtitle", current.Subtitle, pageConfig.Subtitle)
+		changes = appendChange(changes, "page_config.logo", current.Logo, pageConfig.Logo)
+		changes = appendChange(changes, "page_config.footer_text", current.FooterText, pageConfig.FooterText)
+		changes = appendChange(changes, "page_config.icp", current.ICP, pageConfig.ICP)
+		changes = appendChange(changes, "page_config.footer_format", current.FooterFormat, pageConfig.FooterFormat)
+	}
+
+	return changes, nil
+}
+
+// DiffBackupUploads 列出备份中将覆盖存储中已有文件的上传文件和新增的上传文件
+func DiffBackupUploads(zipReader *zip.Reader) (*UploadDiff, error) {
+	diff := &UploadDiff{Overwritten: []string{}, Added: []string{}}
+	for _, f := range zipReader.File {
+		if !strings.HasPrefix(f.Name, "uploads/") || f.FileInfo().IsDir() {
+			continue
+		}
+		key, err := storage.CleanKey(strings.TrimPrefix(f.Name, "uploads/"))
+		if err != nil {
+			continue
+		}
+		_, err = storage.Default.Stat(key)
+		switch {
+		case err == nil:
+			diff.Overwritten = append(diff.Overwritten, storage.URLFromKey(key))
+		case err == storage.ErrNotExist:
+			diff.Added = append(diff.Added, storage.URLFromKey(key))
+		default:
+			return nil, err
+		}
+	}
+	return diff, nil
+}
+
+// DiffBackupUsers 列出备份中将被更新密码的已有用户和将新建的用户
+func DiffBackupUsers(db *sql.DB, users []BackupUser) (*UserDiff, error) {
+	diff := &UserDiff{Updated: []string{}, Added: []string{}}
+	for _, user := range users {
+		_, err := models.GetUserByUsername(db, user.Username)
+		switch {
+		case err == nil:
+			diff.Updated = append(diff.Updated, user.Username)
+		case err == sql.ErrNoRows:
+			diff.Added = append(diff.Added, user.Username)
+		default:
+			return nil, err
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// metadataFetchTimeout 抓取网页元数据的超时时间
+	metadataFetchTimeout = 10 * time.Second
+	// metadataMaxBodySize 最多读取的网页字节数（元数据都在<head>中，无需完整页面）
+	metadataMaxBodySize = 512 * 1024
+	// metadataMaxRedirects 最多跟随的重定向次数
+	metadataMaxRedirects = 5
+)
+
+// PageMetadata 从网页中提取的元数据
+type PageMetadata struct {
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	OGTitle       string `json:"og_title"`
+	OGDescription string `json:"og_description"`
+	OGImage       string `json:"og_image"`
+	OGSiteName    string `json:"og_site_name"`
+	Canonical     string `json:"canonical"`
+	Icon          string `json:"icon"`
+	// Name 和 Desc 是用于预填站点表单的建议值
+	Name string `json:"name"`
+	Desc string `json:"desc"`
+}
+
+var metadataClient = newMetadataClient(metadataFetchTimeout, checkMetadataAddr)
+
+// newMetadataClient 创建抓取网页用的HTTP客户端
+// checkAddr 在每次建立连接前检查实际连接的IP地址（包括重定向后的地址），返回错误时拒绝连接
+func newMetadataClient(timeout time.Duration, checkAddr func(address string) error) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddr(address)
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// 不使用环境变量中的代理，否则检查的是代理的地址而不是目标地址
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= metadataMaxRedirects {
+				return errors.New("重定向次数过多")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("只支持http/https地址")
+			}
+			return nil
+		},
+	}
+}
+
+// checkMetadataAddr 拒绝连接本机、内网、链路本地（包括云服务器元数据地址 169.254.169.254）等地址，防止SSRF
+func checkMetadataAddr(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("无效的地址 %s", host)
+	}
+	if isBlockedMetadataIP(ip) {
+		return fmt.Errorf("不允许访问内网地址 %s", ip)
+	}
+	return nil
+}
+
+// blockedMetadataNets 标准库未归类但同样不允许抓取的网段
+var blockedMetadataNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),  // 运营商级NAT（CGNAT），Tailscale 等也使用该网段
+	mustParseCIDR("64:ff9b:1::/48"), // 本地使用的 NAT64 前缀
+	mustParseCIDR("198.18.0.0/15"),  // 网络设备基准测试
+}
+
+// nat64Prefix 众所周知的 NAT64 前缀，后32位是嵌入的IPv4地址
+var nat64Prefix = mustParseCIDR("64:ff9b::/96")
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// isBlockedMetadataIP 判断是否为不允许抓取的地址
+// IPv4映射地址（::ffff:a.b.c.d）和 NAT64 地址（64:ff9b::a.b.c.d）按嵌入的IPv4地址判断
+func isBlockedMetadataIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	} else if nat64Prefix.Contains(ip) {
+		ip = net.IP(ip[12:16])
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, n := range blockedMetadataNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchPageMetadata 抓取指定网页并提取标题、描述、Open Graph 和 canonical 信息
+// 只读取前 metadataMaxBodySize 字节，并根据响应头和<meta charset>自动识别编码（如GBK）
+// 不允许访问本机和内网地址（重定向后的地址同样检查）
+func FetchPageMetadata(rawURL string) (*PageMetadata, error) {
+	return fetchPageMetadata(metadataClient, rawURL)
+}
+
+// fetchPageMetadata 使用指定的客户端抓取网页元数据
+func fetchPageMetadata(client *http.Client, rawURL string) (*PageMetadata, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("只支持http/https地址")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; nav-admin metadata fetcher)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("目标网站返回状态码 %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, errors.New("目标地址不是HTML页面")
+	}
+
+	body := io.LimitReader(resp.Body, metadataMaxBodySize)
+	reader, err := charset.NewReader(body, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("无法识别网页编码: %v", err)
+	}
+
+	meta := parsePageMetadata(reader, resp.Request.URL)
+	return meta, nil
+}
+
+// parsePageMetadata 解析HTML，提取<head>中的元数据
+// baseURL 用于把相对地址（图标、canonical等）转换为绝对地址
+func parsePageMetadata(r io.Reader, baseURL *url.URL) *PageMetadata {
+	meta := &PageMetadata{URL: baseURL.String()}
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case html.ErrorToken:
+			// io.EOF 或读取达到大小限制，返回已提取的内容
+			meta.fillSuggestions()
+			return meta
+		case html.TextToken:
+			if inTitle && meta.Title == "" {
+				meta.Title = cleanText(string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				meta.fillSuggestions()
+				return meta
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			tag := string(name)
+			if tag == "body" {
+				meta.fillSuggestions()
+				return meta
+			}
+			if tag == "title" {
+				inTitle = tt == html.StartTagToken
+				continue
+			}
+			if !hasAttr || (tag != "meta" && tag != "link") {
+				continue
+			}
+
+			attrs := make(map[string]string)
+			for {
+				key, val, more := tokenizer.TagAttr()
+				attrs[strings.ToLower(string(key))] = string(val)
+				if !more {
+					break
+				}
+			}
+
+			if tag == "meta" {
+				meta.applyMetaTag(attrs, baseURL)
+			} else {
+				meta.applyLinkTag(attrs, baseURL)
+			}
+		}
+	}
+}
+
+// applyMetaTag 处理<meta>标签
+func (m *PageMetadata) applyMetaTag(attrs map[string]string, baseURL *url.URL) {
+	key := strings.ToLower(attrs["property"])
+	if key == "" {
+		key = strings.ToLower(attrs["name"])
+	}
+	content := cleanText(attrs["content"])
+	if key == "" || content == "" {
+		return
+	}
+
+	switch key {
+	case "description":
+		if m.Description == "" {
+			m.Description = content
+		}
+	case "og:title", "twitter:title":
+		if m.OGTitle == "" {
+			m.OGTitle = content
+		}
+	case "og:description", "twitter:description":
+		if m.OGDescription == "" {
+			m.OGDescription = content
+		}
+	case "og:image", "twitter:image":
+		if m.OGImage == "" {
+			m.OGImage = resolveURL(baseURL, content)
+		}
+	case "og:site_name":
+		if m.OGSiteName == "" {
+			m.OGSiteName = content
+		}
+	}
+}
+
+// applyLinkTag 处理<link>标签（canonical 和图标）
+func (m *PageMetadata) applyLinkTag(attrs map[string]string, baseURL *url.URL) {
+	href := strings.TrimSpace(attrs["href"])
+	if href == "" {
+		return
+	}
+
+	for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+		switch rel {
+		case "canonical":
+			if m.Canonical == "" {
+				m.Canonical = resolveURL(baseURL, href)
+			}
+		case "icon", "apple-touch-icon":
+			if m.Icon == "" {
+				m.Icon = resolveURL(baseURL, href)
+			}
+		}
+	}
+}
+
+// fillSuggestions 根据提取结果生成站点名称和描述的建议值
+func (m *PageMetadata) fillSuggestions() {
+	switch {
+	case m.OGSiteName != "":
+		m.Name = m.OGSiteName
+	case m.OGTitle != "":
+		m.Name = m.OGTitle
+	default:
+		m.Name = m.Title
+	}
+
+	if m.Description != "" {
+		m.Desc = m.Description
+	} else {
+		m.Desc = m.OGDescription
+	}
+}
+
+// resolveURL 将相对地址转换为绝对地址
+func resolveURL(base *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// cleanText 合并多余的空白字符
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
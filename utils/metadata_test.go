@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allowAllAddr 测试中允许连接本机的测试服务器
+func allowAllAddr(string) error { return nil }
+
+func serveHTML(contentType, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestFetchPageMetadataGBK(t *testing.T) {
+	// "中文标题" 和 "简介" 的GBK编码
+	body := "<html><head><meta charset=\"gbk\"><title>\xd6\xd0\xce\xc4\xb1\xea\xcc\xe2</title>" +
+		"<meta name=\"description\" content=\"\xbc\xf2\xbd\xe9\"></head><body></body></html>"
+	srv := serveHTML("text/html", body)
+	defer srv.Close()
+
+	meta, err := fetchPageMetadata(newMetadataClient(time.Second, allowAllAddr), srv.URL)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if meta.Title != "中文标题" || meta.Name != "中文标题" {
+		t.Errorf("title = %q, name = %q", meta.Title, meta.Name)
+	}
+	if meta.Description != "简介" || meta.Desc != "简介" {
+		t.Errorf("description = %q, desc = %q", meta.Description, meta.Desc)
+	}
+}
+
+func TestFetchPageMetadataOpenGraph(t *testing.T) {
+	body := `<html><head>
+<title>  Page
+  Title </title>
+<meta property="og:title" content="OG Title">
+<meta property="og:site_name" content="Example Site">
+<meta property="og:description" content="OG description">
+<meta property="og:image" content="/img/cover.png">
+<link rel="canonical" href="/canonical/page">
+<link rel="shortcut icon" href="favicon.ico">
+</head><body><title>ignored</title></body></html>`
+	srv := serveHTML("text/html; charset=utf-8", body)
+	defer srv.Close()
+
+	meta, err := fetchPageMetadata(newMetadataClient(time.Second, allowAllAddr), srv.URL+"/dir/page")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	checks := map[string][2]string{
+		"title":          {meta.Title, "Page Title"},
+		"og_title":       {meta.OGTitle, "OG Title"},
+		"og_site_name":   {meta.OGSiteName, "Example Site"},
+		"og_description": {meta.OGDescription, "OG description"},
+		"og_image":       {meta.OGImage, srv.URL + "/img/cover.png"},
+		"canonical":      {meta.Canonical, srv.URL + "/canonical/page"},
+		"icon":           {meta.Icon, srv.URL + "/dir/favicon.ico"},
+		"name":           {meta.Name, "Example Site"},
+		"desc":           {meta.Desc, "OG description"},
+	}
+	for field, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("%s = %q, want %q", field, c[0], c[1])
+		}
+	}
+}
+
+func TestFetchPageMetadataSizeLimit(t *testing.T) {
+	// 标题在读取上限之后，不应被读取
+	body := "<html><head><meta name=\"description\" content=\"early\">" +
+		"<!--" + strings.Repeat("x", metadataMaxBodySize) + "-->" +
+		"<title>late</title></head></html>"
+	srv := serveHTML("text/html; charset=utf-8", body)
+	defer srv.Close()
+
+	meta, err := fetchPageMetadata(newMetadataClient(time.Second, allowAllAddr), srv.URL)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if meta.Title != "" {
+		t.Errorf("title beyond size limit was read: %q", meta.Title)
+	}
+	if meta.Description != "early" {
+		t.Errorf("description = %q, want %q", meta.Description, "early")
+	}
+}
+
+func TestFetchPageMetadataTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := fetchPageMetadata(newMetadataClient(200*time.Millisecond, allowAllAddr), srv.URL)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout took %v", elapsed)
+	}
+}
+
+func TestFetchPageMetadataRejectsNonHTML(t *testing.T) {
+	srv := serveHTML("application/json", `{}`)
+	defer srv.Close()
+
+	if _, err := fetchPageMetadata(newMetadataClient(time.Second, allowAllAddr), srv.URL); err == nil {
+		t.Fatal("expected error for non-HTML content")
+	}
+	if _, err := fetchPageMetadata(newMetadataClient(time.Second, allowAllAddr), "ftp://example.com/"); err == nil {
+		t.Fatal("expected error for non-http scheme")
+	}
+}
+
+func TestFetchPageMetadataRejectsLoopback(t *testing.T) {
+	srv := serveHTML("text/html", "<title>secret</title>")
+	defer srv.Close()
+
+	_, err := FetchPageMetadata(srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "不允许访问内网地址") {
+		t.Fatalf("err = %v, want loopback rejection", err)
+	}
+}
+
+func TestFetchPageMetadataRejectsRedirectToPrivate(t *testing.T) {
+	internal := serveHTML("text/html", "<title>secret</title>")
+	defer internal.Close()
+	public := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer public.Close()
+
+	// 把 public 服务器当作外网地址，其他地址按默认规则检查
+	publicAddr := public.Listener.Addr().String()
+	client := newMetadataClient(time.Second, func(address string) error {
+		if address == publicAddr {
+			return nil
+		}
+		return checkMetadataAddr(address)
+	})
+
+	_, err := fetchPageMetadata(client, public.URL)
+	if err == nil || !strings.Contains(err.Error(), "不允许访问内网地址") {
+		t.Fatalf("err = %v, want redirect rejection", err)
+	}
+}
+
+func TestIsBlockedMetadataIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"224.0.0.1", true},
+		{"100.64.0.1", true},
+		{"100.100.100.100", true},
+		{"100.127.255.255", true},
+		{"::ffff:100.64.0.1", true},
+		{"::ffff:169.254.169.254", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b::10.0.0.1", true},
+		{"64:ff9b::127.0.0.1", true},
+		{"64:ff9b:1::1", true},
+		{"198.18.0.1", true},
+		{"100.128.0.1", false},
+		{"100.63.255.255", false},
+		{"::ffff:8.8.8.8", false},
+		{"64:ff9b::8.8.8.8", false},
+		{"8.8.8.8", false},
+		{"172.32.0.1", false},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := isBlockedMetadataIP(net.ParseIP(tt.ip)); got != tt.blocked {
+			t.Errorf("isBlockedMetadataIP(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/xml"
+	"html"
+	"io"
+	"nav-admin/navdoc"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NavExportFormat 导航数据的导出格式
+type NavExportFormat struct {
+	Ext         string // 下载文件的扩展名
+	ContentType string
+	Write       func(w io.Writer, doc *navdoc.Document) error
+}
+
+// NavExportFormats 除JSON外支持的导出格式（/api/admin/export?format=），都只导出分类和站点
+var NavExportFormats = map[string]NavExportFormat{
+	"html":     {Ext: ".html", ContentType: "text/html; charset=utf-8", Write: WriteBookmarksHTML},
+	"opml":     {Ext: ".opml", ContentType: "text/x-opml; charset=utf-8", Write: WriteOPML},
+	"csv":      {Ext: ".csv", ContentType: "text/csv; charset=utf-8", Write: WriteSitesCSV},
+	"markdown": {Ext: ".md", ContentType: "text/markdown; charset=utf-8", Write: WriteMarkdown},
+	"xlsx":     {Ext: ".xlsx", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Write: WriteSitesXLSX},
+}
+
+// docTitle 导出文件使用的标题（页面标题）
+func docTitle(doc *navdoc.Document) string {
+	if doc.PageConfig != nil && strings.TrimSpace(doc.PageConfig.Title) != "" {
+		return doc.PageConfig.Title
+	}
+	return "网址导航"
+}
+
+// WriteBookmarksHTML 导出为 Netscape 格式的书签文件（可导入任意浏览器），每个分类是一个书签文件夹，子分类是其中的子文件夹
+// 站点logo是本站地址，浏览器无法作为书签图标使用，不导出
+func WriteBookmarksHTML(w io.Writer, doc *navdoc.Document) error {
+	bw := bufio.NewWriter(w)
+	addDate := strconv.FormatInt(time.Now().Unix(), 10)
+	esc := html.EscapeString
+
+	var writeFolder func(cat navdoc.Category, indent string)
+	writeFolder = func(cat navdoc.Category, indent string) {
+		bw.WriteString(indent + "<DT><H3 ADD_DATE=\"" + addDate + "\">" + esc(cat.Classify) + "</H3>\n")
+		bw.WriteString(indent + "<DL><p>\n")
+		for _, site := range cat.Sites {
+			attrs := "HREF=\"" + esc(site.Href) + "\" ADD_DATE=\"" + addDate + "\""
+			if len(site.Tags) > 0 {
+				// Firefox 的书签标签，多个标签用逗号分隔
+				attrs += " TAGS=\"" + esc(strings.Join(site.Tags, ",")) + "\""
+			}
+			bw.WriteString(indent + "    <DT><A " + attrs + ">" + esc(site.Name) + "</A>\n")
+			if site.Desc != "" {
+				bw.WriteString(indent + "    <DD>" + esc(site.Desc) + "\n")
+			}
+		}
+		for _, child := range cat.Children {
+			writeFolder(child, indent+"    ")
+		}
+		bw.WriteString(indent + "</DL><p>\n")
+	}
+
+	bw.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	bw.WriteString("<!-- This is an automatically generated file.\n     It will be read and overwritten.\n     DO NOT EDIT! -->\n")
+	bw.WriteString(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n")
+	bw.WriteString("<TITLE>Bookmarks</TITLE>\n")
+	bw.WriteString("<H1>Bookmarks</H1>\n")
+	bw.WriteString("<DL><p>\n")
+	bw.WriteString("    <DT><H3 ADD_DATE=\"" + addDate + "\">" + esc(docTitle(doc)) + "</H3>\n")
+	bw.WriteString("    <DL><p>\n")
+	for _, cat := range navdoc.Tree(doc.Categories) {
+		writeFolder(cat, "        ")
+	}
+	bw.WriteString("    </DL><p>\n")
+	bw.WriteString("</DL><p>\n")
+	return bw.Flush()
+}
+
+// opmlOutline OPML 大纲条目：分类是包含站点的条目，站点是 type=link 的条目
+type opmlOutline struct {
+	Text        string        `xml:"text,attr"`
+	Title       string        `xml:"title,attr,omitempty"`
+	Type        string        `xml:"type,attr,omitempty"`
+	URL         string        `xml:"url,attr,omitempty"`
+	Description string        `xml:"description,attr,omitempty"`
+	Outlines    []opmlOutline `xml:"outline"`
+}
+
+// opmlDocument OPML 2.0 文档
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title"`
+		DateCreated string `xml:"dateCreated"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []opmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+// WriteOPML 导出为 OPML 2.0 大纲，每个顶级分类是一个顶层条目，站点是其下 type=link 的条目，子分类是其下的子条目
+func WriteOPML(w io.Writer, doc *navdoc.Document) error {
+	opml := opmlDocument{Version: "2.0"}
+	opml.Head.Title = docTitle(doc)
+	opml.Head.DateCreated = time.Now().Format(time.RFC1123Z)
+	tree := navdoc.Tree(doc.Categories)
+	opml.Body.Outlines = make([]opmlOutline, 0, len(tree))
+	for _, cat := range tree {
+		opml.Body.Outlines = append(opml.Body.Outlines, categoryOutline(cat))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(opml); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// categoryOutline 分类的 OPML 条目（先是站点，然后是子分类）
+func categoryOutline(cat navdoc.Category) opmlOutline {
+	outline := opmlOutline{Text: cat.Classify, Title: cat.Classify}
+	for _, site := range cat.Sites {
+		outline.Outlines = append(outline.Outlines, opmlOutline{
+			Text:        site.Name,
+			Title:       site.Name,
+			Type:        "link",
+			URL:         site.Href,
+			Description: site.Desc,
+		})
+	}
+	for _, child := range cat.Children {
+		outline.Outlines = append(outline.Outlines, categoryOutline(child))
+	}
+	return outline
+}
+
+// WriteMarkdown 导出为按分类分组的 Markdown 链接列表，子分类使用下一级标题（最多到六级标题）
+func WriteMarkdown(w io.Writer, doc *navdoc.Document) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# " + markdownEscape(docTitle(doc)) + "\n")
+
+	var writeSection func(cat navdoc.Category, level int)
+	writeSection = func(cat navdoc.Category, level int) {
+		bw.WriteString("\n" + strings.Repeat("#", level) + " " + markdownEscape(cat.Classify) + "\n\n")
+		for _, site := range cat.Sites {
+			bw.WriteString("- [" + markdownEscape(site.Name) + "](<" + markdownURL(site.Href) + ">)")
+			if site.Desc != "" {
+				bw.WriteString(" - " + markdownEscape(site.Desc))
+			}
+			bw.WriteString("\n")
+		}
+		for _, child := range cat.Children {
+			writeSection(child, min(level+1, 6))
+		}
+	}
+	for _, cat := range navdoc.Tree(doc.Categories) {
+		writeSection(cat, 2)
+	}
+	return bw.Flush()
+}
+
+// markdownEscaper 转义 Markdown 中有特殊含义的字符（换行替换为空格，保持列表项在一行）
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ",
+)
+
+// markdownEscape 转义 Markdown 文本
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// markdownURL 转义尖括号形式链接目标中的特殊字符
+func markdownURL(s string) string {
+	return strings.NewReplacer("<", "%3C", ">", "%3E", " ", "%20", "\n", "", "\r", "").Replace(s)
+}
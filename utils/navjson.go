@@ -5,110 +5,119 @@ import (
 	"encoding/json"
 	"log"
 	"nav-admin/config"
+	"nav-admin/models"
+	"nav-admin/navdoc"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 // 文件锁，防止并发写入
 var navJSONMutex sync.Mutex
 
-// NavJSONCategory 导航分类结构（用于JSON输出）
-type NavJSONCategory struct {
-	ID       string        `json:"_id"`
-	Classify string        `json:"classify"`
-	Icon     string        `json:"icon"`
-	Sites    []NavJSONSite `json:"sites"`
-}
-
-// NavJSONSite 站点结构（用于JSON输出）
-type NavJSONSite struct {
-	Name string `json:"name"`
-	Href string `json:"href"`
-	Desc string `json:"desc"`
-	Logo string `json:"logo"`
-}
+// BuildNavExport 获取导出用的完整导航数据：页面配置（保留页脚原始内容和格式，导入时可原样恢复）、
+// 全部公告（含未生效和已过期的）、分类及其站点
+func BuildNavExport(db *sql.DB) (*navdoc.Document, error) {
+	pageConfig, err := models.GetPageConfig(db)
+	if err != nil {
+		return nil, err
+	}
 
-// NavJSONAnnouncement 公告结构（用于JSON输出）
-type NavJSONAnnouncement struct {
-	ID        int    `json:"id"`
-	Timestamp string `json:"timestamp"`
-	Content   string `json:"content"`
-}
+	announcementConfig, err := models.GetAnnouncementConfig(db)
+	if err != nil {
+		return nil, err
+	}
+	announcements := make([]navdoc.Announcement, 0, len(announcementConfig.Announcements))
+	for _, ann := range announcementConfig.Announcements {
+		announcements = append(announcements, navdoc.Announcement{
+			Timestamp: ann.Timestamp,
+			Content:   ann.Content,
+			Format:    ann.Format,
+			PublishAt: ann.PublishAt,
+			ExpireAt:  ann.ExpireAt,
+			Priority:  ann.Priority,
+			Pinned:    ann.Pinned,
+			Severity:  ann.Severity,
+		})
+	}
 
-// NavJSONAnnouncementConfig 公告配置结构（用于JSON输出）
-type NavJSONAnnouncementConfig struct {
-	ID            string                `json:"_id"`
-	Type          string                `json:"type"`
-	Interval      int                   `json:"interval"`
-	Announcements []NavJSONAnnouncement `json:"announcements"`
-}
+	categories, err := getCategoriesForJSON(db)
+	if err != nil {
+		return nil, err
+	}
 
-// NavJSONPageConfig 页面配置结构（用于JSON输出）
-type NavJSONPageConfig struct {
-	Type       string `json:"type"`
-	Title      string `json:"title"`
-	Subtitle   string `json:"subtitle"`
-	Logo       string `json:"logo"`
-	FooterText string `json:"footer_text"`
-	ICP        string `json:"icp"`
+	return &navdoc.Document{
+		Version: navdoc.Version,
+		PageConfig: &navdoc.PageConfig{
+			Title:        pageConfig.Title,
+			Subtitle:     pageConfig.Subtitle,
+			Logo:         pageConfig.Logo,
+			FooterText:   pageConfig.FooterText,
+			ICP:          pageConfig.ICP,
+			FooterFormat: pageConfig.FooterFormat,
+		},
+		AnnouncementConfig: &navdoc.AnnouncementConfig{
+			Interval:      &announcementConfig.Interval,
+			Announcements: announcements,
+		},
+		Categories: categories,
+	}, nil
 }
 
-// GenerateNavJSON 从数据库生成nav.json文件
-// 每次数据变更后调用此函数更新静态JSON文件
-func GenerateNavJSON(db *sql.DB) error {
-	navJSONMutex.Lock()
-	defer navJSONMutex.Unlock()
-
-	var result []interface{}
+// BuildNavDocument 获取前台展示用的导航数据（nav.json 和 /api/nav）：
+// 页脚和公告内容输出过滤后的安全HTML，只包含当前处于展示期的公告
+// 读取页面配置或公告配置失败时使用默认配置，读取分类失败时不输出分类
+// 分类结构由 NAV_JSON_LAYOUT 配置决定（默认平铺，兼容只支持一级分类的前台页面）
+func BuildNavDocument(db *sql.DB) *navdoc.Document {
+	doc := &navdoc.Document{Version: navdoc.Version, Nested: NavJSONNested()}
 
 	// 1. 获取页面配置
 	pageConfig, err := getPageConfigForJSON(db)
 	if err != nil {
 		log.Printf("获取页面配置失败: %v", err)
 		// 使用默认配置
-		pageConfig = &NavJSONPageConfig{
-			Type:       "page_config",
-			Title:      "网址导航",
-			Subtitle:   "常用网址一键直达",
-			Logo:       "/static/logo.png",
-			FooterText: "",
-			ICP:        "",
+		pageConfig = &navdoc.PageConfig{
+			Title:    "网址导航",
+			Subtitle: "常用网址一键直达",
+			Logo:     "/static/logo.png",
 		}
 	}
-	result = append(result, pageConfig)
+	doc.PageConfig = pageConfig
 
 	// 2. 获取公告配置
 	announcementConfig, err := getAnnouncementConfigForJSON(db)
 	if err != nil {
 		log.Printf("获取公告配置失败: %v", err)
 		// 使用默认配置
-		announcementConfig = &NavJSONAnnouncementConfig{
-			ID:            "announcement_config",
-			Type:          "announcement_config",
-			Interval:      5000,
-			Announcements: []NavJSONAnnouncement{},
-		}
+		interval := 5000
+		announcementConfig = &navdoc.AnnouncementConfig{Interval: &interval}
 	}
-	result = append(result, announcementConfig)
+	doc.AnnouncementConfig = announcementConfig
 
 	// 3. 获取所有分类及其站点
 	categories, err := getCategoriesForJSON(db)
 	if err != nil {
 		log.Printf("获取分类失败: %v", err)
-	} else {
-		for _, cat := range categories {
-			result = append(result, cat)
-		}
 	}
+	doc.Categories = categories
 
-	// 3. 序列化为JSON（带缩进，便于阅读）
-	jsonData, err := json.MarshalIndent(result, "", "  ")
+	return doc
+}
+
+// GenerateNavJSON 从数据库生成nav.json文件
+// 每次数据变更后调用此函数更新静态JSON文件
+func GenerateNavJSON(db *sql.DB) error {
+	navJSONMutex.Lock()
+	defer navJSONMutex.Unlock()
+
+	// 序列化为JSON（带缩进，便于阅读）
+	jsonData, err := json.MarshalIndent(BuildNavDocument(db), "", "  ")
 	if err != nil {
 		return err
 	}
 
-	// 4. 写入文件
+	// 写入文件
 	outputPath := config.AppConfig.Nav.JSONPath
 	if outputPath == "" {
 		outputPath = "../static/nav.json"
@@ -126,11 +135,19 @@ func GenerateNavJSON(db *sql.DB) error {
 	}
 
 	log.Printf("nav.json 已更新: %s", outputPath)
+
+	// 公告可能有变化，通知调度器重新计算下一次检查时间
+	wakeAnnouncementScheduler()
 	return nil
 }
 
+// NavJSONNested nav.json 是否使用嵌套的分类结构
+func NavJSONNested() bool {
+	return config.AppConfig != nil && config.AppConfig.Nav.JSONLayout == "nested"
+}
+
 // getAnnouncementConfigForJSON 获取公告配置（用于JSON输出）
-func getAnnouncementConfigForJSON(db *sql.DB) (*NavJSONAnnouncementConfig, error) {
+func getAnnouncementConfigForJSON(db *sql.DB) (*navdoc.AnnouncementConfig, error) {
 	// 获取轮播间隔
 	var interval int
 	err := db.QueryRow("SELECT interval FROM announcement_config WHERE id = 1").Scan(&interval)
@@ -138,107 +155,69 @@ func getAnnouncementConfigForJSON(db *sql.DB) (*NavJSONAnnouncementConfig, error
 		interval = 5000
 	}
 
-	// 获取公告列表
-	rows, err := db.Query("SELECT id, timestamp, content FROM announcements ORDER BY id")
+	// 获取当前展示期内的公告（已按置顶、优先级排序）
+	active, err := models.GetActiveAnnouncements(db, time.Now())
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var announcements []NavJSONAnnouncement
-	for rows.Next() {
-		var ann NavJSONAnnouncement
-		if err := rows.Scan(&ann.ID, &ann.Timestamp, &ann.Content); err != nil {
-			continue
-		}
-		announcements = append(announcements, ann)
-	}
 
-	if announcements == nil {
-		announcements = []NavJSONAnnouncement{}
+	announcements := make([]navdoc.Announcement, 0, len(active))
+	for _, ann := range active {
+		announcements = append(announcements, navdoc.Announcement{
+			ID:        ann.ID,
+			Timestamp: ann.Timestamp,
+			// 输出过滤后的安全HTML
+			Content:  RenderRichText(ann.Content, ann.Format),
+			Severity: ann.Severity,
+			Pinned:   ann.Pinned,
+			ExpireAt: ann.ExpireAt,
+		})
 	}
 
-	return &NavJSONAnnouncementConfig{
-		ID:            "announcement_config",
-		Type:          "announcement_config",
-		Interval:      interval,
-		Announcements: announcements,
-	}, nil
+	return &navdoc.AnnouncementConfig{Interval: &interval, Announcements: announcements}, nil
 }
 
-// getCategoriesForJSON 获取分类列表（用于JSON输出）
-func getCategoriesForJSON(db *sql.DB) ([]NavJSONCategory, error) {
-	// 获取所有分类
-	rows, err := db.Query("SELECT id, id_str, classify, icon FROM categories ORDER BY sort_no, id")
+// getCategoriesForJSON 获取分类及其站点（用于JSON输出），按深度优先顺序平铺，子分类的 Parent 为上级分类的 _id
+func getCategoriesForJSON(db *sql.DB) ([]navdoc.Category, error) {
+	categories, err := models.GetAllCategories(db)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var categories []NavJSONCategory
-	for rows.Next() {
-		var id int
-		var cat NavJSONCategory
-		if err := rows.Scan(&id, &cat.ID, &cat.Classify, &cat.Icon); err != nil {
-			continue
-		}
+	idStrs := make(map[int]string, len(categories))
+	for _, cat := range categories {
+		idStrs[cat.ID] = cat.IDStr
+	}
 
-		// 获取该分类下的站点
-		sites, err := getSitesForJSON(db, id)
+	result := make([]navdoc.Category, 0, len(categories))
+	for _, cat := range categories {
+		sites, err := models.GetSitesByCategoryID(db, cat.ID)
 		if err != nil {
-			cat.Sites = []NavJSONSite{}
-		} else {
-			cat.Sites = sites
+			return nil, err
 		}
 
-		categories = append(categories, cat)
-	}
-
-	return categories, nil
-}
-
-// getSitesForJSON 获取站点列表（用于JSON输出）
-func getSitesForJSON(db *sql.DB, categoryID int) ([]NavJSONSite, error) {
-	rows, err := db.Query(
-		"SELECT name, href, description, logo FROM sites WHERE cat_id = ? ORDER BY sort_no, id",
-		categoryID,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var sites []NavJSONSite
-	for rows.Next() {
-		var site NavJSONSite
-		var desc, logo sql.NullString
-		if err := rows.Scan(&site.Name, &site.Href, &desc, &logo); err != nil {
-			continue
+		docSites := make([]navdoc.Site, 0, len(sites))
+		for _, site := range sites {
+			docSites = append(docSites, navdoc.Site{Name: site.Name, Href: site.Href, Desc: site.Desc, Logo: site.Logo, Tags: site.Tags})
 		}
-		site.Desc = desc.String
-		site.Logo = logo.String
-		sites = append(sites, site)
-	}
-
-	if sites == nil {
-		sites = []NavJSONSite{}
+		result = append(result, navdoc.Category{ID: cat.IDStr, Parent: idStrs[cat.ParentID], Classify: cat.Classify, Icon: cat.Icon, Sites: docSites})
 	}
 
-	return sites, nil
+	return result, nil
 }
 
 // getPageConfigForJSON 获取页面配置（用于JSON输出）
-func getPageConfigForJSON(db *sql.DB) (*NavJSONPageConfig, error) {
-	var config NavJSONPageConfig
-	config.Type = "page_config"
-
-	err := db.QueryRow("SELECT title, subtitle, logo, footer_text, icp FROM page_config WHERE id = 1").Scan(
-		&config.Title, &config.Subtitle, &config.Logo, &config.FooterText, &config.ICP,
-	)
-
+func getPageConfigForJSON(db *sql.DB) (*navdoc.PageConfig, error) {
+	cfg, err := models.GetPageConfig(db)
 	if err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	return &navdoc.PageConfig{
+		Title:    cfg.Title,
+		Subtitle: cfg.Subtitle,
+		Logo:     cfg.Logo,
+		// 输出过滤后的安全HTML
+		FooterText: RenderRichText(cfg.FooterText, cfg.FooterFormat),
+		ICP:        cfg.ICP,
+	}, nil
 }
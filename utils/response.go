@@ -7,6 +7,10 @@ type Response struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	// Warnings 操作成功但需要提示用户的信息（如重复链接）
+	Warnings []string `json:"warnings,omitempty"`
+	// Errors 字段级校验错误列表
+	Errors ValidationErrors `json:"errors,omitempty"`
 }
 
 // Success 成功响应
@@ -27,6 +31,16 @@ func SuccessWithMessage(c *gin.Context, message string, data interface{}) {
 	})
 }
 
+// SuccessWithWarnings 带警告信息的成功响应
+func SuccessWithWarnings(c *gin.Context, message string, data interface{}, warnings []string) {
+	c.JSON(200, Response{
+		Code:     0,
+		Message:  message,
+		Data:     data,
+		Warnings: warnings,
+	})
+}
+
 // Error 错误响应
 func Error(c *gin.Context, code int, message string) {
 	c.JSON(code, Response{
@@ -50,6 +64,15 @@ func NotFound(c *gin.Context, message string) {
 	Error(c, 404, message)
 }
 
+// ValidationFailed 400错误，附带字段级校验错误列表
+func ValidationFailed(c *gin.Context, errs ValidationErrors) {
+	c.JSON(400, Response{
+		Code:    400,
+		Message: "数据校验失败: " + errs[0].Field + " " + errs[0].Message,
+		Errors:  errs,
+	})
+}
+
 // InternalServerError 500错误
 func InternalServerError(c *gin.Context, message string) {
 	Error(c, 500, message)
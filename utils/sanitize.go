@@ -0,0 +1,387 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// 富文本格式
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+)
+
+// allowedTags 允许保留的HTML标签（链接、加粗/斜体、换行、段落）
+var allowedTags = map[string]bool{
+	"a":      true,
+	"b":      true,
+	"strong": true,
+	"i":      true,
+	"em":     true,
+	"u":      true,
+	"br":     true,
+	"p":      true,
+}
+
+// droppedTags 连同内容一起丢弃的标签
+var droppedTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"noscript": true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+}
+
+// IsValidRichTextFormat 判断富文本格式是否合法（空字符串视为html）
+func IsValidRichTextFormat(format string) bool {
+	return format == "" || format == FormatHTML || format == FormatMarkdown
+}
+
+// SanitizeRichText 返回适合存储的内容：html格式先过滤，markdown格式保留原文（输出时再转换）
+func SanitizeRichText(content, format string) string {
+	if format == FormatMarkdown {
+		return content
+	}
+	return SanitizeHTML(content)
+}
+
+// RenderRichText 将存储的内容按格式渲染为安全的HTML
+func RenderRichText(content, format string) string {
+	if format == FormatMarkdown {
+		return MarkdownToHTML(content)
+	}
+	return SanitizeHTML(content)
+}
+
+// SanitizeHTML 按白名单过滤HTML
+// 只保留链接、加粗、斜体、下划线、段落和换行，其他标签去掉但保留文字，脚本类标签连同内容删除
+func SanitizeHTML(input string) string {
+	if input == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(input))
+	var openTags []string
+	skipDepth := 0
+
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case xhtml.ErrorToken:
+			// 补全未闭合的标签
+			for i := len(openTags) - 1; i >= 0; i-- {
+				b.WriteString("</" + openTags[i] + ">")
+			}
+			return b.String()
+
+		case xhtml.TextToken:
+			if skipDepth == 0 {
+				b.WriteString(html.EscapeString(string(tokenizer.Text())))
+			}
+
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			token := tokenizer.Token()
+			tag := token.Data
+			if droppedTags[tag] {
+				if tt == xhtml.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if skipDepth > 0 || !allowedTags[tag] {
+				continue
+			}
+
+			if tag == "br" {
+				b.WriteString("<br>")
+				continue
+			}
+			if tag == "a" {
+				b.WriteString(sanitizeLinkTag(token))
+			} else {
+				b.WriteString("<" + tag + ">")
+			}
+			if tt == xhtml.StartTagToken {
+				openTags = append(openTags, tag)
+			} else {
+				b.WriteString("</" + tag + ">")
+			}
+
+		case xhtml.EndTagToken:
+			token := tokenizer.Token()
+			tag := token.Data
+			if droppedTags[tag] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if skipDepth > 0 || !allowedTags[tag] || tag == "br" {
+				continue
+			}
+
+			// 只闭合已打开的标签，保证输出结构完整
+			for i := len(openTags) - 1; i >= 0; i-- {
+				if openTags[i] != tag {
+					continue
+				}
+				for j := len(openTags) - 1; j >= i; j-- {
+					b.WriteString("</" + openTags[j] + ">")
+				}
+				openTags = openTags[:i]
+				break
+			}
+		}
+	}
+}
+
+// sanitizeLinkTag 生成安全的<a>开始标签，只保留合法的 href 和 title
+func sanitizeLinkTag(token xhtml.Token) string {
+	var href, title string
+	for _, attr := range token.Attr {
+		switch strings.ToLower(attr.Key) {
+		case "href":
+			href = strings.TrimSpace(attr.Val)
+		case "title":
+			title = attr.Val
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<a")
+	if href != "" && checkURL(href, []string{"http", "https", "mailto"}) == "" {
+		b.WriteString(` href="` + html.EscapeString(href) + `"`)
+		if !strings.HasPrefix(href, "/") {
+			b.WriteString(` target="_blank" rel="noopener noreferrer"`)
+		}
+	}
+	if title != "" {
+		b.WriteString(` title="` + html.EscapeString(title) + `"`)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+var (
+	mdLinkPattern   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBoldPattern   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalicPattern = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	mdParaSeparator = regexp.MustCompile(`\n{2,}`)
+	mdPlaceholder   = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// MarkdownToHTML 将简单的Markdown（链接、加粗、斜体、换行、段落）转换为安全的HTML
+func MarkdownToHTML(input string) string {
+	input = strings.ReplaceAll(input, "\r\n", "\n")
+	paragraphs := mdParaSeparator.Split(strings.TrimSpace(input), -1)
+
+	var parts []string
+	for _, para := range paragraphs {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+
+		lines := strings.Split(para, "\n")
+		for i, line := range lines {
+			lines[i] = renderMarkdownInline(strings.TrimSpace(line))
+		}
+		parts = append(parts, strings.Join(lines, "<br>"))
+	}
+
+	// 只有一段时不加<p>，便于在公告栏等单行区域内显示
+	if len(parts) > 1 {
+		for i := range parts {
+			parts[i] = "<p>" + parts[i] + "</p>"
+		}
+	}
+
+	// 转换结果再经过白名单过滤，确保链接地址合法
+	return SanitizeHTML(strings.Join(parts, ""))
+}
+
+// renderMarkdownInline 转换单行内的Markdown语法，原始文本先做HTML转义
+// 链接先替换为占位符，避免链接地址中的 _ 或 * 被当作强调语法
+func renderMarkdownInline(line string) string {
+	line = html.EscapeString(strings.ReplaceAll(line, "\x00", ""))
+
+	var links []string
+	line = mdLinkPattern.ReplaceAllStringFunc(line, func(m string) string {
+		sub := mdLinkPattern.FindStringSubmatch(m)
+		href := html.UnescapeString(sub[2])
+		links = append(links, `<a href="`+html.EscapeString(href)+`">`+renderMarkdownEmphasis(sub[1])+`</a>`)
+		return "\x00" + strconv.Itoa(len(links)-1) + "\x00"
+	})
+
+	line = renderMarkdownEmphasis(line)
+
+	return mdPlaceholder.ReplaceAllStringFunc(line, func(m string) string {
+		index, _ := strconv.Atoi(mdPlaceholder.FindStringSubmatch(m)[1])
+		return links[index]
+	})
+}
+
+// renderMarkdownEmphasis 转换加粗和斜体
+func renderMarkdownEmphasis(text string) string {
+	text = mdBoldPattern.ReplaceAllStringFunc(text, func(m string) string {
+		sub := mdBoldPattern.FindStringSubmatch(m)
+		return "<strong>" + sub[1] + sub[2] + "</strong>"
+	})
+	return mdItalicPattern.ReplaceAllStringFunc(text, func(m string) string {
+		sub := mdItalicPattern.FindStringSubmatch(m)
+		return "<em>" + sub[1] + sub[2] + "</em>"
+	})
+}
+
+// svgDroppedElements SVG中连同内容一起删除的元素
+var svgDroppedElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// SanitizeSVG 过滤SVG中的脚本：删除<script>、<foreignObject>等元素、on*事件属性、
+// javascript:等危险链接，以及DOCTYPE声明（防止实体注入）
+func SanitizeSVG(data []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+	decoder.Strict = false
+
+	var b bytes.Buffer
+	var open []string // 已打开的元素，结束标签必须与之对应
+	skipDepth := 0
+	hasRoot := false
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("SVG格式错误: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if len(open) == 0 {
+				if hasRoot || name != "svg" {
+					return nil, errors.New("SVG格式错误: 根元素必须是<svg>")
+				}
+				hasRoot = true
+			}
+			open = append(open, svgName(t.Name))
+			if skipDepth > 0 || svgDroppedElements[name] || isDangerousSVGAnimation(t) {
+				skipDepth++
+				continue
+			}
+
+			b.WriteString("<" + svgName(t.Name))
+			for _, attr := range t.Attr {
+				if !isSafeSVGAttr(attr) {
+					continue
+				}
+				b.WriteString(" " + svgName(attr.Name) + `="` + html.EscapeString(attr.Value) + `"`)
+			}
+			b.WriteString(">")
+
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != svgName(t.Name) {
+				return nil, fmt.Errorf("SVG格式错误: 结束标签</%s>不匹配", svgName(t.Name))
+			}
+			open = open[:len(open)-1]
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			b.WriteString("</" + svgName(t.Name) + ">")
+
+		case xml.CharData:
+			if skipDepth == 0 && len(open) > 0 {
+				b.WriteString(html.EscapeString(string(t)))
+			}
+
+		case xml.ProcInst:
+			// 只保留XML声明
+			if t.Target == "xml" && len(open) == 0 && b.Len() == 0 {
+				b.WriteString("<?xml " + string(t.Inst) + "?>")
+			}
+		}
+		// 注释和DOCTYPE等指令直接丢弃
+	}
+
+	if !hasRoot {
+		return nil, errors.New("SVG格式错误: 缺少<svg>元素")
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("SVG格式错误: <%s>没有结束标签", open[len(open)-1])
+	}
+	return b.Bytes(), nil
+}
+
+// svgName 还原带前缀的元素或属性名（如 xlink:href）
+func svgName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// isSafeSVGAttr 判断SVG属性是否安全
+func isSafeSVGAttr(attr xml.Attr) bool {
+	name := strings.ToLower(attr.Name.Local)
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+
+	value := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+	switch name {
+	case "href", "src", "action", "formaction":
+		// 只允许文档内引用和内嵌位图
+		return strings.HasPrefix(value, "#") ||
+			strings.HasPrefix(value, "data:image/png") ||
+			strings.HasPrefix(value, "data:image/jpeg") ||
+			strings.HasPrefix(value, "data:image/gif") ||
+			strings.HasPrefix(value, "data:image/webp")
+	case "style":
+		return !strings.Contains(value, "javascript:") && !strings.Contains(value, "expression(")
+	}
+	return !strings.Contains(value, "javascript:")
+}
+
+// isDangerousSVGAnimation 判断是否是修改链接或事件属性的动画元素（可借此注入javascript:链接）
+func isDangerousSVGAnimation(t xml.StartElement) bool {
+	switch strings.ToLower(t.Name.Local) {
+	case "set", "animate":
+	default:
+		return false
+	}
+	for _, attr := range t.Attr {
+		if strings.ToLower(attr.Name.Local) != "attributename" {
+			continue
+		}
+		target := strings.ToLower(attr.Value)
+		if i := strings.Index(target, ":"); i >= 0 {
+			target = target[i+1:]
+		}
+		return target == "href" || strings.HasPrefix(target, "on")
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"allowed tags", `<p>a<b>b</b><strong>s</strong><i>i</i><em>e</em><u>u</u><br/>c</p>`, `<p>a<b>b</b><strong>s</strong><i>i</i><em>e</em><u>u</u><br>c</p>`},
+		{"other tags keep text", `<div><span>t</span><img src=x></div>`, `t`},
+		{"text is escaped", `a < b & "c"`, `a &lt; b &amp; &#34;c&#34;`},
+		{"unclosed tags are closed", `<p><b>x`, `<p><b>x</b></p>`},
+		{"stray end tags", `</b>x</p>`, `x`},
+
+		// 链接
+		{"https link", `<a href="https://example.com/?a=1&amp;b=2">x</a>`, `<a href="https://example.com/?a=1&amp;b=2" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"site path link", `<a href="/uploads/files/a.pdf" title="t">x</a>`, `<a href="/uploads/files/a.pdf" title="t">x</a>`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"mixed case scheme", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"leading space", `<a href="  javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"decimal entity scheme", `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"hex entity scheme", `<a href="&#x6A;avascript&colon;alert(1)">x</a>`, `<a>x</a>`},
+		{"entity tab in scheme", `<a href="java&#x09;script:alert(1)">x</a>`, `<a>x</a>`},
+		{"data href", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, `<a>x</a>`},
+		{"protocol relative", `<a href="//evil.example.com">x</a>`, `<a>x</a>`},
+		{"title is escaped", `<a title="&quot;><script>alert(1)</script>">x</a>`, `<a title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">x</a>`},
+
+		// 事件属性
+		{"on attributes on a", `<a href="https://example.com" onclick="alert(1)" style="x">x</a>`, `<a href="https://example.com" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"on attributes on allowed tags", `<b onmouseover="alert(1)">b</b><p onclick=alert(1) class="c">p</p><br onload=alert(1)>`, `<b>b</b><p>p</p><br>`},
+
+		// 脚本类标签
+		{"script", `a<script>alert(1)</script>b`, `ab`},
+		{"script inside dropped tag", `<object><script>alert(1)</script><b>x</b></object>y`, `y`},
+		{"script inside raw text tag", `<iframe><script>alert(1)</script></iframe>y`, `y`},
+		{"script inside unclosed tag", `<b><script>alert(1)`, `<b></b>`},
+		{"script inside unclosed dropped tag", `<template><p>x<script>alert(1)</script>`, ``},
+		{"script inside noscript", `<p>a<noscript><p>b</p><script>alert(1)</script></noscript>c`, `<p>ac</p>`},
+		{"split script tag", `<scr<script>ipt>alert(1)</script>`, `ipt&gt;alert(1)`},
+		{"style breakout", `<style><img src="</style><img src=x onerror=alert(1)>">`, `&#34;&gt;`},
+		{"style end without start", `</style><script>alert(1)</script>x`, `x`},
+		{"textarea breakout", `<textarea></textarea><a href="javascript:alert(1)" onclick="x">x</a>`, `<a>x</a>`},
+		{"unclosed textarea", `<textarea><b onclick=alert(1)>x</b>`, ``},
+		{"svg in html", `<svg onload=alert(1)><script>alert(1)</script></svg>`, ``},
+	}
+	for _, tt := range tests {
+		if got := SanitizeHTML(tt.input); got != tt.want {
+			t.Errorf("%s: SanitizeHTML(%q)\n got %q\nwant %q", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"emphasis", "**b** and *i* and __u__ and _e_", `<strong>b</strong> and <em>i</em> and <strong>u</strong> and <em>e</em>`},
+		{"line break", "a\nb", `a<br>b`},
+		{"paragraphs", "a\n\nb", `<p>a</p><p>b</p>`},
+		{"link", "[ok](https://example.com/a_b_c)", `<a href="https://example.com/a_b_c" target="_blank" rel="noopener noreferrer">ok</a>`},
+		{"raw html is escaped", "<script>alert(1)</script><b onclick=x>", `&lt;script&gt;alert(1)&lt;/script&gt;&lt;b onclick=x&gt;`},
+		{"javascript link", "[x](javascript:alert(1))", `<a>x</a>)`},
+		{"mixed case javascript link", "[x](JaVaScRiPt:alert%281%29)", `<a>x</a>`},
+		{"entity javascript link", "[x](&#106;avascript:alert%281%29)", `<a>x</a>`},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", `<a>x</a>`},
+		{"quote in link", `[x](https://e.com"onclick="alert(1))`, `<a>x</a>)`},
+		{"emphasis in link text", "[**x**](https://e.com)", `<a href="https://e.com" target="_blank" rel="noopener noreferrer"><strong>x</strong></a>`},
+	}
+	for _, tt := range tests {
+		if got := MarkdownToHTML(tt.input); got != tt.want {
+			t.Errorf("%s: MarkdownToHTML(%q)\n got %q\nwant %q", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeSVG(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{
+			"keeps shapes",
+			`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><circle r="1" fill="red"/></svg>`,
+			`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><circle r="1" fill="red"></circle></svg>`,
+		},
+		{
+			"script",
+			`<svg><script>alert(1)</script><script><![CDATA[alert(2)]]></script><rect/></svg>`,
+			`<svg><rect></rect></svg>`,
+		},
+		{
+			"event attributes",
+			`<svg onload="alert(1)" width="10"><rect ONCLICK="alert(1)" onmouseover="x" width="1"/></svg>`,
+			`<svg width="10"><rect width="1"></rect></svg>`,
+		},
+		{
+			"links",
+			`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="https://evil.example.com/x.svg#a"/><use xlink:href="#local"/><image href="data:image/png;base64,AAAA"/><image href="data:image/svg+xml;base64,AAAA"/><a href="javascript:alert(1)"><text>t</text></a><a xlink:href="&#106;avascript:alert(1)"/></svg>`,
+			`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use></use><use xlink:href="#local"></use><image href="data:image/png;base64,AAAA"></image><image></image><a><text>t</text></a><a></a></svg>`,
+		},
+		{
+			"animations",
+			`<svg><a><set attributeName="href" to="javascript:alert(1)"/><animate attributeName="xlink:href" values="javascript:alert(1)"/><set attributeName="onclick" to="alert(1)"/><animate attributeName="opacity" from="0" to="1"/></a></svg>`,
+			`<svg><a><animate attributeName="opacity" from="0" to="1"></animate></a></svg>`,
+		},
+		{
+			"foreign object",
+			`<svg><foreignObject><body xmlns="http://www.w3.org/1999/xhtml"><iframe src="javascript:alert(1)"/></body></foreignObject></svg>`,
+			`<svg></svg>`,
+		},
+		{
+			"style",
+			`<svg><rect style="background:url(javascript:alert(1))"/><rect style="fill:red"/></svg>`,
+			`<svg><rect></rect><rect style="fill:red"></rect></svg>`,
+		},
+		{
+			"doctype and comments",
+			`<!DOCTYPE svg [<!ENTITY x "y">]><svg><!-- c --><text>&amp;</text></svg>`,
+			`<svg><text>&amp;</text></svg>`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := SanitizeSVG([]byte(tt.input))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: SanitizeSVG\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{
+		``,
+		`<html><script>alert(1)</script></html>`,
+		`<svg></svg><svg></svg>`,
+		`<svg><rect></svg>`,
+		`<svg><script></a>alert(1)</script></svg>`,
+		`<svg><g>`,
+	} {
+		if got, err := SanitizeSVG([]byte(input)); err == nil {
+			t.Errorf("SanitizeSVG(%q) = %s, want error", input, got)
+		} else if !strings.HasPrefix(err.Error(), "SVG格式错误") {
+			t.Errorf("SanitizeSVG(%q) error = %v", input, err)
+		}
+	}
+}
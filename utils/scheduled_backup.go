@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"nav-admin/config"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// storedBackupMu 保证同一时间只有一个备份任务（定时备份和手动创建可能同时触发）
+var storedBackupMu sync.Mutex
+
+// storedBackupPattern 备份文件名格式，同时用于防止路径穿越
+var storedBackupPattern = regexp.MustCompile(`^nav_backup_(\d{8}_\d{6})\.zip$`)
+
+// storedBackupTimeLayout 备份文件名中的时间格式
+const storedBackupTimeLayout = "20060102_150405"
+
+// ErrInvalidBackupName 备份文件名不符合格式
+var ErrInvalidBackupName = errors.New("无效的备份文件名")
+
+// StoredBackup 保存在 BACKUP_PATH 中的备份
+type StoredBackup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"-"`
+	Created   string    `json:"created_at"`
+}
+
+// CreateStoredBackup 创建一份备份保存到 BACKUP_PATH：
+// 先用 VACUUM INTO 生成数据库快照，从快照读取导出数据（保证数据一致），再打包快照、数据和上传文件
+func CreateStoredBackup(db *sql.DB) (*StoredBackup, error) {
+	storedBackupMu.Lock()
+	defer storedBackupMu.Unlock()
+
+	dir := config.AppConfig.Backup.Path
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("nav_backup_%s.zip", now.Format(storedBackupTimeLayout))
+	finalPath := filepath.Join(dir, name)
+	if _, err := os.Stat(finalPath); err == nil {
+		return nil, fmt.Errorf("备份 %s 已存在，请稍后再试", name)
+	}
+
+	// 临时目录以点开头，不会出现在备份列表中
+	tmpDir, err := os.MkdirTemp(dir, ".backup-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshot := filepath.Join(tmpDir, BackupDatabaseFile)
+	if _, err := db.Exec("VACUUM INTO ?", snapshot); err != nil {
+		return nil, fmt.Errorf("生成数据库快照失败: %w", err)
+	}
+
+	entries, contents, err := collectSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, BackupEntry{Name: BackupDatabaseFile, File: snapshot})
+
+	tmpZip := filepath.Join(tmpDir, name)
+	f, err := os.Create(tmpZip)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := WriteBackup(f, entries, contents); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpZip, finalPath); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(finalPath)
+	if err != nil {
+		return nil, err
+	}
+	return &StoredBackup{Name: name, Size: info.Size(), CreatedAt: now, Created: now.Format("2006-01-02 15:04:05")}, nil
+}
+
+// collectSnapshot 从数据库快照读取备份数据（包含用户）
+func collectSnapshot(snapshot string) ([]BackupEntry, []string, error) {
+	snapDB, err := sql.Open("sqlite", snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer snapDB.Close()
+	return CollectBackup(snapDB, true)
+}
+
+// ListStoredBackups 列出 BACKUP_PATH 中的备份（按时间从新到旧）
+func ListStoredBackups() ([]StoredBackup, error) {
+	entries, err := os.ReadDir(config.AppConfig.Backup.Path)
+	if os.IsNotExist(err) {
+		return []StoredBackup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []StoredBackup{}
+	for _, entry := range entries {
+		match := storedBackupPattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		createdAt, err := time.ParseInLocation(storedBackupTimeLayout, match[1], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, StoredBackup{
+			Name:      entry.Name(),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+			Created:   createdAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// StoredBackupPath 返回备份文件的本地路径，文件名不合法时返回 ErrInvalidBackupName，不存在时返回 os.ErrNotExist
+func StoredBackupPath(name string) (string, error) {
+	if !storedBackupPattern.MatchString(name) {
+		return "", ErrInvalidBackupName
+	}
+	p := filepath.Join(config.AppConfig.Backup.Path, name)
+	if _, err := os.Stat(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// DeleteStoredBackup 删除备份文件
+func DeleteStoredBackup(name string) error {
+	p, err := StoredBackupPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// PruneStoredBackups 按保留策略删除多余的备份，返回删除的文件名
+// 分别保留最近 BACKUP_KEEP_DAILY 天、BACKUP_KEEP_WEEKLY 周、BACKUP_KEEP_MONTHLY 个月中每个周期最新的一份，
+// 最新的一份备份总是保留；三项都为0时不清理
+func PruneStoredBackups() ([]string, error) {
+	cfg := config.AppConfig.Backup
+	if cfg.KeepDaily == 0 && cfg.KeepWeekly == 0 && cfg.KeepMonthly == 0 {
+		return nil, nil
+	}
+
+	storedBackupMu.Lock()
+	defer storedBackupMu.Unlock()
+
+	backups, err := ListStoredBackups()
+	if err != nil || len(backups) == 0 {
+		return nil, err
+	}
+
+	keep := map[string]bool{backups[0].Name: true}
+	keepPeriods := func(n int, period func(time.Time) string) {
+		seen := map[string]bool{}
+		for _, b := range backups {
+			p := period(b.CreatedAt)
+			if seen[p] {
+				continue
+			}
+			if len(seen) >= n {
+				break
+			}
+			seen[p] = true
+			keep[b.Name] = true
+		}
+	}
+	keepPeriods(cfg.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	keepPeriods(cfg.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	keepPeriods(cfg.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") })
+
+	deleted := []string{}
+	for _, b := range backups {
+		if keep[b.Name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(cfg.Path, b.Name)); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, b.Name)
+	}
+	return deleted, nil
+}
+
+// StartBackupScheduler 按 BACKUP_SCHEDULE 定时创建备份并按保留策略清理，未设置时不启动
+func StartBackupScheduler(db *sql.DB) error {
+	expr := config.AppConfig.Backup.Schedule
+	if expr == "" {
+		return nil
+	}
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			next := schedule.Next(time.Now())
+			if next.IsZero() {
+				log.Printf("定时备份表达式 %q 不会再触发，定时备份已停止", expr)
+				return
+			}
+			time.Sleep(time.Until(next))
+
+			backup, err := CreateStoredBackup(db)
+			if err != nil {
+				log.Printf("定时备份失败: %v", err)
+				continue
+			}
+			log.Printf("定时备份完成: %s（%d 字节）", backup.Name, backup.Size)
+
+			if deleted, err := PruneStoredBackups(); err != nil {
+				log.Printf("清理旧备份失败: %v", err)
+			} else if len(deleted) > 0 {
+				log.Printf("已按保留策略删除 %d 个旧备份", len(deleted))
+			}
+		}
+	}()
+	log.Printf("已启用定时备份: %s，保存到 %s", expr, config.AppConfig.Backup.Path)
+	return nil
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"nav-admin/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneStoredBackups(t *testing.T) {
+	tests := []struct {
+		name                   string
+		daily, weekly, monthly int
+		backups                []string // 备份时间 YYYY-MM-DD HH:MM
+		kept                   []string
+	}{
+		{
+			name:  "daily keeps newest per day",
+			daily: 3,
+			backups: []string{
+				"2024-03-10 15:00", "2024-03-10 03:00", "2024-03-09 15:00",
+				"2024-03-09 03:00", "2024-03-08 03:00", "2024-03-07 03:00",
+			},
+			kept: []string{"2024-03-10 15:00", "2024-03-09 15:00", "2024-03-08 03:00"},
+		},
+		{
+			// 2024-03-04（周一）到 03-10 为第10周，02-26 到 03-03 为第9周
+			name:   "weekly keeps newest per ISO week",
+			weekly: 2,
+			backups: []string{
+				"2024-03-10 03:00", "2024-03-04 03:00", "2024-03-03 03:00",
+				"2024-02-26 03:00", "2024-02-25 03:00", "2024-02-20 03:00",
+			},
+			kept: []string{"2024-03-10 03:00", "2024-03-03 03:00"},
+		},
+		{
+			// 2024-12-30 属于 2025 年第1周
+			name:    "weekly across year boundary",
+			weekly:  2,
+			backups: []string{"2025-01-02 03:00", "2024-12-30 03:00", "2024-12-29 03:00", "2024-12-27 03:00"},
+			kept:    []string{"2025-01-02 03:00", "2024-12-29 03:00"},
+		},
+		{
+			name:    "monthly keeps newest per month",
+			monthly: 2,
+			backups: []string{
+				"2024-03-01 10:00", "2024-02-29 10:00", "2024-02-01 10:00",
+				"2024-01-31 10:00", "2024-01-15 10:00",
+			},
+			kept: []string{"2024-03-01 10:00", "2024-02-29 10:00"},
+		},
+		{
+			name:  "policies combined",
+			daily: 1, weekly: 1, monthly: 3,
+			backups: []string{
+				"2024-03-10 15:00", "2024-03-10 03:00", "2024-03-09 03:00",
+				"2024-02-29 03:00", "2024-02-10 03:00", "2024-01-31 03:00", "2023-12-31 03:00",
+			},
+			kept: []string{"2024-03-10 15:00", "2024-02-29 03:00", "2024-01-31 03:00"},
+		},
+		{
+			name:    "all zero keeps everything",
+			backups: []string{"2024-03-10 03:00", "2024-03-09 03:00", "2023-01-01 03:00"},
+			kept:    []string{"2024-03-10 03:00", "2024-03-09 03:00", "2023-01-01 03:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config.AppConfig = &config.Config{Backup: config.BackupConfig{
+				Path:        dir,
+				KeepDaily:   tt.daily,
+				KeepWeekly:  tt.weekly,
+				KeepMonthly: tt.monthly,
+			}}
+			for _, b := range tt.backups {
+				writeTestFile(t, filepath.Join(dir, testBackupName(t, b)))
+			}
+			// 不符合备份文件名格式的文件不受影响
+			writeTestFile(t, filepath.Join(dir, "other.zip"))
+			writeTestFile(t, filepath.Join(dir, ".backup-123", "nav_backup_20000101_000000.zip"))
+
+			deleted, err := PruneStoredBackups()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deleted)+len(tt.kept) != len(tt.backups) {
+				t.Errorf("deleted %v", deleted)
+			}
+
+			want := []string{".backup-123", "other.zip"}
+			for _, k := range tt.kept {
+				want = append(want, testBackupName(t, k))
+			}
+			sort.Strings(want)
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("remaining files:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func testBackupName(t *testing.T, s string) string {
+	t.Helper()
+	v, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "nav_backup_" + v.Format(storedBackupTimeLayout) + ".zip"
+}
+
+func writeTestFile(t *testing.T, name string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte("zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"nav-admin/models"
+	"strings"
+	"time"
+)
+
+// announcementCheckInterval 定时检查公告状态的最长间隔
+const announcementCheckInterval = time.Minute
+
+// announcementWakeup 数据变更后唤醒调度器重新计算下一次检查时间
+var announcementWakeup = make(chan struct{}, 1)
+
+// wakeAnnouncementScheduler 通知调度器数据已变更（不阻塞）
+func wakeAnnouncementScheduler() {
+	select {
+	case announcementWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// StartAnnouncementScheduler 启动公告调度器
+// 公告到达发布时间或过期时自动重新生成nav.json，无需管理员操作
+func StartAnnouncementScheduler(db *sql.DB) {
+	go func() {
+		lastState := activeAnnouncementState(db)
+		for {
+			select {
+			case <-time.After(nextAnnouncementCheck(db)):
+			case <-announcementWakeup:
+				// nav.json 刚刚生成过，只需记录当前状态并重新计算等待时间
+				lastState = activeAnnouncementState(db)
+				continue
+			}
+
+			state := activeAnnouncementState(db)
+			if state == lastState {
+				continue
+			}
+			lastState = state
+
+			log.Println("公告展示状态发生变化，重新生成nav.json")
+			if err := GenerateNavJSON(db); err != nil {
+				log.Printf("生成nav.json失败: %v", err)
+			}
+		}
+	}()
+}
+
+// nextAnnouncementCheck 计算距离下一次检查的等待时间
+// 有公告即将开始或结束时在该时间点检查，否则按固定间隔检查
+func nextAnnouncementCheck(db *sql.DB) time.Duration {
+	now := time.Now()
+	next, err := models.GetNextAnnouncementTransition(db, now)
+	if err != nil || next.IsZero() {
+		return announcementCheckInterval
+	}
+
+	// 时间精度为秒，多等待一秒确保越过边界
+	wait := next.Sub(now) + time.Second
+	if wait > announcementCheckInterval {
+		return announcementCheckInterval
+	}
+	return wait
+}
+
+// activeAnnouncementState 返回当前展示中的公告ID列表，用于判断是否需要重新生成
+func activeAnnouncementState(db *sql.DB) string {
+	announcements, err := models.GetActiveAnnouncements(db, time.Now())
+	if err != nil {
+		return ""
+	}
+
+	ids := make([]string, 0, len(announcements))
+	for _, ann := range announcements {
+		ids = append(ids, fmt.Sprint(ann.ID))
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"nav-admin/navdoc"
+	"strconv"
+	"strings"
+)
+
+// SiteSheetColumns 站点表格（CSV/XLSX）的列
+var SiteSheetColumns = []string{"category", "name", "href", "desc", "logo", "sort"}
+
+// siteSheetAliases 导入时可识别的表头（不区分大小写），对应 SiteSheetColumns 中的列
+var siteSheetAliases = map[string]string{
+	"category": "category", "classify": "category", "分类": "category", "分类名称": "category",
+	"name": "name", "名称": "name", "站点名称": "name",
+	"href": "href", "url": "href", "链接": "href", "网址": "href",
+	"desc": "desc", "description": "desc", "描述": "desc",
+	"logo": "logo", "icon": "logo", "图标": "logo",
+	"sort": "sort", "排序": "sort",
+}
+
+// siteSheetRequired 导入时必须有的列
+var siteSheetRequired = []string{"category", "name", "href"}
+
+// SiteSheetRow 站点表格中的一行，Line 为在文件中的行号
+type SiteSheetRow struct {
+	Line     int
+	Category string
+	Name     string
+	Href     string
+	Desc     string
+	Logo     string
+	Sort     string
+}
+
+// SiteSheet 导入的站点表格，Columns 为表头中出现的列（没有的列更新时保持原值）
+type SiteSheet struct {
+	Columns map[string]bool
+	Rows    []SiteSheetRow
+}
+
+// ReadCSVSheet 读取CSV文件（忽略 UTF-8 BOM 和空行，导出时为防止公式注入加的单引号会被去掉）
+func ReadCSVSheet(r io.Reader) ([]SheetRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	var rows []SheetRow
+	first := true
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, errors.New("第" + strconv.Itoa(parseErr.Line) + "行CSV格式错误")
+			}
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		if first {
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			first = false
+		}
+		empty := true
+		for i, cell := range record {
+			record[i] = unquoteCSVCell(cell)
+			if strings.TrimSpace(cell) != "" {
+				empty = false
+			}
+		}
+		if !empty {
+			rows = append(rows, SheetRow{Line: line, Cells: record})
+		}
+	}
+}
+
+// ParseSiteSheet 按表头解析站点表格，第一行为表头
+func ParseSiteSheet(rows []SheetRow) (*SiteSheet, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("表格为空")
+	}
+
+	sheet := &SiteSheet{Columns: map[string]bool{}}
+	index := map[string]int{}
+	for i, cell := range rows[0].Cells {
+		column, ok := siteSheetAliases[strings.ToLower(strings.TrimSpace(cell))]
+		if !ok || sheet.Columns[column] {
+			continue
+		}
+		sheet.Columns[column] = true
+		index[column] = i
+	}
+	var missing []string
+	for _, column := range siteSheetRequired {
+		if !sheet.Columns[column] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.New("第" + strconv.Itoa(rows[0].Line) + "行表头缺少必需的列: " + strings.Join(missing, ", "))
+	}
+
+	cell := func(row SheetRow, column string) string {
+		i, ok := index[column]
+		if !ok || i >= len(row.Cells) {
+			return ""
+		}
+		return strings.TrimSpace(row.Cells[i])
+	}
+	for _, row := range rows[1:] {
+		sheet.Rows = append(sheet.Rows, SiteSheetRow{
+			Line:     row.Line,
+			Category: cell(row, "category"),
+			Name:     cell(row, "name"),
+			Href:     cell(row, "href"),
+			Desc:     cell(row, "desc"),
+			Logo:     cell(row, "logo"),
+			Sort:     cell(row, "sort"),
+		})
+	}
+	return sheet, nil
+}
+
+// SiteSheetRecords 按 SiteSheetColumns 生成站点表格（含表头），sort 为站点在分类中的位置（从1开始）
+func SiteSheetRecords(doc *navdoc.Document) [][]string {
+	records := [][]string{append([]string(nil), SiteSheetColumns...)}
+	for _, cat := range doc.Categories {
+		for i, site := range cat.Sites {
+			records = append(records, []string{cat.Classify, site.Name, site.Href, site.Desc, site.Logo, strconv.Itoa(i + 1)})
+		}
+	}
+	return records
+}
+
+// WriteSitesCSV 导出为CSV，每行一个站点（列见 SiteSheetColumns）
+// 文件以 UTF-8 BOM 开头，便于 Excel 正确识别中文
+func WriteSitesCSV(w io.Writer, doc *navdoc.Document) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	for _, record := range SiteSheetRecords(doc) {
+		for i := range record {
+			record[i] = csvSafeCell(record[i])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteSitesXLSX 导出为xlsx表格，每行一个站点（列见 SiteSheetColumns）
+func WriteSitesXLSX(w io.Writer, doc *navdoc.Document) error {
+	return WriteXLSX(w, "Sites", SiteSheetRecords(doc))
+}
+
+// csvFormulaPrefixes 在电子表格中会被当作公式的开头字符
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvSafeCell 以 = + - @ 开头的单元格在电子表格中会被当作公式执行，前面加单引号防止公式注入
+// 本身以单引号开头、导入时会被 unquoteCSVCell 去掉单引号的内容也加一个单引号，保证导出后能原样导入
+func csvSafeCell(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaPrefixes, rune(s[0])) || s != unquoteCSVCell(s) {
+		return "'" + s
+	}
+	return s
+}
+
+// unquoteCSVCell 去掉 csvSafeCell 加的单引号
+func unquoteCSVCell(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes+"'", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
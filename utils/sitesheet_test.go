@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"nav-admin/navdoc"
+	"strings"
+	"testing"
+)
+
+// testSheetDoc 导出测试用的导航数据，包含需要转义的内容
+var testSheetDoc = &navdoc.Document{Categories: []navdoc.Category{
+	{Classify: "常用", Sites: []navdoc.Site{
+		{Name: "Google", Href: "https://www.google.com/", Desc: "搜索, \"引号\"", Logo: "/uploads/logos/g.png"},
+		{Name: "=HYPERLINK(\"https://evil.example.com\")", Href: "https://a.example.com/", Desc: "多行\n描述"},
+		{Name: "'=1+1", Href: "https://b.example.com/", Desc: "-3", Logo: "@x"},
+	}},
+	{Classify: "'引号开头", Sites: []navdoc.Site{
+		{Name: "007", Href: "https://c.example.com/?a=1&b=<2>", Desc: "  前后空格  "},
+	}},
+}}
+
+// testSheetRows 期望导入 testSheetDoc 导出的表格得到的行（内容去掉首尾空白）
+var testSheetRows = []SiteSheetRow{
+	{Category: "常用", Name: "Google", Href: "https://www.google.com/", Desc: "搜索, \"引号\"", Logo: "/uploads/logos/g.png", Sort: "1"},
+	{Category: "常用", Name: "=HYPERLINK(\"https://evil.example.com\")", Href: "https://a.example.com/", Desc: "多行\n描述", Sort: "2"},
+	{Category: "常用", Name: "'=1+1", Href: "https://b.example.com/", Desc: "-3", Logo: "@x", Sort: "3"},
+	{Category: "'引号开头", Name: "007", Href: "https://c.example.com/?a=1&b=<2>", Desc: "前后空格", Sort: "1"},
+}
+
+func checkSheetRows(t *testing.T, name string, got, want []SiteSheetRow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: %d rows, want %d: %+v", name, len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: row %d\n got %+v\nwant %+v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestSitesCSVRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSitesCSV(&buf, testSheetDoc); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "\ufeffcategory,name,href,desc,logo,sort\n") {
+		t.Errorf("header = %q", strings.SplitN(out, "\n", 2)[0])
+	}
+	// 公式开头的单元格加了单引号
+	for _, cell := range []string{`"'=HYPERLINK(""https://evil.example.com"")"`, `''=1+1`, `,'-3,'@x,`} {
+		if !strings.Contains(out, cell) {
+			t.Errorf("exported CSV does not contain %s:\n%s", cell, out)
+		}
+	}
+
+	rows, err := ReadCSVSheet(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := ParseSiteSheet(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 多行的描述占两行，之后的行号顺延
+	want := append([]SiteSheetRow(nil), testSheetRows...)
+	for i, line := range []int{2, 3, 5, 6} {
+		want[i].Line = line
+	}
+	checkSheetRows(t, "csv", sheet.Rows, want)
+	for _, column := range SiteSheetColumns {
+		if !sheet.Columns[column] {
+			t.Errorf("column %s not found", column)
+		}
+	}
+}
+
+func TestCSVSafeCell(t *testing.T) {
+	tests := []struct{ value, exported string }{
+		{"", ""},
+		{"plain", "plain"},
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"'", "'"},
+		{"'abc", "'abc"},
+		{"'=1", "''=1"},
+		{"''", "'''"},
+		{"a=1", "a=1"},
+	}
+	for _, tt := range tests {
+		if got := csvSafeCell(tt.value); got != tt.exported {
+			t.Errorf("csvSafeCell(%q) = %q, want %q", tt.value, got, tt.exported)
+		}
+		if got := unquoteCSVCell(tt.exported); got != tt.value {
+			t.Errorf("unquoteCSVCell(%q) = %q, want %q", tt.exported, got, tt.value)
+		}
+	}
+}
+
+func TestReadCSVSheet(t *testing.T) {
+	rows, err := ReadCSVSheet(strings.NewReader("\ufeffname,href\n\n,\n\"a\nb\",x\nc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 空行和只有空单元格的行不返回，列数可以不同
+	if len(rows) != 3 || rows[0].Line != 1 || rows[0].Cells[0] != "name" ||
+		rows[1].Line != 4 || rows[1].Cells[0] != "a\nb" || rows[2].Line != 6 || len(rows[2].Cells) != 1 {
+		t.Errorf("rows = %+v", rows)
+	}
+
+	// Excel 等生成的不规范引号按原样读取
+	rows, err = ReadCSVSheet(strings.NewReader("name,href\n5\"屏,x\n"))
+	if err != nil || len(rows) != 2 || rows[1].Cells[0] != "5\"屏" {
+		t.Errorf("bare quote: %+v, %v", rows, err)
+	}
+}
+
+func TestParseSiteSheet(t *testing.T) {
+	sheet, err := ParseSiteSheet([]SheetRow{
+		{Line: 3, Cells: []string{"网址", "备注", " 名称 ", "Classify", "url"}},
+		{Line: 4, Cells: []string{" https://a.example.com ", "x", "A", "分类"}},
+		{Line: 7, Cells: []string{"https://b.example.com", "", "B"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不认识的列忽略，重复的列使用第一个，缺少的单元格为空
+	if !sheet.Columns["href"] || !sheet.Columns["name"] || !sheet.Columns["category"] || sheet.Columns["desc"] || sheet.Columns["sort"] {
+		t.Errorf("columns = %v", sheet.Columns)
+	}
+	checkSheetRows(t, "aliases", sheet.Rows, []SiteSheetRow{
+		{Line: 4, Category: "分类", Name: "A", Href: "https://a.example.com"},
+		{Line: 7, Name: "B", Href: "https://b.example.com"},
+	})
+
+	if _, err := ParseSiteSheet(nil); err == nil || err.Error() != "表格为空" {
+		t.Errorf("empty sheet: err = %v", err)
+	}
+	if _, err := ParseSiteSheet([]SheetRow{{Line: 2, Cells: []string{"name", "desc"}}}); err == nil || err.Error() != "第2行表头缺少必需的列: category, href" {
+		t.Errorf("missing columns: err = %v", err)
+	}
+}
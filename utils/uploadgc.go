@@ -0,0 +1,439 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"nav-admin/config"
+	"nav-admin/models"
+	"nav-admin/storage"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// uploadGCMu 保证同一时间只有一个清理任务（定时任务和手动清理可能同时触发）
+var uploadGCMu sync.Mutex
+
+// uploadSubDirs 参与清理的上传子目录
+var uploadSubDirs = []string{"logos", "files"}
+
+// OrphanFile 未被引用的上传文件
+type OrphanFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"mod_time"`
+	Reason  string `json:"reason,omitempty"` // 未被引用但不清理的原因（只用于 Kept）
+}
+
+// UploadGCReport 上传文件清理报告
+type UploadGCReport struct {
+	DryRun      bool         `json:"dry_run"`
+	Scanned     int          `json:"scanned"`     // 扫描的文件数
+	Orphans     []OrphanFile `json:"orphans"`     // 未被引用的文件（非预览模式下已移入隔离区）
+	Kept        []OrphanFile `json:"kept"`        // 未被引用但可能通过下载链接共享的文件（非公开或最近有下载），不清理
+	Purged      []OrphanFile `json:"purged"`      // 隔离期满被永久删除（或预览模式下将被删除）的文件
+	FreedBytes  int64        `json:"freed_bytes"` // 永久删除释放的空间
+	Errors      []string     `json:"errors,omitempty"`
+	CollectedAt string       `json:"collected_at"`
+}
+
+// CollectOrphanedUploads 清理未被引用的上传文件
+// 扫描存储中 logos/ 和 files/ 下的文件，未被站点、页面配置或公告引用的移入隔离区，
+// 隔离区中超过保留时长的文件永久删除。dryRun 为 true 时只报告不做修改。
+// 上传时间在宽限期内的文件不会被清理（可能刚上传还未保存到站点）；
+// files/ 下下载权限不是公开或最近被下载过的文件可能通过 /api/download 链接共享，同样不清理
+func CollectOrphanedUploads(db *sql.DB, dryRun bool) (*UploadGCReport, error) {
+	uploadGCMu.Lock()
+	defer uploadGCMu.Unlock()
+
+	if !dryRun {
+		// 先处理登记的待删除文件（上次删除失败的会在这里重试）
+		RemovePendingUploads(db)
+	}
+
+	cfg := config.AppConfig.Upload
+	now := time.Now()
+	report := &UploadGCReport{
+		DryRun:      dryRun,
+		Orphans:     []OrphanFile{},
+		Kept:        []OrphanFile{},
+		Purged:      []OrphanFile{},
+		CollectedAt: now.Format("2006-01-02 15:04:05"),
+	}
+
+	downloadedSince := now.Add(-cfg.GCDownloadWindow)
+	orphans, err := findOrphanedUploads(db, now.Add(-cfg.GCGracePeriod), downloadedSince, report)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, orphan := range orphans {
+		if !dryRun {
+			// 扫描后可能有新的引用或修改了下载权限，移动前再确认一次
+			refs, err := models.CountUploadReferences(db, orphan.Path)
+			if err != nil || refs > 0 {
+				continue
+			}
+			if reason, err := sharedUploadReason(db, orphan.Path, downloadedSince); err != nil || reason != "" {
+				continue
+			}
+			if err := quarantineUpload(orphan.Path); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", orphan.Path, err))
+				continue
+			}
+		}
+		report.Orphans = append(report.Orphans, orphan)
+	}
+
+	purgeQuarantine(db, cfg.QuarantinePath, now.Add(-cfg.QuarantineRetention), report)
+	return report, nil
+}
+
+// findOrphanedUploads 查找未被引用且超过宽限期的上传文件，以及原图已不存在的缩略图
+// files/ 下可能通过下载链接共享的文件（见 sharedUploadReason）记录在 report.Kept 中，不作为孤立文件
+func findOrphanedUploads(db *sql.DB, before, downloadedSince time.Time, report *UploadGCReport) ([]OrphanFile, error) {
+	var orphans []OrphanFile
+	logos := make(map[string]bool) // 现有图标的文件名（不含扩展名），用于判断缩略图是否孤立
+	var thumbs []storage.ObjectInfo
+
+	for _, sub := range uploadSubDirs {
+		objects, err := storage.Default.List(sub + "/")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range objects {
+			rel := strings.TrimPrefix(object.Key, sub+"/")
+			if sub == "logos" && strings.HasPrefix(rel, ThumbnailDir+"/") {
+				thumbs = append(thumbs, object)
+				continue
+			}
+			if strings.Contains(rel, "/") {
+				continue // 其他子目录不处理
+			}
+			if sub == "logos" {
+				logos[strings.TrimSuffix(rel, path.Ext(rel))] = true
+			}
+
+			report.Scanned++
+			if object.ModTime.After(before) {
+				continue
+			}
+
+			accessPath := storage.URLFromKey(object.Key)
+			refs, err := models.CountUploadReferences(db, accessPath)
+			if err != nil {
+				return nil, err
+			}
+			if refs > 0 {
+				continue
+			}
+			if sub == "files" {
+				reason, err := sharedUploadReason(db, accessPath, downloadedSince)
+				if err != nil {
+					return nil, err
+				}
+				if reason != "" {
+					kept := newOrphanFile(accessPath, object)
+					kept.Reason = reason
+					report.Kept = append(report.Kept, kept)
+					continue
+				}
+			}
+			orphans = append(orphans, newOrphanFile(accessPath, object))
+		}
+	}
+
+	// 缩略图随原图一起移入隔离区，这里只处理原图已不存在的缩略图
+	for _, object := range thumbs {
+		report.Scanned++
+		if object.ModTime.After(before) {
+			continue
+		}
+
+		name := path.Base(object.Key)
+		base := strings.TrimSuffix(name, path.Ext(name))
+		if i := strings.LastIndex(base, "_"); i > 0 {
+			base = base[:i]
+		}
+		if !logos[base] {
+			orphans = append(orphans, newOrphanFile(storage.URLFromKey(object.Key), object))
+		}
+	}
+
+	return orphans, nil
+}
+
+// sharedUploadReason 判断未被引用的文件是否可能通过下载链接共享：下载权限不是公开，或在 since 之后被下载过
+// 返回不清理的原因，可以清理时返回空字符串
+func sharedUploadReason(db *sql.DB, accessPath string, since time.Time) (string, error) {
+	upload, err := models.GetUploadByPath(db, accessPath)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if upload.Visibility != "" && upload.Visibility != models.VisibilityPublic {
+		return "下载权限为 " + upload.Visibility, nil
+	}
+	if upload.LastDownloadAt != "" {
+		last, err := time.ParseInLocation("2006-01-02 15:04:05", upload.LastDownloadAt, time.Local)
+		if err == nil && last.After(since) {
+			return "最近下载于 " + upload.LastDownloadAt, nil
+		}
+	}
+	return "", nil
+}
+
+// quarantineUpload 将上传文件（及其缩略图）移入隔离区
+// 上传记录保留到隔离期满永久删除时，期间恢复文件后下载权限和下载次数不变
+func quarantineUpload(accessPath string) error {
+	cfg := config.AppConfig.Upload
+	key, err := storage.KeyFromURL(accessPath)
+	if err != nil {
+		return err
+	}
+
+	if err := moveToQuarantine(key, filepath.Join(cfg.QuarantinePath, filepath.FromSlash(key))); err != nil {
+		return err
+	}
+
+	if path.Dir(key) == "logos" {
+		for _, size := range cfg.ThumbnailSizes {
+			for _, format := range ThumbnailFormats {
+				thumb := ThumbnailPath(key, size, format)
+				if _, err := storage.Default.Stat(thumb); err == nil {
+					moveToQuarantine(thumb, filepath.Join(cfg.QuarantinePath, filepath.FromSlash(thumb)))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// moveToQuarantine 将对象从存储复制到本地隔离区后删除，并把修改时间设为移入时间（用于计算保留时长）
+func moveToQuarantine(key, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	src, _, err := storage.Default.Get(key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	if err := storage.Default.Delete(key); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return os.Chtimes(dst, now, now)
+}
+
+// purgeQuarantine 永久删除隔离区中移入时间早于 before 的文件，并删除其上传记录
+func purgeQuarantine(db *sql.DB, quarantinePath string, before time.Time, report *UploadGCReport) {
+	filepath.Walk(quarantinePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.ModTime().After(before) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(quarantinePath, path)
+		if err != nil {
+			return nil
+		}
+
+		if !report.DryRun {
+			if err := os.Remove(path); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("删除 %s 失败: %v", relPath, err))
+				return nil
+			}
+			if err := deleteQuarantinedRecord(db, filepath.ToSlash(relPath)); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("删除 %s 的上传记录失败: %v", relPath, err))
+			}
+		}
+		report.Purged = append(report.Purged, OrphanFile{
+			Path:    filepath.ToSlash(relPath),
+			Size:    info.Size(),
+			ModTime: info.ModTime().Format("2006-01-02 15:04:05"),
+		})
+		report.FreedBytes += info.Size()
+		return nil
+	})
+}
+
+// deleteQuarantinedRecord 删除隔离期满的文件的上传记录
+// 隔离期间重新上传了相同内容的文件（存储中已存在）时保留记录
+func deleteQuarantinedRecord(db *sql.DB, key string) error {
+	if _, err := storage.Default.Stat(key); err != storage.ErrNotExist {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := models.DeleteUpload(tx, storage.URLFromKey(key)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListQuarantinedUploads 列出隔离区中可以恢复的文件（缩略图随图标一起恢复，不单独列出）
+// Path 为原来的访问路径，ModTime 为移入隔离区的时间
+func ListQuarantinedUploads() ([]OrphanFile, error) {
+	quarantinePath := config.AppConfig.Upload.QuarantinePath
+	files := []OrphanFile{}
+	err := filepath.Walk(quarantinePath, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == quarantinePath {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(quarantinePath, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relPath)
+		if strings.HasPrefix(key, "logos/"+ThumbnailDir+"/") {
+			return nil
+		}
+		files = append(files, OrphanFile{
+			Path:    storage.URLFromKey(key),
+			Size:    info.Size(),
+			ModTime: info.ModTime().Format("2006-01-02 15:04:05"),
+		})
+		return nil
+	})
+	return files, err
+}
+
+// RestoreQuarantinedUpload 把隔离区中的文件（图标连同缩略图）移回存储
+// 上传记录在隔离期间保留，恢复后下载权限和下载次数不变；文件不在隔离区时返回 storage.ErrNotExist
+func RestoreQuarantinedUpload(accessPath string) error {
+	uploadGCMu.Lock()
+	defer uploadGCMu.Unlock()
+
+	cfg := config.AppConfig.Upload
+	key, err := storage.KeyFromURL(accessPath)
+	if err != nil {
+		return err
+	}
+	src := filepath.Join(cfg.QuarantinePath, filepath.FromSlash(key))
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrNotExist
+		}
+		return err
+	}
+
+	if err := restoreFromQuarantine(src, key); err != nil {
+		return err
+	}
+
+	if path.Dir(key) == "logos" {
+		for _, size := range cfg.ThumbnailSizes {
+			for _, format := range ThumbnailFormats {
+				thumb := ThumbnailPath(key, size, format)
+				thumbSrc := filepath.Join(cfg.QuarantinePath, filepath.FromSlash(thumb))
+				if _, err := os.Stat(thumbSrc); err == nil {
+					if err := restoreFromQuarantine(thumbSrc, thumb); err != nil {
+						log.Printf("恢复缩略图 %s 失败: %v", thumb, err)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// restoreFromQuarantine 将隔离区中的文件写回存储后删除
+func restoreFromQuarantine(src, key string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = storage.Default.Put(key, f, info.Size(), storage.ContentType(key))
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// newOrphanFile 根据对象信息生成报告条目
+func newOrphanFile(accessPath string, object storage.ObjectInfo) OrphanFile {
+	return OrphanFile{
+		Path:    accessPath,
+		Size:    object.Size,
+		ModTime: object.ModTime.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// RemovePendingUploads 删除事务中登记的待删除上传文件（在修改或删除站点等操作的事务提交后调用）
+// 出错时记录日志，删除失败的文件保留登记，下次调用时重试
+func RemovePendingUploads(db *sql.DB) {
+	if err := models.RemovePendingFiles(db); err != nil {
+		log.Printf("删除不再引用的上传文件失败: %v", err)
+	}
+}
+
+// StartUploadGC 启动定时清理任务（UPLOAD_GC_INTERVAL 未设置时不启动）
+func StartUploadGC(db *sql.DB) {
+	interval := config.AppConfig.Upload.GCInterval
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := CollectOrphanedUploads(db, false)
+			if err != nil {
+				log.Printf("清理未引用的上传文件失败: %v", err)
+				continue
+			}
+			if len(report.Orphans) > 0 || len(report.Purged) > 0 || len(report.Errors) > 0 {
+				log.Printf("上传文件清理: %d 个文件移入隔离区，%d 个文件永久删除，%d 个错误",
+					len(report.Orphans), len(report.Purged), len(report.Errors))
+			}
+		}
+	}()
+	log.Printf("已启用上传文件定时清理，间隔 %s", interval)
+}
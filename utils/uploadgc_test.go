@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"database/sql"
+	"nav-admin/config"
+	"nav-admin/models"
+	"nav-admin/storage"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDB 创建使用临时目录的数据库和本地存储
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dir := t.TempDir()
+	config.AppConfig = &config.Config{
+		Upload: config.UploadConfig{
+			Path:                filepath.Join(dir, "uploads"),
+			ThumbnailSizes:      []int{32},
+			QuarantinePath:      filepath.Join(dir, "quarantine"),
+			GCGracePeriod:       time.Hour,
+			GCDownloadWindow:    30 * 24 * time.Hour,
+			QuarantineRetention: 24 * time.Hour,
+		},
+		Nav: config.NavConfig{JSONPath: filepath.Join(dir, "nav.json")},
+	}
+	storage.Default = storage.NewLocalStorage(config.AppConfig.Upload.Path)
+
+	db, err := InitDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// putTestUpload 写入上传文件并登记上传记录，文件修改时间设为 age 之前
+func putTestUpload(t *testing.T, db *sql.DB, key string, age time.Duration) string {
+	t.Helper()
+	file := filepath.Join(config.AppConfig.Upload.Path, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("content of "+key), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	accessPath := storage.URLFromKey(key)
+	withTx(t, db, func(tx *sql.Tx) error {
+		return models.CreateUpload(tx, &models.Upload{Hash: key, Path: accessPath})
+	})
+	return accessPath
+}
+
+// createTestSite 在测试分类中创建链接到 href 的站点，返回站点ID
+func createTestSite(t *testing.T, db *sql.DB, href string) int {
+	t.Helper()
+	var siteID int64
+	withTx(t, db, func(tx *sql.Tx) error {
+		var catID int64
+		err := tx.QueryRow("SELECT id FROM categories WHERE id_str = 'test'").Scan(&catID)
+		if err == sql.ErrNoRows {
+			catID, err = models.CreateCategory(tx, &models.Category{IDStr: "test", Classify: "测试"})
+		}
+		if err != nil {
+			return err
+		}
+		siteID, err = models.CreateSite(tx, &models.Site{CatID: int(catID), Name: "站点", Href: href})
+		return err
+	})
+	return int(siteID)
+}
+
+func withTx(t *testing.T, db *sql.DB, fn func(tx *sql.Tx) error) {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func uploadExists(t *testing.T, key string) bool {
+	t.Helper()
+	_, err := storage.Default.Stat(key)
+	if err != nil && err != storage.ErrNotExist {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func countRows(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDeletedSiteFileRemovedOnlyAfterCommit(t *testing.T) {
+	db := newTestDB(t)
+	key := "files/report.pdf"
+	href := putTestUpload(t, db, key, time.Hour)
+	siteID := createTestSite(t, db, href)
+
+	// 事务回滚：文件、上传记录和站点都不受影响
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DeleteSite(tx, siteID); err != nil {
+		t.Fatal(err)
+	}
+	if !uploadExists(t, key) {
+		t.Fatal("file deleted before commit")
+	}
+	tx.Rollback()
+	RemovePendingUploads(db)
+
+	if !uploadExists(t, key) {
+		t.Fatal("file deleted after rollback")
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM uploads WHERE path = ?", href); n != 1 {
+		t.Fatalf("upload rows after rollback = %d, want 1", n)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM pending_file_removals"); n != 0 {
+		t.Fatalf("pending removals after rollback = %d, want 0", n)
+	}
+
+	// 事务提交：提交后才删除文件
+	withTx(t, db, func(tx *sql.Tx) error { return models.DeleteSite(tx, siteID) })
+	if !uploadExists(t, key) {
+		t.Fatal("file deleted before RemovePendingUploads")
+	}
+	RemovePendingUploads(db)
+
+	if uploadExists(t, key) {
+		t.Fatal("file not deleted after commit")
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM uploads WHERE path = ?", href); n != 0 {
+		t.Fatalf("upload rows after commit = %d, want 0", n)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM pending_file_removals"); n != 0 {
+		t.Fatalf("pending removals after commit = %d, want 0", n)
+	}
+}
+
+func TestPendingFileKeptWhenReuploaded(t *testing.T) {
+	db := newTestDB(t)
+	key := "files/shared.zip"
+	href := putTestUpload(t, db, key, time.Hour)
+	siteID := createTestSite(t, db, href)
+
+	withTx(t, db, func(tx *sql.Tx) error { return models.DeleteSite(tx, siteID) })
+
+	// 提交后、删除前重新上传了相同内容的文件（文件已写入，上传记录还未登记）
+	file := filepath.Join(config.AppConfig.Upload.Path, filepath.FromSlash(key))
+	if err := os.WriteFile(file, []byte("content of "+key), 0644); err != nil {
+		t.Fatal(err)
+	}
+	RemovePendingUploads(db)
+
+	if !uploadExists(t, key) {
+		t.Fatal("re-uploaded file was deleted")
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM pending_file_removals"); n != 0 {
+		t.Fatalf("pending removals = %d, want 0", n)
+	}
+}
+
+func TestSharedSiteFileKept(t *testing.T) {
+	db := newTestDB(t)
+	key := "files/manual.pdf"
+	href := putTestUpload(t, db, key, time.Hour)
+	first := createTestSite(t, db, href)
+	createTestSite(t, db, "./uploads/"+key)
+
+	withTx(t, db, func(tx *sql.Tx) error { return models.DeleteSite(tx, first) })
+	RemovePendingUploads(db)
+
+	if !uploadExists(t, key) {
+		t.Fatal("file still referenced by another site was deleted")
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM uploads WHERE path = ?", href); n != 1 {
+		t.Fatalf("upload rows = %d, want 1", n)
+	}
+}
+
+func TestCollectOrphanedUploadsKeepsSharedFiles(t *testing.T) {
+	db := newTestDB(t)
+	age := 48 * time.Hour
+	unused := putTestUpload(t, db, "files/unused.pdf", age)
+	signed := putTestUpload(t, db, "files/signed.pdf", age)
+	recent := putTestUpload(t, db, "files/recent.pdf", age)
+	stale := putTestUpload(t, db, "files/stale.pdf", age)
+
+	timeFormat := "2006-01-02 15:04:05"
+	mustExec(t, db, "UPDATE uploads SET visibility = ? WHERE path = ?", models.VisibilitySigned, signed)
+	mustExec(t, db, "UPDATE uploads SET download_count = 3, last_download_at = ? WHERE path = ?",
+		time.Now().Add(-time.Hour).Format(timeFormat), recent)
+	mustExec(t, db, "UPDATE uploads SET download_count = 5, last_download_at = ? WHERE path = ?",
+		time.Now().Add(-60*24*time.Hour).Format(timeFormat), stale)
+
+	report, err := CollectOrphanedUploads(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quarantined := map[string]bool{}
+	for _, f := range report.Orphans {
+		quarantined[f.Path] = true
+	}
+	kept := map[string]bool{}
+	for _, f := range report.Kept {
+		kept[f.Path] = true
+	}
+	if !quarantined[unused] || !quarantined[stale] || len(report.Orphans) != 2 {
+		t.Fatalf("orphans = %+v, want %s and %s", report.Orphans, unused, stale)
+	}
+	if !kept[signed] || !kept[recent] || len(report.Kept) != 2 {
+		t.Fatalf("kept = %+v, want %s and %s", report.Kept, signed, recent)
+	}
+	if uploadExists(t, "files/stale.pdf") || !uploadExists(t, "files/signed.pdf") || !uploadExists(t, "files/recent.pdf") {
+		t.Fatal("unexpected storage state after collection")
+	}
+
+	// 隔离期间保留上传记录，恢复后下载权限和下载次数不变
+	if n := countRows(t, db, "SELECT COUNT(*) FROM uploads WHERE path = ? AND download_count = 5", stale); n != 1 {
+		t.Fatalf("upload record of quarantined file was not kept")
+	}
+	files, err := ListQuarantinedUploads()
+	if err != nil || len(files) != 2 {
+		t.Fatalf("quarantine list = %+v, %v", files, err)
+	}
+	if err := RestoreQuarantinedUpload(stale); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if !uploadExists(t, "files/stale.pdf") {
+		t.Fatal("restored file missing from storage")
+	}
+	if err := RestoreQuarantinedUpload(stale); err != storage.ErrNotExist {
+		t.Fatalf("second restore err = %v, want ErrNotExist", err)
+	}
+	if err := RestoreQuarantinedUpload("/uploads/../data/test.db"); err != storage.ErrInvalidKey {
+		t.Fatalf("restore outside uploads err = %v, want ErrInvalidKey", err)
+	}
+
+	// 隔离期满后永久删除文件和上传记录
+	quarantinedFile := filepath.Join(config.AppConfig.Upload.QuarantinePath, "files", "unused.pdf")
+	expired := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(quarantinedFile, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	report, err = CollectOrphanedUploads(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Purged) != 1 || report.Purged[0].Path != "files/unused.pdf" {
+		t.Fatalf("purged = %+v", report.Purged)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM uploads WHERE path = ?", unused); n != 0 {
+		t.Fatalf("upload record of purged file still exists")
+	}
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
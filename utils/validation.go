@@ -0,0 +1,543 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"nav-admin/models"
+	"nav-admin/navdoc"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 字段长度限制（按字符数计算）
+const (
+	MaxCategoryIDLength     = 64
+	MaxClassifyLength       = 50
+	MaxIconLength           = 64
+	MaxSiteNameLength       = 100
+	MaxSiteDescLength       = 500
+	MaxURLLength            = 2048
+	MaxAnnouncementLength   = 2000
+	MaxTimestampLength      = 32
+	MaxPageTitleLength      = 100
+	MaxPageFooterLength     = 2000
+	MaxICPLength            = 100
+	MinAnnouncementInterval = 1000
+	MaxAnnouncementInterval = 600000
+	MaxAnnouncementPriority = 1000
+	MaxTagNameLength        = 20
+)
+
+// MaxSiteTags 一个站点最多的标签数
+const MaxSiteTags = 10
+
+// MaxCategoryDepth 分类最多的层级数（顶级分类为第1级）
+const MaxCategoryDepth = 5
+
+// announcementTimeLayouts 公告时间允许的输入格式
+var announcementTimeLayouts = []string{
+	models.AnnouncementTimeLayout,
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// NormalizeAnnouncementTime 将公告时间转换为存储格式（本地时间）
+// 支持 RFC3339 和常见的日期时间格式，空字符串原样返回
+func NormalizeAnnouncementTime(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", true
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(time.Local).Format(models.AnnouncementTimeLayout), true
+	}
+	for _, layout := range announcementTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Format(models.AnnouncementTimeLayout), true
+		}
+	}
+	return "", false
+}
+
+// maxImportValidationErrors 导入校验最多返回的错误条数
+const maxImportValidationErrors = 100
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors 字段校验错误列表
+type ValidationErrors []FieldError
+
+// Add 添加一个字段错误
+func (v *ValidationErrors) Add(field, message string) {
+	*v = append(*v, FieldError{Field: field, Message: message})
+}
+
+// Merge 合并另一组错误，字段名加上前缀（用于导入时定位到具体条目）
+func (v *ValidationErrors) Merge(prefix string, other ValidationErrors) {
+	for _, e := range other {
+		field := e.Field
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+		v.Add(field, e.Message)
+	}
+}
+
+// HasErrors 是否存在错误
+func (v ValidationErrors) HasErrors() bool {
+	return len(v) > 0
+}
+
+// Error 实现 error 接口
+func (v ValidationErrors) Error() string {
+	parts := make([]string, 0, len(v))
+	for _, e := range v {
+		parts = append(parts, e.Field+": "+e.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Queryer 同时兼容 *sql.DB 和 *sql.Tx 的查询接口
+type Queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+var (
+	categoryIDPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+	iconClassPattern  = regexp.MustCompile(`\.(ti-[a-z0-9\-]+):before`)
+
+	iconWhitelistMu sync.RWMutex
+	iconWhitelist   map[string]bool
+)
+
+// LoadIconWhitelist 从 themify-icons.css 内容中解析可用的图标类名
+func LoadIconWhitelist(css []byte) int {
+	icons := make(map[string]bool)
+	for _, m := range iconClassPattern.FindAllSubmatch(css, -1) {
+		icons[string(m[1])] = true
+	}
+
+	iconWhitelistMu.Lock()
+	iconWhitelist = icons
+	iconWhitelistMu.Unlock()
+	return len(icons)
+}
+
+// IsAllowedIcon 判断图标类名是否在白名单中（未加载白名单时不限制）
+func IsAllowedIcon(icon string) bool {
+	iconWhitelistMu.RLock()
+	defer iconWhitelistMu.RUnlock()
+
+	if len(iconWhitelist) == 0 {
+		return true
+	}
+	return iconWhitelist[icon]
+}
+
+// ValidateCategory 校验分类字段
+func ValidateCategory(cat *models.Category) ValidationErrors {
+	var errs ValidationErrors
+	cat.IDStr = strings.TrimSpace(cat.IDStr)
+	cat.Classify = strings.TrimSpace(cat.Classify)
+	cat.Icon = strings.TrimSpace(cat.Icon)
+
+	switch {
+	case cat.IDStr == "":
+		errs.Add("_id", "分类ID不能为空")
+	case utf8.RuneCountInString(cat.IDStr) > MaxCategoryIDLength:
+		errs.Add("_id", fmt.Sprintf("分类ID不能超过%d个字符", MaxCategoryIDLength))
+	case !categoryIDPattern.MatchString(cat.IDStr):
+		errs.Add("_id", "分类ID只能包含字母、数字、下划线和横线")
+	}
+
+	checkRequired(&errs, "classify", "分类名称", cat.Classify, MaxClassifyLength)
+
+	if cat.Icon != "" {
+		if utf8.RuneCountInString(cat.Icon) > MaxIconLength || !IsAllowedIcon(cat.Icon) {
+			errs.Add("icon", "图标不在Themify Icons列表中")
+		}
+	}
+
+	return errs
+}
+
+// ValidateSite 校验站点字段
+// q 不为 nil 时检查所属分类是否存在
+func ValidateSite(q Queryer, site *models.Site) ValidationErrors {
+	var errs ValidationErrors
+	site.Name = strings.TrimSpace(site.Name)
+	site.Href = strings.TrimSpace(site.Href)
+	site.Desc = strings.TrimSpace(site.Desc)
+	site.Logo = strings.TrimSpace(site.Logo)
+	site.Href = addDefaultScheme(site.Href)
+
+	checkRequired(&errs, "name", "站点名称", site.Name, MaxSiteNameLength)
+
+	if site.Href == "" {
+		errs.Add("href", "链接不能为空")
+	} else if msg := checkURL(site.Href, []string{"http", "https", "ftp", "mailto"}); msg != "" {
+		errs.Add("href", msg)
+	}
+
+	if utf8.RuneCountInString(site.Desc) > MaxSiteDescLength {
+		errs.Add("desc", fmt.Sprintf("描述不能超过%d个字符", MaxSiteDescLength))
+	}
+
+	if site.Logo != "" {
+		if msg := checkURL(site.Logo, []string{"http", "https"}); msg != "" {
+			errs.Add("logo", msg)
+		}
+	}
+
+	if site.Tags != nil {
+		tags, msg := normalizeSiteTags(site.Tags)
+		if msg != "" {
+			errs.Add("tags", msg)
+		}
+		site.Tags = tags
+	}
+
+	if q != nil {
+		if site.CatID <= 0 {
+			errs.Add("cat_id", "请选择所属分类")
+		} else {
+			var exists int
+			if err := q.QueryRow("SELECT 1 FROM categories WHERE id = ?", site.CatID).Scan(&exists); err != nil {
+				errs.Add("cat_id", "所属分类不存在")
+			}
+		}
+	}
+
+	return errs
+}
+
+// normalizeSiteTags 规范化站点的标签：去除首尾空白，去掉重复的标签（不区分大小写，保留第一个）
+// 有无效的标签名称或标签过多时返回错误信息
+func normalizeSiteTags(tags []string) ([]string, string) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if msg := checkTagName(tag); msg != "" {
+			return result, msg
+		}
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+	if len(result) > MaxSiteTags {
+		return result, fmt.Sprintf("标签不能超过%d个", MaxSiteTags)
+	}
+	return result, ""
+}
+
+// checkTagName 检查标签名称（已去除首尾空白），有效时返回空字符串
+// 名称中不能有逗号（后台页面和表格中用逗号分隔多个标签）
+func checkTagName(name string) string {
+	switch {
+	case name == "":
+		return "标签名称不能为空"
+	case utf8.RuneCountInString(name) > MaxTagNameLength:
+		return fmt.Sprintf("标签名称不能超过%d个字符", MaxTagNameLength)
+	case strings.ContainsAny(name, ",，"):
+		return "标签名称不能包含逗号"
+	}
+	return ""
+}
+
+// ValidateTag 校验标签字段
+func ValidateTag(tag *models.Tag) ValidationErrors {
+	var errs ValidationErrors
+	tag.Name = strings.TrimSpace(tag.Name)
+	if msg := checkTagName(tag.Name); msg != "" {
+		errs.Add("name", msg)
+	}
+	return errs
+}
+
+// ValidateAnnouncement 校验公告字段
+func ValidateAnnouncement(ann *models.Announcement) ValidationErrors {
+	var errs ValidationErrors
+	ann.Content = strings.TrimSpace(ann.Content)
+	ann.Timestamp = strings.TrimSpace(ann.Timestamp)
+
+	checkRequired(&errs, "content", "公告内容", ann.Content, MaxAnnouncementLength)
+
+	if utf8.RuneCountInString(ann.Timestamp) > MaxTimestampLength {
+		errs.Add("timestamp", fmt.Sprintf("时间不能超过%d个字符", MaxTimestampLength))
+	}
+	if !IsValidRichTextFormat(ann.Format) {
+		errs.Add("format", "格式只能是 html 或 markdown")
+	}
+
+	publishAt, ok := NormalizeAnnouncementTime(ann.PublishAt)
+	if !ok {
+		errs.Add("publish_at", "发布时间格式错误，应为 YYYY-MM-DD HH:MM:SS")
+	}
+	expireAt, ok2 := NormalizeAnnouncementTime(ann.ExpireAt)
+	if !ok2 {
+		errs.Add("expire_at", "过期时间格式错误，应为 YYYY-MM-DD HH:MM:SS")
+	}
+	if ok && ok2 {
+		ann.PublishAt, ann.ExpireAt = publishAt, expireAt
+		if publishAt != "" && expireAt != "" && expireAt <= publishAt {
+			errs.Add("expire_at", "过期时间必须晚于发布时间")
+		}
+	}
+
+	switch ann.Severity {
+	case "", models.SeverityInfo, models.SeverityWarning, models.SeverityCritical:
+	default:
+		errs.Add("severity", "级别只能是 info、warning 或 critical")
+	}
+	if ann.Priority < 0 || ann.Priority > MaxAnnouncementPriority {
+		errs.Add("priority", fmt.Sprintf("优先级必须在0-%d之间", MaxAnnouncementPriority))
+	}
+
+	return errs
+}
+
+// ValidateAnnouncementInterval 校验公告轮播间隔
+func ValidateAnnouncementInterval(interval int) ValidationErrors {
+	var errs ValidationErrors
+	if interval < MinAnnouncementInterval || interval > MaxAnnouncementInterval {
+		errs.Add("interval", fmt.Sprintf("轮播间隔必须在%d-%d毫秒之间", MinAnnouncementInterval, MaxAnnouncementInterval))
+	}
+	return errs
+}
+
+// ValidatePageConfig 校验页面配置字段
+func ValidatePageConfig(cfg *models.PageConfig) ValidationErrors {
+	var errs ValidationErrors
+	cfg.Title = strings.TrimSpace(cfg.Title)
+	cfg.Subtitle = strings.TrimSpace(cfg.Subtitle)
+	cfg.Logo = strings.TrimSpace(cfg.Logo)
+	cfg.ICP = strings.TrimSpace(cfg.ICP)
+
+	checkRequired(&errs, "title", "页面标题", cfg.Title, MaxPageTitleLength)
+
+	if utf8.RuneCountInString(cfg.Subtitle) > MaxPageTitleLength {
+		errs.Add("subtitle", fmt.Sprintf("副标题不能超过%d个字符", MaxPageTitleLength))
+	}
+	if cfg.Logo != "" {
+		if msg := checkURL(cfg.Logo, []string{"http", "https"}); msg != "" {
+			errs.Add("logo", msg)
+		}
+	}
+	if utf8.RuneCountInString(cfg.FooterText) > MaxPageFooterLength {
+		errs.Add("footer_text", fmt.Sprintf("页脚内容不能超过%d个字符", MaxPageFooterLength))
+	}
+	if !IsValidRichTextFormat(cfg.FooterFormat) {
+		errs.Add("footer_format", "格式只能是 html 或 markdown")
+	}
+	if utf8.RuneCountInString(cfg.ICP) > MaxICPLength {
+		errs.Add("icp", fmt.Sprintf("备案号不能超过%d个字符", MaxICPLength))
+	}
+
+	return errs
+}
+
+// ParseNavDocument 解码并校验导入的导航数据（nav.json格式）
+// 不是JSON数组时返回 error；结构和字段内容的错误合并为 ValidationErrors
+func ParseNavDocument(data []byte) (*navdoc.Document, ValidationErrors, error) {
+	doc, err := navdoc.Decode(data)
+	if err != nil {
+		decodeErrs, ok := err.(navdoc.DecodeErrors)
+		if !ok {
+			return nil, nil, err
+		}
+		var errs ValidationErrors
+		for _, e := range decodeErrs {
+			errs.Add(e.Field, e.Message)
+		}
+		return nil, errs, nil
+	}
+
+	if errs := ValidateNavDocument(doc); errs.HasErrors() {
+		return nil, errs, nil
+	}
+	return doc, nil, nil
+}
+
+// ValidateNavDocument 校验导入的导航数据，错误字段以条目在数组中的位置为前缀（如 [2].sites[0].href）
+// 校验通过的字段会被规范化（去除首尾空白、公告时间转换为存储格式）；页面配置由调用方按需校验
+func ValidateNavDocument(doc *navdoc.Document) ValidationErrors {
+	var errs ValidationErrors
+
+	if cfg := doc.AnnouncementConfig; cfg != nil {
+		prefix := fmt.Sprintf("[%d]", cfg.Index)
+		if cfg.Interval != nil {
+			errs.Merge(prefix, ValidateAnnouncementInterval(*cfg.Interval))
+		}
+		for i := range cfg.Announcements {
+			a := &cfg.Announcements[i]
+			ann := &models.Announcement{
+				Timestamp: a.Timestamp,
+				Content:   a.Content,
+				Format:    a.Format,
+				PublishAt: a.PublishAt,
+				ExpireAt:  a.ExpireAt,
+				Priority:  a.Priority,
+				Severity:  a.Severity,
+			}
+			errs.Merge(fmt.Sprintf("%s.announcements[%d]", prefix, i), ValidateAnnouncement(ann))
+			a.Timestamp, a.Content, a.PublishAt, a.ExpireAt = ann.Timestamp, ann.Content, ann.PublishAt, ann.ExpireAt
+		}
+	}
+
+	for i := range doc.Categories {
+		if len(errs) >= maxImportValidationErrors {
+			break
+		}
+		c := &doc.Categories[i]
+		prefix := c.Field()
+
+		cat := &models.Category{IDStr: c.ID, Classify: c.Classify, Icon: c.Icon}
+		errs.Merge(prefix, ValidateCategory(cat))
+		c.ID, c.Classify, c.Icon = cat.IDStr, cat.Classify, cat.Icon
+
+		for j := range c.Sites {
+			s := &c.Sites[j]
+			site := &models.Site{Name: s.Name, Href: s.Href, Desc: s.Desc, Logo: s.Logo, Tags: s.Tags}
+			errs.Merge(fmt.Sprintf("%s.sites[%d]", prefix, j), ValidateSite(nil, site))
+			s.Name, s.Href, s.Desc, s.Logo, s.Tags = site.Name, site.Href, site.Desc, site.Logo, site.Tags
+		}
+	}
+
+	if !errs.HasErrors() {
+		errs = validateCategoryParents(doc.Categories)
+	}
+
+	if len(errs) > maxImportValidationErrors {
+		errs = errs[:maxImportValidationErrors]
+	}
+	return errs
+}
+
+// validateCategoryParents 校验分类的上级分类：必须是文档中唯一的另一个分类，不能形成循环，层级不超过 MaxCategoryDepth
+func validateCategoryParents(categories []navdoc.Category) ValidationErrors {
+	var errs ValidationErrors
+	index := make(map[string]int, len(categories)) // _id -> 位置
+	count := make(map[string]int, len(categories))
+	for i, c := range categories {
+		if _, ok := index[c.ID]; !ok {
+			index[c.ID] = i
+		}
+		count[c.ID]++
+	}
+
+	for i := range categories {
+		if len(errs) >= maxImportValidationErrors {
+			break
+		}
+		c := &categories[i]
+		c.Parent = strings.TrimSpace(c.Parent)
+		if c.Parent == "" {
+			continue
+		}
+		field := c.Field() + ".parent"
+		switch {
+		case c.Parent == c.ID:
+			errs.Add(field, "上级分类不能是自己")
+			continue
+		case count[c.Parent] == 0:
+			errs.Add(field, "上级分类不存在: "+c.Parent)
+			continue
+		case count[c.Parent] > 1:
+			errs.Add(field, "上级分类ID重复: "+c.Parent)
+			continue
+		}
+
+		// 沿上级分类向上查找，检查循环和层级
+		depth := 1
+		for p := c.Parent; p != ""; p = categories[index[p]].Parent {
+			depth++
+			if p == c.ID {
+				errs.Add(field, "上级分类形成循环")
+				break
+			}
+			if depth > MaxCategoryDepth {
+				errs.Add(field, fmt.Sprintf("分类层级不能超过%d级", MaxCategoryDepth))
+				break
+			}
+			if _, ok := index[categories[index[p]].Parent]; !ok {
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// checkRequired 检查必填字段及长度
+func checkRequired(errs *ValidationErrors, field, label, value string, maxLen int) {
+	if value == "" {
+		errs.Add(field, label+"不能为空")
+		return
+	}
+	if utf8.RuneCountInString(value) > maxLen {
+		errs.Add(field, fmt.Sprintf("%s不能超过%d个字符", label, maxLen))
+	}
+}
+
+// bareHostPattern 匹配没有协议的域名（或IP）开头的链接，如 example.com、localhost:8080/path
+var bareHostPattern = regexp.MustCompile(`^(?i)(localhost|[a-z0-9-]+(\.[a-z0-9-]+)+)(:\d{1,5})?([/?#]|$)`)
+
+// addDefaultScheme 给没有协议的域名链接（如 example.com）加上 https://，其他链接原样返回
+func addDefaultScheme(href string) string {
+	if bareHostPattern.MatchString(href) {
+		return "https://" + href
+	}
+	return href
+}
+
+// checkURL 检查链接格式和协议，允许以 / 开头的站内路径
+// 返回空字符串表示合法
+func checkURL(raw string, schemes []string) string {
+	if utf8.RuneCountInString(raw) > MaxURLLength {
+		return fmt.Sprintf("链接不能超过%d个字符", MaxURLLength)
+	}
+	if strings.ContainsAny(raw, "\r\n\t<>\"") {
+		return "链接包含非法字符"
+	}
+
+	// 站内路径（如 /uploads/...、/static/...），禁止协议相对地址和路径穿越
+	if strings.HasPrefix(raw, "/") {
+		if strings.HasPrefix(raw, "//") || strings.HasPrefix(raw, "/\\") {
+			return "不支持协议相对地址"
+		}
+		if strings.Contains(raw, "..") {
+			return "链接包含非法路径"
+		}
+		return ""
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "链接格式错误"
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	for _, allowed := range schemes {
+		if scheme == allowed {
+			if (scheme == "http" || scheme == "https" || scheme == "ftp") && u.Host == "" {
+				return "链接缺少域名"
+			}
+			return ""
+		}
+	}
+	return "不支持的链接协议，仅允许: " + strings.Join(schemes, ", ")
+}
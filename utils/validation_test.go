@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"encoding/json"
+	"nav-admin/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateSiteHref(t *testing.T) {
+	tests := []struct {
+		href string
+		want string // 规范化后的链接，空字符串表示校验失败
+	}{
+		{"https://example.com", "https://example.com"},
+		{" example.com ", "https://example.com"},
+		{"www.example.com/path?q=1", "https://www.example.com/path?q=1"},
+		{"localhost:8080", "https://localhost:8080"},
+		{"192.168.1.10:5000/", "https://192.168.1.10:5000/"},
+		{"ftp://files.example.com/pub", "ftp://files.example.com/pub"},
+		{"mailto:admin@example.com", "mailto:admin@example.com"},
+		{"/uploads/files/a.pdf", "/uploads/files/a.pdf"},
+		{"javascript:alert(1)", ""},
+		{"example", ""},
+		{"//example.com", ""},
+		{"http://", ""},
+	}
+	for _, tt := range tests {
+		site := &models.Site{Name: "站点", Href: tt.href}
+		errs := ValidateSite(nil, site)
+		if tt.want == "" {
+			if !errs.HasErrors() {
+				t.Errorf("ValidateSite(%q) accepted, href = %q", tt.href, site.Href)
+			}
+			continue
+		}
+		if errs.HasErrors() {
+			t.Errorf("ValidateSite(%q) = %v", tt.href, errs)
+		} else if site.Href != tt.want {
+			t.Errorf("ValidateSite(%q) href = %q, want %q", tt.href, site.Href, tt.want)
+		}
+	}
+}
+
+// FuzzParseNavDocument 任意输入都不应导致 panic；校验通过并规范化后的文档编码后应能再次通过校验
+func FuzzParseNavDocument(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("..", "navdoc", "testdata", "*.json"))
+	if err != nil || len(files) == 0 {
+		f.Fatalf("no seed documents: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte(`[{"_id": "a", "classify": " A ", "sites": [{"name": " x ", "href": "example.com", "tags": [" t ", "t"]}]}]`))
+	f.Add([]byte(`[{"type": "announcement_config", "announcements": [{"timestamp": "2024-01-01T08:00:00Z", "content": "<b>x</b>", "format": "html"}]}]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, errs, err := ParseNavDocument(data)
+		if err != nil || errs.HasErrors() {
+			return
+		}
+		out, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if _, errs, err := ParseNavDocument(out); err != nil || errs.HasErrors() {
+			t.Fatalf("parse normalized document: %v %v\n%s", err, errs, out)
+		}
+	})
+}
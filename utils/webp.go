@@ -0,0 +1,433 @@
+package utils
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// 标准库和 golang.org/x/image 只提供WebP解码，这里实现一个精简的无损WebP(VP8L)编码器，
+// 用于生成缩略图。只使用"减绿"和预测变换加熵编码，不做LZ77反向引用，缩略图尺寸小，压缩率足够。
+
+const (
+	vp8lSignature          = 0x2f
+	vp8lMaxDimension       = 1 << 14
+	vp8lTransformPredictor = 0
+	vp8lTransformGreen     = 2
+	vp8lPredictorBits      = 4 // 预测模式按16×16的块选择
+	vp8lMaxCodeLength      = 15
+	vp8lMaxCLCLength       = 7 // 码长编码(code length code)的最大码长
+	vp8lGreenAlphabet      = 256 + 24
+	vp8lColorAlphabet      = 256
+	vp8lDistAlphabet       = 40
+	vp8lNumCLCSymbols      = 19
+)
+
+// vp8lCodeLengthOrder 码长编码的码长写入顺序（规范规定）
+var vp8lCodeLengthOrder = [vp8lNumCLCSymbols]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// EncodeWebP 将图片编码为无损WebP
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 || width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return errors.New("webp: 图片尺寸超出范围")
+	}
+
+	// 读取像素并应用"减绿"变换（红、蓝分量减去绿色分量）
+	pixels := make([][4]uint8, 0, width*height) // 顺序: 绿、红、蓝、透明度
+	hasAlpha := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A != 0xff {
+				hasAlpha = true
+			}
+			pixels = append(pixels, [4]uint8{c.G, c.R - c.G, c.B - c.G, c.A})
+		}
+	}
+
+	bw := &vp8lBitWriter{}
+	bw.writeBits(vp8lSignature, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if hasAlpha {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+	bw.writeBits(0, 3) // 版本号
+
+	// 变换：先减绿，再做预测（解码时按相反顺序还原）
+	bw.writeBits(1, 1)
+	bw.writeBits(vp8lTransformGreen, 2)
+
+	residuals, modes := vp8lApplyPredictor(pixels, width, height)
+	bw.writeBits(1, 1)
+	bw.writeBits(vp8lTransformPredictor, 2)
+	bw.writeBits(vp8lPredictorBits-2, 3)
+	bw.writeImageData(modes, false)
+
+	bw.writeBits(0, 1) // 变换结束
+
+	bw.writeImageData(residuals, true)
+
+	data := bw.flush()
+
+	// RIFF容器
+	chunkSize := len(data)
+	padding := chunkSize & 1
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+chunkSize+padding))
+	buf.WriteString("WEBPVP8L")
+	binary.Write(&buf, binary.LittleEndian, uint32(chunkSize))
+	buf.Write(data)
+	if padding == 1 {
+		buf.WriteByte(0)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// vp8lPredictorModes 候选的预测模式：1=左 2=上 4=左上 6=平均(左,左上) 7=平均(左,上) 8=平均(左上,上)
+var vp8lPredictorModes = []uint8{1, 2, 4, 6, 7, 8}
+
+// vp8lApplyPredictor 对每个块选择残差最小的预测模式，返回残差和模式子图
+// 第一个像素预测为不透明黑色，第一行预测为左侧像素，第一列预测为上方像素（规范规定）
+func vp8lApplyPredictor(pixels [][4]uint8, width, height int) ([][4]uint8, [][4]uint8) {
+	tileSize := 1 << vp8lPredictorBits
+	tilesX := (width + tileSize - 1) / tileSize
+	tilesY := (height + tileSize - 1) / tileSize
+
+	predict := func(mode uint8, x, y int) [4]uint8 {
+		switch {
+		case x == 0 && y == 0:
+			return [4]uint8{0, 0, 0, 0xff}
+		case y == 0:
+			return pixels[y*width+x-1]
+		case x == 0:
+			return pixels[(y-1)*width+x]
+		}
+		l, t, tl := pixels[y*width+x-1], pixels[(y-1)*width+x], pixels[(y-1)*width+x-1]
+		var p [4]uint8
+		for i := 0; i < 4; i++ {
+			switch mode {
+			case 1:
+				p[i] = l[i]
+			case 2:
+				p[i] = t[i]
+			case 4:
+				p[i] = tl[i]
+			case 6:
+				p[i] = uint8((int(l[i]) + int(tl[i])) / 2)
+			case 7:
+				p[i] = uint8((int(l[i]) + int(t[i])) / 2)
+			case 8:
+				p[i] = uint8((int(tl[i]) + int(t[i])) / 2)
+			}
+		}
+		return p
+	}
+
+	residuals := make([][4]uint8, len(pixels))
+	modes := make([][4]uint8, 0, tilesX*tilesY)
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			x0, y0 := tx*tileSize, ty*tileSize
+			x1, y1 := min(x0+tileSize, width), min(y0+tileSize, height)
+
+			// 以残差绝对值之和衡量预测效果
+			best, bestCost := vp8lPredictorModes[0], -1
+			for _, mode := range vp8lPredictorModes {
+				cost := 0
+				for y := y0; y < y1; y++ {
+					for x := x0; x < x1; x++ {
+						p := predict(mode, x, y)
+						for i := 0; i < 4; i++ {
+							d := int(int8(pixels[y*width+x][i] - p[i]))
+							if d < 0 {
+								d = -d
+							}
+							cost += d
+						}
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					p := predict(best, x, y)
+					for i := 0; i < 4; i++ {
+						residuals[y*width+x][i] = pixels[y*width+x][i] - p[i]
+					}
+				}
+			}
+			// 预测模式保存在子图的绿色分量中
+			modes = append(modes, [4]uint8{best, 0, 0, 0xff})
+		}
+	}
+	return residuals, modes
+}
+
+// writeImageData 写入一幅熵编码图像（主图或变换使用的子图），像素顺序为绿、红、蓝、透明度
+func (bw *vp8lBitWriter) writeImageData(pixels [][4]uint8, topLevel bool) {
+	bw.writeBits(0, 1) // 不使用颜色缓存
+	if topLevel {
+		bw.writeBits(0, 1) // 不使用元前缀编码
+	}
+
+	// 统计各分量的频率并生成前缀编码
+	var hist [4][]int
+	hist[0] = make([]int, vp8lGreenAlphabet)
+	for i := 1; i < 4; i++ {
+		hist[i] = make([]int, vp8lColorAlphabet)
+	}
+	for _, p := range pixels {
+		for i := 0; i < 4; i++ {
+			hist[i][p[i]]++
+		}
+	}
+
+	var codes [5]vp8lPrefixCode
+	for i := 0; i < 4; i++ {
+		codes[i] = bw.writePrefixCode(hist[i])
+	}
+	codes[4] = bw.writePrefixCode(make([]int, vp8lDistAlphabet)) // 距离编码（未使用）
+
+	for _, p := range pixels {
+		for i := 0; i < 4; i++ {
+			codes[i].write(bw, int(p[i]))
+		}
+	}
+}
+
+// vp8lBitWriter 按VP8L规范从低位开始写入比特
+type vp8lBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (bw *vp8lBitWriter) writeBits(value uint32, n uint) {
+	bw.acc |= uint64(value) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+func (bw *vp8lBitWriter) flush() []byte {
+	if bw.nbits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nbits = 0, 0
+	}
+	return bw.buf
+}
+
+// vp8lPrefixCode 前缀编码：每个符号的码长和（已按写入顺序反转的）码字
+type vp8lPrefixCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+func (pc vp8lPrefixCode) write(bw *vp8lBitWriter, symbol int) {
+	if n := pc.lengths[symbol]; n > 0 {
+		bw.writeBits(pc.codes[symbol], uint(n))
+	}
+}
+
+// writePrefixCode 根据频率生成前缀编码并写入码表
+// 不超过两个符号且符号值小于256时使用"简单编码"，否则使用"常规编码"
+func (bw *vp8lBitWriter) writePrefixCode(hist []int) vp8lPrefixCode {
+	var used []int
+	for symbol, count := range hist {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+
+	pc := vp8lPrefixCode{lengths: make([]int, len(hist)), codes: make([]uint32, len(hist))}
+
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		bw.writeBits(1, 1) // 简单编码
+		bw.writeBits(uint32(len(used)-1), 1)
+		if used[0] <= 1 {
+			bw.writeBits(0, 1)
+			bw.writeBits(uint32(used[0]), 1)
+		} else {
+			bw.writeBits(1, 1)
+			bw.writeBits(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.writeBits(uint32(used[1]), 8)
+			pc.lengths[used[0]], pc.codes[used[0]] = 1, 0
+			pc.lengths[used[1]], pc.codes[used[1]] = 1, 1
+		}
+		return pc
+	}
+
+	pc.lengths = huffmanCodeLengths(hist, vp8lMaxCodeLength)
+	pc.codes = canonicalCodes(pc.lengths)
+
+	// 码长编码：统计码长(0-15)出现次数，生成最长7位的前缀编码
+	clcHist := make([]int, vp8lNumCLCSymbols)
+	for _, n := range pc.lengths {
+		clcHist[n]++
+	}
+	clcLengths := huffmanCodeLengths(clcHist, vp8lMaxCLCLength)
+	clcCodes := canonicalCodes(clcLengths)
+
+	numCodes := vp8lNumCLCSymbols
+	for numCodes > 4 && clcLengths[vp8lCodeLengthOrder[numCodes-1]] == 0 {
+		numCodes--
+	}
+
+	bw.writeBits(0, 1) // 常规编码
+	bw.writeBits(uint32(numCodes-4), 4)
+	for i := 0; i < numCodes; i++ {
+		bw.writeBits(uint32(clcLengths[vp8lCodeLengthOrder[i]]), 3)
+	}
+	bw.writeBits(0, 1) // 写入全部符号的码长
+	for _, n := range pc.lengths {
+		if clcLengths[n] > 0 {
+			bw.writeBits(clcCodes[n], uint(clcLengths[n]))
+		}
+	}
+
+	return pc
+}
+
+// huffmanNode 构建霍夫曼树的节点
+type huffmanNode struct {
+	count       int
+	symbol      int // 叶子节点的符号，内部节点为-1
+	left, right *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].symbol < h[j].symbol
+}
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// huffmanCodeLengths 计算码长不超过 maxLength 的霍夫曼码长
+// 超长时逐步抬高小频率符号的最小计数，使树变得平坦（与libwebp的做法一致）
+// 只有一个符号时补充一个符号，保证得到完整的二叉树
+func huffmanCodeLengths(hist []int, maxLength int) []int {
+	var used []int
+	for symbol, count := range hist {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) == 1 {
+		extra := 0
+		if used[0] == 0 {
+			extra = 1
+		}
+		used = append(used, extra)
+		sort.Ints(used)
+	}
+
+	lengths := make([]int, len(hist))
+	for minCount := 1; ; minCount *= 2 {
+		h := make(huffmanHeap, 0, len(used))
+		for _, symbol := range used {
+			count := hist[symbol]
+			if count < minCount {
+				count = minCount
+			}
+			h = append(h, &huffmanNode{count: count, symbol: symbol})
+		}
+		heap.Init(&h)
+		for h.Len() > 1 {
+			a := heap.Pop(&h).(*huffmanNode)
+			b := heap.Pop(&h).(*huffmanNode)
+			heap.Push(&h, &huffmanNode{count: a.count + b.count, symbol: -1, left: a, right: b})
+		}
+
+		for i := range lengths {
+			lengths[i] = 0
+		}
+		if assignDepths(h[0], 0, lengths) <= maxLength {
+			return lengths
+		}
+	}
+}
+
+// assignDepths 记录每个叶子的深度，返回最大深度
+func assignDepths(node *huffmanNode, depth int, lengths []int) int {
+	if node.left == nil {
+		lengths[node.symbol] = depth
+		return depth
+	}
+	l := assignDepths(node.left, depth+1, lengths)
+	r := assignDepths(node.right, depth+1, lengths)
+	if l > r {
+		return l
+	}
+	return r
+}
+
+// canonicalCodes 根据码长生成规范霍夫曼码，并按写入顺序反转比特
+func canonicalCodes(lengths []int) []uint32 {
+	var count [vp8lMaxCodeLength + 1]int
+	for _, n := range lengths {
+		count[n]++
+	}
+	count[0] = 0
+
+	var next [vp8lMaxCodeLength + 2]uint32
+	code := uint32(0)
+	for bits := 1; bits <= vp8lMaxCodeLength; bits++ {
+		code = (code + uint32(count[bits-1])) << 1
+		next[bits] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, n := range lengths {
+		if n == 0 {
+			continue
+		}
+		codes[symbol] = reverseBits(next[n], n)
+		next[n]++
+	}
+	return codes
+}
+
+// reverseBits 反转 code 的低 n 位
+func reverseBits(code uint32, n int) uint32 {
+	var r uint32
+	for i := 0; i < n; i++ {
+		r = r<<1 | code&1
+		code >>= 1
+	}
+	return r
+}
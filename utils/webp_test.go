@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	opaque := image.NewRGBA(image.Rect(0, 0, 37, 23))
+	for y := 0; y < 23; y++ {
+		for x := 0; x < 37; x++ {
+			opaque.Set(x, y, color.RGBA{uint8(x * 7), uint8(y * 11), uint8(x * y), 0xff})
+		}
+	}
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 20, 17))
+	for y := 0; y < 17; y++ {
+		for x := 0; x < 20; x++ {
+			transparent.SetNRGBA(x, y, color.NRGBA{uint8(x * 13), uint8(y * 5), 200, uint8((x + y) * 9)})
+		}
+	}
+
+	palette := color.Palette{
+		color.NRGBA{0, 0, 0, 0},
+		color.NRGBA{255, 0, 0, 255},
+		color.NRGBA{0, 128, 255, 255},
+		color.NRGBA{20, 200, 20, 128},
+	}
+	paletted := image.NewPaletted(image.Rect(0, 0, 33, 18), palette)
+	for i := range paletted.Pix {
+		paletted.Pix[i] = uint8(rng.Intn(len(palette)))
+	}
+
+	noise := image.NewNRGBA(image.Rect(0, 0, 64, 40))
+	rng.Read(noise.Pix)
+
+	// 取值按几何分布，符号频率相差悬殊，检查码长限制（最长15位）
+	skewed := image.NewNRGBA(image.Rect(0, 0, 256, 128))
+	for i := 0; i < len(skewed.Pix); i += 4 {
+		v := uint8(bits.TrailingZeros32(rng.Uint32()|1<<31) * 8)
+		skewed.Pix[i], skewed.Pix[i+1], skewed.Pix[i+2], skewed.Pix[i+3] = v, v/2, 255-v, 255
+	}
+
+	single := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	single.SetNRGBA(0, 0, color.NRGBA{10, 20, 30, 255})
+
+	solid := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range solid.Pix {
+		solid.Pix[i] = 0xff
+	}
+
+	// 起点不是 (0,0) 的子图
+	sub := opaque.SubImage(image.Rect(5, 3, 30, 20))
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"opaque", opaque},
+		{"transparent", transparent},
+		{"paletted", paletted},
+		{"noise", noise},
+		{"skewed", skewed},
+		{"single pixel", single},
+		{"solid", solid},
+		{"sub image", sub},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, tt.img); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if err := CheckFileContent(".webp", buf.Bytes()); err != nil {
+				t.Fatalf("encoded data is not recognized as webp: %v", err)
+			}
+
+			decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			b := tt.img.Bounds()
+			if decoded.Bounds().Dx() != b.Dx() || decoded.Bounds().Dy() != b.Dy() {
+				t.Fatalf("size = %v, want %v", decoded.Bounds().Size(), b.Size())
+			}
+			db := decoded.Bounds()
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					want := color.NRGBAModel.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+					got := color.NRGBAModel.Convert(decoded.At(db.Min.X+x, db.Min.Y+y)).(color.NRGBA)
+					if got != want {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebPRejectsInvalidSize(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, image.NewNRGBA(image.Rect(0, 0, 0, 10))); err == nil {
+		t.Error("expected error for empty image")
+	}
+	if err := EncodeWebP(&buf, image.NewNRGBA(image.Rect(0, 0, vp8lMaxDimension+1, 1))); err == nil {
+		t.Error("expected error for oversized image")
+	}
+}
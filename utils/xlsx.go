@@ -0,0 +1,323 @@
+package utils
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 读取 xlsx 时的限制，防止压缩炸弹和超大表格
+const (
+	maxXLSXPartSize = 50 * 1024 * 1024 // 单个xml文件解压后的大小
+	maxXLSXColumns  = 256              // 每行读取的列数
+)
+
+// ErrInvalidXLSX 不是有效的 xlsx 文件
+var ErrInvalidXLSX = errors.New("不是有效的xlsx文件")
+
+// SheetRow 表格中的一行，Line 为在文件中的行号（从1开始）
+type SheetRow struct {
+	Line  int
+	Cells []string
+}
+
+// xlsxText 共享字符串或内联字符串（纯文本 <t> 或富文本 <r><t>）
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+// String 返回文本内容
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var sb strings.Builder
+	for _, r := range t.Runs {
+		sb.WriteString(r.T)
+	}
+	return sb.String()
+}
+
+// xlsxWorksheet 工作表中需要的部分
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			V      string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXLSX 读取 xlsx 文件第一个工作表的内容（只读取单元格的值，不计算公式）
+// 空行不返回
+func ReadXLSX(r io.ReaderAt, size int64) ([]SheetRow, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrInvalidXLSX
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decodeXLSXPart(f, &sst); err != nil {
+			return nil, err
+		}
+		shared = make([]string, len(sst.Items))
+		for i, si := range sst.Items {
+			shared[i] = si.String()
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, ErrInvalidXLSX
+	}
+	var ws xlsxWorksheet
+	if err := decodeXLSXPart(f, &ws); err != nil {
+		return nil, err
+	}
+
+	var result []SheetRow
+	line := 0
+	for _, row := range ws.Rows {
+		if row.R > 0 {
+			line = row.R
+		} else {
+			line++
+		}
+
+		var cells []string
+		col := -1
+		for _, c := range row.Cells {
+			if idx, ok := xlsxColumnIndex(c.Ref); ok {
+				col = idx
+			} else {
+				col++
+			}
+			if col >= maxXLSXColumns {
+				break
+			}
+
+			var value string
+			switch c.Type {
+			case "s":
+				i, err := strconv.Atoi(strings.TrimSpace(c.V))
+				if err != nil || i < 0 || i >= len(shared) {
+					return nil, fmt.Errorf("第%d行的共享字符串索引无效", line)
+				}
+				value = shared[i]
+			case "inlineStr":
+				value = c.Inline.String()
+			case "b":
+				value = "FALSE"
+				if c.V == "1" {
+					value = "TRUE"
+				}
+			default:
+				value = c.V
+			}
+
+			for len(cells) < col {
+				cells = append(cells, "")
+			}
+			cells = append(cells, value)
+		}
+
+		empty := true
+		for _, v := range cells {
+			if strings.TrimSpace(v) != "" {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			result = append(result, SheetRow{Line: line, Cells: cells})
+		}
+	}
+	return result, nil
+}
+
+// firstSheetPath 从 workbook.xml 及其关系文件中找到第一个工作表的路径
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	wbFile, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", ErrInvalidXLSX
+	}
+	var wb struct {
+		Sheets []struct {
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeXLSXPart(wbFile, &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", errors.New("xlsx文件中没有工作表")
+	}
+	relID := ""
+	for _, attr := range wb.Sheets[0].Attrs {
+		if attr.Name.Local == "id" && attr.Name.Space != "" {
+			relID = attr.Value
+		}
+	}
+
+	relsFile, ok := files["xl/_rels/workbook.xml.rels"]
+	if relID == "" || !ok {
+		return fallback, nil
+	}
+	var rels struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeXLSXPart(relsFile, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Items {
+		if rel.ID == relID {
+			if strings.HasPrefix(rel.Target, "/") {
+				return path.Clean(strings.TrimPrefix(rel.Target, "/")), nil
+			}
+			return path.Clean(path.Join("xl", rel.Target)), nil
+		}
+	}
+	return fallback, nil
+}
+
+// decodeXLSXPart 解析 xlsx 中的一个xml文件（限制解压后的大小）
+func decodeXLSXPart(f *zip.File, v interface{}) error {
+	if f.UncompressedSize64 > maxXLSXPartSize {
+		return errors.New("xlsx文件内容过大")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return ErrInvalidXLSX
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, maxXLSXPartSize)).Decode(v); err != nil {
+		return ErrInvalidXLSX
+	}
+	return nil
+}
+
+// xlsxCellRefPattern 单元格引用（如 B12）
+var xlsxCellRefPattern = regexp.MustCompile(`^([A-Za-z]{1,3})[0-9]*$`)
+
+// xlsxColumnIndex 单元格引用对应的列号（从0开始）
+func xlsxColumnIndex(ref string) (int, bool) {
+	m := xlsxCellRefPattern.FindStringSubmatch(ref)
+	if m == nil {
+		return 0, false
+	}
+	idx := 0
+	for _, ch := range strings.ToUpper(m[1]) {
+		idx = idx*26 + int(ch-'A'+1)
+	}
+	return idx - 1, true
+}
+
+// xlsxColumnName 列号（从0开始）对应的列名（如 0 -> A，26 -> AA）
+func xlsxColumnName(idx int) string {
+	name := ""
+	for idx >= 0 {
+		name = string(rune('A'+idx%26)) + name
+		idx = idx/26 - 1
+	}
+	return name
+}
+
+// xlsxNumberPattern 写入为数字单元格的值（不含前导零的整数，避免改变编号类文本）
+var xlsxNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]{0,14})$`)
+
+// xlsx 包中固定的文件
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+)
+
+// WriteXLSX 把表格内容写为只有一个工作表的 xlsx 文件，文本使用内联字符串，整数写为数字
+func WriteXLSX(w io.Writer, sheetName string, rows [][]string) error {
+	zw := zip.NewWriter(w)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` + xmlEscape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	}
+	for _, p := range parts {
+		fw, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, p.content); err != nil {
+			return err
+		}
+	}
+
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(fw)
+	bw.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	bw.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		line := strconv.Itoa(i + 1)
+		bw.WriteString(`<row r="` + line + `">`)
+		for j, value := range row {
+			ref := xlsxColumnName(j) + line
+			if xlsxNumberPattern.MatchString(value) {
+				bw.WriteString(`<c r="` + ref + `"><v>` + value + `</v></c>`)
+			} else {
+				bw.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + xmlEscape(value) + `</t></is></c>`)
+			}
+		}
+		bw.WriteString(`</row>`)
+	}
+	bw.WriteString(`</sheetData></worksheet>`)
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// xmlEscape 转义xml文本（无效的xml字符替换为 U+FFFD）
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
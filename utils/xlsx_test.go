@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSitesXLSXRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSitesXLSX(&buf, testSheetDoc); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := ParseSiteSheet(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// xlsx 中的文本不会被当作公式，不加单引号
+	want := append([]SiteSheetRow(nil), testSheetRows...)
+	for i := range want {
+		want[i].Line = i + 2
+	}
+	checkSheetRows(t, "xlsx", sheet.Rows, want)
+}
+
+// testXLSX 用给定的文件生成 xlsx，workbook.xml 和关系文件未指定时使用默认内容
+func testXLSX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	if _, ok := parts["xl/workbook.xml"]; !ok {
+		parts["xl/workbook.xml"] = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	// Excel 保存的文件：共享字符串（含富文本和注音）、省略的空单元格、跳过的行和内联字符串
+	data := testXLSX(t, map[string]string{
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/data.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="4" uniqueCount="4">
+			<si><t>name</t></si>
+			<si><t>href</t></si>
+			<si><r><rPr><b/></rPr><t>富</t></r><r><t xml:space="preserve">文本 </t></r><rPh sb="0" eb="1"><t>fu</t></rPh></si>
+			<si><t>category</t></si>
+		</sst>`,
+		"xl/worksheets/data.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+			<row r="2"><c r="A2" t="s"><v>0</v></c><c r="C2" t="s"><v>1</v></c><c r="E2" t="s"><v>3</v></c></row>
+			<row r="3"><c r="B3"><v>  </v></c></row>
+			<row r="5" spans="1:5"><c r="A5" t="s"><v>2</v></c><c r="C5" t="inlineStr"><is><t>https://a.example.com</t></is></c><c r="E5" t="str"><f>"A"&amp;"B"</f><v>AB</v></c></row>
+			<row><c t="b"><v>1</v></c><c><v>1.5</v></c><c r="D6" t="inlineStr"><is><r><t>x</t></r><r><t>y</t></r></is></c></row>
+		</sheetData></worksheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c t="inlineStr"><is><t>wrong sheet</t></is></c></row></sheetData></worksheet>`,
+	})
+	rows, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SheetRow{
+		{Line: 2, Cells: []string{"name", "", "href", "", "category"}},
+		{Line: 5, Cells: []string{"富文本 ", "", "https://a.example.com", "", "AB"}},
+		{Line: 6, Cells: []string{"TRUE", "1.5", "", "xy"}},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %q", rows)
+	}
+	for i := range want {
+		if rows[i].Line != want[i].Line || strings.Join(rows[i].Cells, "|") != strings.Join(want[i].Cells, "|") {
+			t.Errorf("row %d = %d %q, want %d %q", i, rows[i].Line, rows[i].Cells, want[i].Line, want[i].Cells)
+		}
+	}
+
+	sheet, err := ParseSiteSheet(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSheetRows(t, "sparse", sheet.Rows, []SiteSheetRow{
+		{Line: 5, Category: "AB", Name: "富文本", Href: "https://a.example.com"},
+		{Line: 6, Name: "TRUE"},
+	})
+
+	// 没有关系文件时使用 sheet1.xml
+	data = testXLSX(t, map[string]string{
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="AB1" t="inlineStr"><is><t>far</t></is></c></row></sheetData></worksheet>`,
+	})
+	rows, err = ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	if err != nil || len(rows) != 1 || len(rows[0].Cells) != 28 || rows[0].Cells[27] != "far" {
+		t.Errorf("sheet1 fallback: %q, %v", rows, err)
+	}
+}
+
+func TestReadXLSXErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		error string
+	}{
+		{"not zip", []byte("name,href\n"), ErrInvalidXLSX.Error()},
+		{"no sheets", testXLSX(t, map[string]string{"xl/workbook.xml": `<workbook><sheets/></workbook>`}), "没有工作表"},
+		{"missing sheet", testXLSX(t, map[string]string{}), ErrInvalidXLSX.Error()},
+		{"bad xml", testXLSX(t, map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row>`}), ErrInvalidXLSX.Error()},
+		{"bad shared string", testXLSX(t, map[string]string{
+			"xl/sharedStrings.xml":     `<sst><si><t>a</t></si></sst>`,
+			"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="4"><c t="s"><v>1</v></c></row></sheetData></worksheet>`,
+		}), "第4行的共享字符串索引无效"},
+	}
+	for _, tt := range tests {
+		if _, err := ReadXLSX(bytes.NewReader(tt.data), int64(len(tt.data))); err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.error)
+		}
+	}
+}
+
+func TestXLSXColumns(t *testing.T) {
+	for idx, name := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumnName(idx); got != name {
+			t.Errorf("xlsxColumnName(%d) = %s, want %s", idx, got, name)
+		}
+		if got, ok := xlsxColumnIndex(name + "12"); !ok || got != idx {
+			t.Errorf("xlsxColumnIndex(%s12) = %d, %v", name, got, ok)
+		}
+	}
+	if _, ok := xlsxColumnIndex("12"); ok {
+		t.Error("xlsxColumnIndex(12) ok")
+	}
+}